@@ -48,15 +48,33 @@ func main() {
 	log.Println("✓ Directories created")
 
 	// Initialize scheduler
-	sched := scheduler.NewScheduler(db, cfg.SchedulerIntervalSecs, cfg.MaxConcurrentJobs)
+	sched, err := scheduler.NewScheduler(db, cfg)
+	if err != nil {
+		log.Fatal("Failed to initialize scheduler:", err)
+	}
+
+	// Take over local jobs this host's previous server left behind, before
+	// this replica accepts API requests
+	if _, err := sched.Recover(); err != nil {
+		log.Printf("Failed to recover scheduler state: %v", err)
+	} else {
+		log.Println("✓ Scheduler state recovered")
+	}
+
+	// Only one replica schedules
+	if sched.TryBecomeLeader() {
+		log.Println("✓ Scheduling leader")
+	} else {
+		log.Println("✓ Another replica is the scheduling leader; serving the API only")
+	}
 
 	// Start scheduler in background
 	go sched.Start()
-	log.Printf("✓ Scheduler started (interval: %ds, max concurrent: %d)",
-		cfg.SchedulerIntervalSecs, cfg.MaxConcurrentJobs)
+	log.Printf("✓ Scheduler started (interval: %ds, max concurrent: %d, executor: %s, placement: %s)",
+		cfg.SchedulerIntervalSecs, cfg.MaxConcurrentJobs, cfg.ExecutorMode, cfg.PlacementStrategy)
 
 	// Set up API router
-	router := api.SetupRouter(db, jwtManager)
+	router := api.SetupRouter(db, jwtManager, sched, cfg.WorkerToken)
 
 	// Start server in a goroutine
 	go func() {
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/samik-k21/research-compute-queue/internal/config"
+	"github.com/samik-k21/research-compute-queue/internal/models"
+	"github.com/samik-k21/research-compute-queue/internal/scheduler"
+)
+
+func main() {
+	// Scheduling settings default to the server's (.env and environment)
+	cfg := config.Load()
+
+	trace := flag.String("trace", "", "Job trace to replay (CSV export or .swf file)")
+	format := flag.String("format", "auto", "Trace format: auto, csv or swf (auto = swf for .swf files)")
+	clusterFile := flag.String("cluster", "", "JSON file describing workers, departments, groups and users")
+	workers := flag.Int("workers", 4, "Identical workers to simulate without -cluster")
+	cpus := flag.Int("cpus", 32, "CPU cores per worker without -cluster")
+	memory := flag.Int("memory", 128, "Memory (GB) per worker without -cluster")
+	gpus := flag.Int("gpus", 0, "GPUs per worker without -cluster")
+	flag.StringVar(&cfg.PlacementStrategy, "placement", cfg.PlacementStrategy, "Placement strategy (PLACEMENT_STRATEGY)")
+	flag.BoolVar(&cfg.BackfillEnabled, "backfill", cfg.BackfillEnabled, "EASY backfill (BACKFILL_ENABLED)")
+	flag.IntVar(&cfg.MaxConcurrentJobs, "max-concurrent", cfg.MaxConcurrentJobs, "Maximum running jobs (MAX_CONCURRENT_JOBS)")
+	flag.IntVar(&cfg.SchedulerIntervalSecs, "interval", cfg.SchedulerIntervalSecs, "Seconds between cycles (SCHEDULER_INTERVAL_SECONDS)")
+	flag.BoolVar(&cfg.SchedulerEventsEnabled, "events", cfg.SchedulerEventsEnabled, "Cycle on submissions and completions (SCHEDULER_EVENTS_ENABLED)")
+	flag.Float64Var(&cfg.FairShareHalfLifeHours, "half-life", cfg.FairShareHalfLifeHours, "Fair-share usage half-life in hours (FAIRSHARE_HALF_LIFE_HOURS)")
+	asJSON := flag.Bool("json", false, "Print the report as JSON")
+	flag.Parse()
+
+	if *trace == "" {
+		log.Fatal("A trace is required (-trace)")
+	}
+	if err := cfg.ValidateScheduling(); err != nil {
+		log.Fatal("Configuration validation failed:", err)
+	}
+
+	cluster := models.SimCluster{
+		Workers: []models.SimWorker{{Hostname: "sim", CPUCores: *cpus, MemoryGB: *memory, GPUCount: *gpus, Count: *workers}},
+	}
+	if *clusterFile != "" {
+		data, err := os.ReadFile(*clusterFile)
+		if err != nil {
+			log.Fatal("Failed to read cluster file:", err)
+		}
+		cluster = models.SimCluster{}
+		if err := json.Unmarshal(data, &cluster); err != nil {
+			log.Fatal("Invalid cluster file:", err)
+		}
+	}
+
+	jobs, err := readTrace(*trace, *format)
+	if err != nil {
+		log.Fatal("Failed to read trace:", err)
+	}
+	if len(jobs) == 0 {
+		log.Fatal("The trace has no jobs that ran")
+	}
+
+	sim, err := scheduler.NewSimulation(cfg, cluster)
+	if err != nil {
+		log.Fatal("Failed to set up simulation:", err)
+	}
+
+	log.Printf("Replaying %d jobs (placement: %s, backfill: %t, max concurrent: %d, interval: %ds, events: %t)",
+		len(jobs), cfg.PlacementStrategy, cfg.BackfillEnabled, cfg.MaxConcurrentJobs,
+		cfg.SchedulerIntervalSecs, cfg.SchedulerEventsEnabled)
+
+	r := newReport(sim, sim.Run(jobs))
+	if *asJSON {
+		err = r.writeJSON(os.Stdout)
+	} else {
+		err = r.writeText(os.Stdout)
+	}
+	if err != nil {
+		log.Fatal("Failed to write report:", err)
+	}
+}
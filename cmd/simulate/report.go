@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/samik-k21/research-compute-queue/internal/scheduler"
+)
+
+// slowdownBound keeps very short jobs from dominating the slowdown: a job's
+// runtime counts as at least this long (bounded slowdown)
+const slowdownBound = 10 * time.Second
+
+// report summarizes a simulation
+type report struct {
+	Jobs         int `json:"jobs"`
+	Started      int `json:"started"`
+	NeverStarted int `json:"never_started"` // Too big for any worker
+	Killed       int `json:"killed"`        // Ran past their walltime
+	Cycles       int `json:"cycles"`
+
+	Workers  int       `json:"workers"`
+	CPUCores int       `json:"cpu_cores"`
+	GPUs     int       `json:"gpus"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+
+	CPUUtilisation float64 `json:"cpu_utilisation"` // Fraction of the cluster's CPU time used
+	GPUUtilisation float64 `json:"gpu_utilisation"`
+
+	Wait     waitStats     `json:"wait_seconds"`
+	Slowdown slowdownStats `json:"bounded_slowdown"`
+
+	Groups   []groupReport `json:"groups"`
+	Fairness float64       `json:"fairness_index"` // Jain's index of usage over target, 1 = perfectly fair
+}
+
+// waitStats are queue waits in seconds
+type waitStats struct {
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+// slowdownStats are (wait + runtime) / max(runtime, 10s)
+type slowdownStats struct {
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P95  float64 `json:"p95"`
+	Max  float64 `json:"max"`
+}
+
+// groupReport is how one group fared
+type groupReport struct {
+	GroupID      int     `json:"group_id"`
+	Jobs         int     `json:"jobs"`
+	CPUHours     float64 `json:"cpu_hours"`
+	UsageShare   float64 `json:"usage_share"`  // Fraction of all CPU hours used
+	TargetShare  float64 `json:"target_share"` // Fraction its fair-share shares entitle it to
+	MeanWait     float64 `json:"mean_wait_seconds"`
+	MeanSlowdown float64 `json:"mean_slowdown"`
+}
+
+// newReport computes the report from the outcomes of a simulation
+func newReport(sim *scheduler.Simulation, outcomes []scheduler.SimOutcome) report {
+	r := report{Jobs: len(outcomes), Cycles: sim.Cycles}
+	r.Workers, r.CPUCores, r.GPUs = sim.Capacity()
+
+	var waits, slowdowns []float64
+	var cpuSeconds, gpuSeconds, totalCPUHours float64
+	groups := make(map[int]*groupReport)
+	groupWaits := make(map[int][]float64)
+	groupSlowdowns := make(map[int][]float64)
+
+	for _, o := range outcomes {
+		if r.From.IsZero() || o.Job.SubmitAt.Before(r.From) {
+			r.From = o.Job.SubmitAt
+		}
+		g, ok := groups[o.Job.GroupID]
+		if !ok {
+			g = &groupReport{GroupID: o.Job.GroupID}
+			groups[o.Job.GroupID] = g
+		}
+		g.Jobs++
+
+		if !o.Started {
+			r.NeverStarted++
+			continue
+		}
+		r.Started++
+		if o.Killed {
+			r.Killed++
+		}
+		if o.EndAt.After(r.To) {
+			r.To = o.EndAt
+		}
+
+		wait := o.StartAt.Sub(o.Job.SubmitAt).Seconds()
+		run := o.EndAt.Sub(o.StartAt)
+		slowdown := (wait + run.Seconds()) / max(run, slowdownBound).Seconds()
+		waits = append(waits, wait)
+		slowdowns = append(slowdowns, slowdown)
+		groupWaits[g.GroupID] = append(groupWaits[g.GroupID], wait)
+		groupSlowdowns[g.GroupID] = append(groupSlowdowns[g.GroupID], slowdown)
+
+		cpuSeconds += run.Seconds() * float64(o.Job.CPUCores)
+		gpuSeconds += run.Seconds() * float64(o.Job.GPUCount)
+		g.CPUHours += run.Hours() * float64(o.Job.CPUCores)
+		totalCPUHours += run.Hours() * float64(o.Job.CPUCores)
+	}
+
+	if span := r.To.Sub(r.From).Seconds(); span > 0 {
+		r.CPUUtilisation = cpuSeconds / (float64(r.CPUCores) * span)
+		if r.GPUs > 0 {
+			r.GPUUtilisation = gpuSeconds / (float64(r.GPUs) * span)
+		}
+	}
+
+	sort.Float64s(waits)
+	r.Wait = waitStats{
+		Mean: mean(waits),
+		P50:  percentile(waits, 50),
+		P90:  percentile(waits, 90),
+		P95:  percentile(waits, 95),
+		P99:  percentile(waits, 99),
+		Max:  percentile(waits, 100),
+	}
+	sort.Float64s(slowdowns)
+	r.Slowdown = slowdownStats{
+		Mean: mean(slowdowns),
+		P50:  percentile(slowdowns, 50),
+		P95:  percentile(slowdowns, 95),
+		Max:  percentile(slowdowns, 100),
+	}
+
+	// Fairness compares each group's share of the CPU hours with the share
+	// it is entitled to; only groups that submitted jobs count
+	targets := sim.GroupShares()
+	var targetSum float64
+	for id := range groups {
+		targetSum += targets[id]
+	}
+	var ratios []float64
+	for id, g := range groups {
+		if targetSum > 0 {
+			g.TargetShare = targets[id] / targetSum
+		}
+		if totalCPUHours > 0 {
+			g.UsageShare = g.CPUHours / totalCPUHours
+		}
+		g.MeanWait = mean(groupWaits[id])
+		g.MeanSlowdown = mean(groupSlowdowns[id])
+		if g.TargetShare > 0 {
+			ratios = append(ratios, g.UsageShare/g.TargetShare)
+		}
+		r.Groups = append(r.Groups, *g)
+	}
+	sort.Slice(r.Groups, func(i, j int) bool { return r.Groups[i].GroupID < r.Groups[j].GroupID })
+	r.Fairness = jainIndex(ratios)
+	return r
+}
+
+// mean returns the mean of the values (0 for none)
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// jainIndex returns Jain's fairness index (Σx)² / (n·Σx²): 1 when all values
+// are equal, 1/n when one takes everything
+func jainIndex(values []float64) float64 {
+	var sum, squares float64
+	for _, v := range values {
+		sum += v
+		squares += v * v
+	}
+	if squares == 0 {
+		return 1
+	}
+	return sum * sum / (float64(len(values)) * squares)
+}
+
+// writeJSON writes the report as JSON
+func (r *report) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// writeText writes the report as a table
+func (r *report) writeText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Cluster:\t%d workers, %d CPU cores, %d GPUs\n", r.Workers, r.CPUCores, r.GPUs)
+	fmt.Fprintf(tw, "Trace:\t%s → %s (%s)\n", r.From.Format(time.RFC3339), r.To.Format(time.RFC3339),
+		r.To.Sub(r.From).Round(time.Second))
+	fmt.Fprintf(tw, "Jobs:\t%d started, %d never fit on a worker, %d killed at their walltime\n",
+		r.Started, r.NeverStarted, r.Killed)
+	fmt.Fprintf(tw, "Cycles:\t%d\n", r.Cycles)
+	fmt.Fprintf(tw, "Utilisation:\t%.1f%% CPU", r.CPUUtilisation*100)
+	if r.GPUs > 0 {
+		fmt.Fprintf(tw, ", %.1f%% GPU", r.GPUUtilisation*100)
+	}
+	fmt.Fprintln(tw)
+	fmt.Fprintf(tw, "Wait:\tmean %s, p50 %s, p90 %s, p95 %s, p99 %s, max %s\n",
+		seconds(r.Wait.Mean), seconds(r.Wait.P50), seconds(r.Wait.P90),
+		seconds(r.Wait.P95), seconds(r.Wait.P99), seconds(r.Wait.Max))
+	fmt.Fprintf(tw, "Slowdown:\tmean %.2f, p50 %.2f, p95 %.2f, max %.2f (bounded at %s)\n",
+		r.Slowdown.Mean, r.Slowdown.P50, r.Slowdown.P95, r.Slowdown.Max, slowdownBound)
+	fmt.Fprintf(tw, "Fairness:\t%.3f (Jain's index of usage over target share)\n", r.Fairness)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Group\tJobs\tCPU hours\tUsage\tTarget\tMean wait\tMean slowdown\t")
+	for _, g := range r.Groups {
+		fmt.Fprintf(tw, "%d\t%d\t%.1f\t%.1f%%\t%.1f%%\t%s\t%.2f\t\n",
+			g.GroupID, g.Jobs, g.CPUHours, g.UsageShare*100, g.TargetShare*100, seconds(g.MeanWait), g.MeanSlowdown)
+	}
+	return tw.Flush()
+}
+
+// seconds formats a number of seconds as a duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second)).Round(time.Second)
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samik-k21/research-compute-queue/internal/scheduler"
+)
+
+// Trace formats
+const (
+	formatCSV = "csv" // Export of the jobs table (scripts/export_trace.sql)
+	formatSWF = "swf" // Standard Workload Format of the Parallel Workloads Archive
+)
+
+// readTrace loads the jobs of a trace file
+func readTrace(path, format string) ([]scheduler.SimJob, error) {
+	if format == "auto" {
+		format = formatCSV
+		if strings.HasSuffix(strings.ToLower(path), ".swf") {
+			format = formatSWF
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch format {
+	case formatCSV:
+		return readExport(f)
+	case formatSWF:
+		return readSWF(f)
+	default:
+		return nil, fmt.Errorf("unknown trace format %q (use auto, %s or %s)", format, formatCSV, formatSWF)
+	}
+}
+
+// exportTimeLayouts are the timestamp formats psql and RFC 3339 exports use
+var exportTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07",
+	time.RFC3339Nano,
+}
+
+// parseExportTime parses a timestamp of a CSV export
+func parseExportTime(value string) (time.Time, error) {
+	for _, layout := range exportTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+}
+
+// readExport reads a CSV export of the jobs table with a header row. Jobs that
+// never started are skipped; the rest run for as long as they did for real.
+func readExport(r io.Reader) ([]scheduler.SimJob, error) {
+	rows := csv.NewReader(r)
+	header, err := rows.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+	column := make(map[string]int)
+	for i, name := range header {
+		column[strings.TrimSpace(name)] = i
+	}
+	for _, name := range []string{"id", "user_id", "group_id", "submitted_at", "started_at", "completed_at", "cpu_cores", "memory_gb"} {
+		if _, ok := column[name]; !ok {
+			return nil, fmt.Errorf("CSV trace has no %s column", name)
+		}
+	}
+
+	var jobs []scheduler.SimJob
+	for line := 2; ; line++ {
+		record, err := rows.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		get := func(name string) string {
+			if i, ok := column[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		if get("started_at") == "" || get("completed_at") == "" {
+			continue
+		}
+
+		job, err := exportJob(get)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// exportJob converts one CSV row
+func exportJob(get func(string) string) (scheduler.SimJob, error) {
+	var job scheduler.SimJob
+	ints := []struct {
+		name     string
+		value    *int
+		optional bool
+	}{
+		{"id", &job.ID, false},
+		{"user_id", &job.UserID, false},
+		{"group_id", &job.GroupID, false},
+		{"cpu_cores", &job.CPUCores, false},
+		{"memory_gb", &job.MemoryGB, false},
+		{"gpu_count", &job.GPUCount, true},
+		{"priority", &job.Priority, true},
+	}
+	for _, field := range ints {
+		value := get(field.name)
+		if value == "" && field.optional {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return job, fmt.Errorf("invalid %s %q", field.name, value)
+		}
+		*field.value = n
+	}
+
+	if value := get("estimated_hours"); value != "" {
+		hours, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return job, fmt.Errorf("invalid estimated_hours %q", value)
+		}
+		job.EstimatedHours = hours
+	}
+
+	var times [3]time.Time
+	for i, name := range []string{"submitted_at", "started_at", "completed_at"} {
+		t, err := parseExportTime(get(name))
+		if err != nil {
+			return job, fmt.Errorf("%s: %w", name, err)
+		}
+		times[i] = t
+	}
+	job.SubmitAt = times[0]
+	job.RunTime = times[2].Sub(times[1])
+	if job.RunTime < 0 {
+		job.RunTime = 0
+	}
+	return job, nil
+}
+
+// SWF fields (1-based in the format description)
+const (
+	swfJobNumber     = 0
+	swfSubmitTime    = 1
+	swfRunTime       = 3
+	swfAllocatedCPUs = 4
+	swfUsedMemory    = 6 // KB per processor
+	swfRequestedCPUs = 7
+	swfRequestedTime = 8
+	swfRequestedMem  = 9 // KB per processor
+	swfStatus        = 10
+	swfUserID        = 11
+	swfGroupID       = 12
+	swfFields        = 18
+)
+
+// swfCancelled is the status of jobs cancelled before they started
+const swfCancelled = 5
+
+// readSWF reads a Standard Workload Format trace. Submit times are relative to
+// the UnixStartTime header; processors become CPU cores and the requested
+// time becomes estimated_hours. Jobs that never ran are skipped.
+func readSWF(r io.Reader) ([]scheduler.SimJob, error) {
+	var start time.Time
+	var jobs []scheduler.SimJob
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, ";") {
+			header := strings.TrimSpace(strings.TrimPrefix(text, ";"))
+			if value, ok := strings.CutPrefix(header, "UnixStartTime:"); ok {
+				if secs, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil {
+					start = time.Unix(secs, 0).UTC()
+				}
+			}
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) < swfFields {
+			return nil, fmt.Errorf("line %d: expected %d fields, got %d", line, swfFields, len(fields))
+		}
+		values := make([]float64, len(fields))
+		for i, field := range fields {
+			v, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid field %d %q", line, i+1, field)
+			}
+			values[i] = v
+		}
+
+		cpus := int(values[swfRequestedCPUs])
+		if cpus <= 0 {
+			cpus = int(values[swfAllocatedCPUs])
+		}
+		runTime := values[swfRunTime]
+		if cpus <= 0 || runTime < 0 || (runTime == 0 && int(values[swfStatus]) == swfCancelled) {
+			continue
+		}
+
+		// Memory is given per processor; default to 1 GB when unknown
+		memoryKB := values[swfRequestedMem]
+		if memoryKB <= 0 {
+			memoryKB = values[swfUsedMemory]
+		}
+		memoryGB := 1
+		if memoryKB > 0 {
+			memoryGB = int(math.Max(1, math.Ceil(memoryKB*float64(cpus)/(1024*1024))))
+		}
+
+		job := scheduler.SimJob{
+			ID:       int(values[swfJobNumber]),
+			UserID:   int(values[swfUserID]),
+			GroupID:  int(math.Max(0, values[swfGroupID])),
+			SubmitAt: start.Add(time.Duration(values[swfSubmitTime] * float64(time.Second))),
+			RunTime:  time.Duration(runTime * float64(time.Second)),
+			CPUCores: cpus,
+			MemoryGB: memoryGB,
+			Priority: 1,
+		}
+		if values[swfRequestedTime] > 0 {
+			job.EstimatedHours = values[swfRequestedTime] / 3600
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, scanner.Err()
+}
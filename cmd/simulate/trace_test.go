@@ -0,0 +1,135 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samik-k21/research-compute-queue/internal/scheduler"
+)
+
+func TestReadSWF(t *testing.T) {
+	start := time.Unix(1000000000, 0).UTC()
+	header := "; Version: 2.2\n; UnixStartTime: 1000000000\n;\n"
+
+	tests := []struct {
+		name  string
+		trace string
+		want  []scheduler.SimJob
+	}{
+		{
+			name:  "job with the start time header",
+			trace: header + "1 60 5 3600 4 -1 -1 4 7200 -1 1 3 2 -1 -1 -1 -1 -1\n",
+			want: []scheduler.SimJob{{
+				ID: 1, UserID: 3, GroupID: 2, SubmitAt: start.Add(time.Minute), RunTime: time.Hour,
+				EstimatedHours: 2, CPUCores: 4, MemoryGB: 1, Priority: 1,
+			}},
+		},
+		{
+			name:  "without a start time header",
+			trace: "1 60 5 3600 4 -1 -1 4 7200 -1 1 3 2 -1 -1 -1 -1 -1\n",
+			want: []scheduler.SimJob{{
+				ID: 1, UserID: 3, GroupID: 2, SubmitAt: time.Time{}.Add(time.Minute), RunTime: time.Hour,
+				EstimatedHours: 2, CPUCores: 4, MemoryGB: 1, Priority: 1,
+			}},
+		},
+		{
+			name:  "comments and blank lines only",
+			trace: header + "\n  \n; MaxJobs: 0\n",
+			want:  nil,
+		},
+		{
+			name:  "empty trace",
+			trace: "",
+			want:  nil,
+		},
+		{
+			name:  "allocated processors when none were requested",
+			trace: header + "2 0 0 10 8 -1 -1 -1 -1 -1 1 1 1 -1 -1 -1 -1 -1\n",
+			want: []scheduler.SimJob{{
+				ID: 2, UserID: 1, GroupID: 1, SubmitAt: start, RunTime: 10 * time.Second,
+				CPUCores: 8, MemoryGB: 1, Priority: 1,
+			}},
+		},
+		{
+			name:  "requested memory per processor",
+			trace: header + "3 0 0 10 4 -1 -1 4 -1 524288 1 1 1 -1 -1 -1 -1 -1\n",
+			want: []scheduler.SimJob{{
+				ID: 3, UserID: 1, GroupID: 1, SubmitAt: start, RunTime: 10 * time.Second,
+				CPUCores: 4, MemoryGB: 2, Priority: 1,
+			}},
+		},
+		{
+			name:  "used memory when none was requested",
+			trace: header + "4 0 0 10 2 -1 1048576 2 -1 -1 1 1 1 -1 -1 -1 -1 -1\n",
+			want: []scheduler.SimJob{{
+				ID: 4, UserID: 1, GroupID: 1, SubmitAt: start, RunTime: 10 * time.Second,
+				CPUCores: 2, MemoryGB: 2, Priority: 1,
+			}},
+		},
+		{
+			name:  "memory rounds up",
+			trace: header + "5 0 0 10 1 -1 -1 1 -1 1000 1 1 1 -1 -1 -1 -1 -1\n",
+			want: []scheduler.SimJob{{
+				ID: 5, UserID: 1, GroupID: 1, SubmitAt: start, RunTime: 10 * time.Second,
+				CPUCores: 1, MemoryGB: 1, Priority: 1,
+			}},
+		},
+		{
+			name:  "unknown group",
+			trace: header + "6 0 0 10 1 -1 -1 1 -1 -1 1 7 -1 -1 -1 -1 -1 -1\n",
+			want: []scheduler.SimJob{{
+				ID: 6, UserID: 7, GroupID: 0, SubmitAt: start, RunTime: 10 * time.Second,
+				CPUCores: 1, MemoryGB: 1, Priority: 1,
+			}},
+		},
+		{
+			name:  "zero runtime that completed",
+			trace: header + "7 0 0 0 1 -1 -1 1 -1 -1 1 1 1 -1 -1 -1 -1 -1\n",
+			want: []scheduler.SimJob{{
+				ID: 7, UserID: 1, GroupID: 1, SubmitAt: start, CPUCores: 1, MemoryGB: 1, Priority: 1,
+			}},
+		},
+		{
+			name: "jobs that never ran are skipped",
+			trace: header +
+				"8 0 0 -1 4 -1 -1 4 -1 -1 1 1 1 -1 -1 -1 -1 -1\n" + // Unknown runtime
+				"9 0 0 0 4 -1 -1 4 -1 -1 5 1 1 -1 -1 -1 -1 -1\n" + // Cancelled before starting
+				"10 0 0 60 -1 -1 -1 -1 -1 -1 1 1 1 -1 -1 -1 -1 -1\n" + // No processors
+				"11 30 0 60 1 -1 -1 1 -1 -1 1 1 1 -1 -1 -1 -1 -1\n",
+			want: []scheduler.SimJob{{
+				ID: 11, UserID: 1, GroupID: 1, SubmitAt: start.Add(30 * time.Second), RunTime: time.Minute,
+				CPUCores: 1, MemoryGB: 1, Priority: 1,
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		jobs, err := readSWF(strings.NewReader(tt.trace))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(jobs) != len(tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, jobs, tt.want)
+			continue
+		}
+		for i := range jobs {
+			if jobs[i] != tt.want[i] {
+				t.Errorf("%s: got job %+v, want %+v", tt.name, jobs[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestReadSWFInvalid(t *testing.T) {
+	header := "; Version: 2.2\n; UnixStartTime: 1000000000\n;\n"
+	for _, trace := range []string{
+		header + "1 0 0 10 1 -1 -1 1\n",                             // Too few fields
+		header + "1 0 0 ten 1 -1 -1 1 -1 -1 1 1 1 -1 -1 -1 -1 -1\n", // Not a number
+	} {
+		if jobs, err := readSWF(strings.NewReader(trace)); err == nil {
+			t.Errorf("%q: got %+v, expected an error", trace, jobs)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/samik-k21/research-compute-queue/internal/agent"
+)
+
+func main() {
+	hostname, _ := os.Hostname()
+
+	server := flag.String("server", getEnv("RCQ_SERVER", "http://localhost:8080"), "Scheduler API URL")
+	token := flag.String("token", os.Getenv("WORKER_TOKEN"), "Shared worker token (WORKER_TOKEN)")
+	name := flag.String("hostname", hostname, "Worker name; use distinct names to run several agents on one machine")
+	cpus := flag.Int("cpus", agent.DetectCPUCores(), "CPU cores offered to the scheduler")
+	memory := flag.Int("memory", agent.DetectMemoryGB(), "Memory (GB) offered to the scheduler")
+	gpus := flag.Int("gpus", agent.DetectGPUs(), "GPUs offered to the scheduler")
+	shell := flag.String("shell", getEnv("JOB_SHELL", "/bin/bash"), "Shell used to run job scripts")
+	heartbeat := flag.Duration("heartbeat", 15*time.Second, "Heartbeat interval")
+	poll := flag.Duration("poll", 5*time.Second, "How often to check for newly assigned jobs")
+	grace := flag.Duration("grace", 10*time.Second, "Grace period between SIGTERM and SIGKILL when stopping a job")
+	flag.Parse()
+
+	if *token == "" {
+		log.Fatal("A worker token is required (-token or WORKER_TOKEN)")
+	}
+	if *cpus < 1 || *memory < 1 {
+		log.Fatal("Could not detect resources; set -cpus and -memory")
+	}
+
+	log.Println("========================================")
+	log.Printf("Research Compute Queue Worker Agent")
+	log.Printf("Server: %s", *server)
+	log.Println("========================================")
+
+	a := agent.New(agent.NewClient(*server, *token), agent.Config{
+		Hostname:          *name,
+		CPUCores:          *cpus,
+		MemoryGB:          *memory,
+		GPUCount:          *gpus,
+		Shell:             *shell,
+		HeartbeatInterval: *heartbeat,
+		PollInterval:      *poll,
+		GracePeriod:       *grace,
+	})
+
+	// Stop jobs and report them on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := a.Run(ctx); err != nil && err != context.Canceled {
+		log.Fatal("Worker agent failed:", err)
+	}
+
+	log.Println("✓ Worker agent stopped")
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,292 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/samik-k21/research-compute-queue/internal/models"
+	"github.com/samik-k21/research-compute-queue/internal/runner"
+)
+
+// Config describes the resources an agent offers and how it talks to the server
+type Config struct {
+	Hostname          string
+	CPUCores          int
+	MemoryGB          int
+	GPUCount          int
+	Shell             string
+	HeartbeatInterval time.Duration
+	PollInterval      time.Duration
+	GracePeriod       time.Duration // SIGTERM → SIGKILL grace when stopping a job
+}
+
+// Agent runs jobs the scheduler assigns to one worker
+type Agent struct {
+	client   *Client
+	cfg      Config
+	workerID int
+
+	mu   sync.Mutex
+	jobs map[int]*agentJob
+	wg   sync.WaitGroup
+}
+
+// agentJob is a job process running on this agent
+type agentJob struct {
+	stop       context.CancelFunc
+	stopReason string
+}
+
+// New creates an agent
+func New(client *Client, cfg Config) *Agent {
+	return &Agent{
+		client: client,
+		cfg:    cfg,
+		jobs:   make(map[int]*agentJob),
+	}
+}
+
+// Run registers the agent and serves jobs until ctx is cancelled. On shutdown
+// running jobs are stopped and reported as failed so the server releases them.
+func (a *Agent) Run(ctx context.Context) error {
+	if err := a.register(ctx); err != nil {
+		return err
+	}
+
+	heartbeat := time.NewTicker(a.cfg.HeartbeatInterval)
+	defer heartbeat.Stop()
+	poll := time.NewTicker(a.cfg.PollInterval)
+	defer poll.Stop()
+
+	a.claim()
+
+	for {
+		select {
+		case <-heartbeat.C:
+			a.heartbeat(ctx)
+		case <-poll.C:
+			a.claim()
+		case <-ctx.Done():
+			a.stopAll("worker agent shut down")
+			a.wg.Wait()
+			return nil
+		}
+	}
+}
+
+// register retries until the server accepts the agent or ctx is cancelled
+func (a *Agent) register(ctx context.Context) error {
+	req := models.RegisterWorkerRequest{
+		Hostname: a.cfg.Hostname,
+		CPUCores: a.cfg.CPUCores,
+		MemoryGB: a.cfg.MemoryGB,
+		GPUCount: a.cfg.GPUCount,
+	}
+
+	for {
+		workerID, err := a.client.Register(req)
+		if err == nil {
+			a.workerID = workerID
+			log.Printf("Registered as worker %d (%s: %d CPU, %d GB RAM, %d GPU)",
+				workerID, a.cfg.Hostname, a.cfg.CPUCores, a.cfg.MemoryGB, a.cfg.GPUCount)
+			return nil
+		}
+		log.Printf("Registration failed, retrying: %v", err)
+
+		select {
+		case <-time.After(a.cfg.HeartbeatInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// heartbeat reports running jobs and stops the ones the server no longer wants
+func (a *Agent) heartbeat(ctx context.Context) {
+	stopJobs, err := a.client.Heartbeat(a.workerID, a.runningJobs())
+	if errors.Is(err, errWorkerUnknown) {
+		log.Println("Server no longer knows this worker, registering again")
+		a.register(ctx)
+		return
+	}
+	if err != nil {
+		log.Printf("Heartbeat failed: %v", err)
+		return
+	}
+
+	for _, jobID := range stopJobs {
+		if a.stopJob(jobID, "Cancelled by scheduler") {
+			log.Printf("Stopping job %d at the server's request", jobID)
+		}
+	}
+}
+
+// claim fetches newly assigned jobs and starts them
+func (a *Agent) claim() {
+	jobs, err := a.client.Claim(a.workerID)
+	if err != nil {
+		log.Printf("Claiming jobs failed: %v", err)
+		return
+	}
+
+	for _, job := range jobs {
+		a.start(job)
+	}
+}
+
+// start launches a claimed job in the background
+func (a *Agent) start(job models.AssignedJob) {
+	ctx, stop := context.WithCancel(context.Background())
+
+	a.mu.Lock()
+	a.jobs[job.JobID] = &agentJob{stop: stop}
+	a.mu.Unlock()
+
+	// Enforce the walltime locally; the server only knows the job from heartbeats
+	var walltime *time.Timer
+	if job.WalltimeSeconds > 0 {
+		limit := time.Duration(job.WalltimeSeconds) * time.Second
+		reason := fmt.Sprintf("%s: exceeded walltime of %v", models.TimeoutReason, limit)
+		walltime = time.AfterFunc(limit, func() { a.stopJob(job.JobID, reason) })
+	}
+
+	log.Printf("Starting job %d (%d CPU, %d GB RAM, %d GPU)", job.JobID, job.CPUCores, job.MemoryGB, job.GPUCount)
+
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		defer stop()
+
+		result := a.runJob(ctx, job)
+		if walltime != nil {
+			walltime.Stop()
+		}
+
+		a.mu.Lock()
+		delete(a.jobs, job.JobID)
+		a.mu.Unlock()
+
+		a.report(job.JobID, result)
+	}()
+}
+
+// runJob runs the job script and returns the result to report
+func (a *Agent) runJob(ctx context.Context, job models.AssignedJob) models.JobResultRequest {
+	if err := os.MkdirAll(job.OutputPath, 0755); err != nil {
+		return models.JobResultRequest{ExitCode: -1, ErrorMessage: fmt.Sprintf("failed to create output directory: %v", err)}
+	}
+
+	stdout, err := os.Create(filepath.Join(job.OutputPath, models.StdoutFile))
+	if err != nil {
+		return models.JobResultRequest{ExitCode: -1, ErrorMessage: fmt.Sprintf("failed to create stdout file: %v", err)}
+	}
+	defer stdout.Close()
+
+	stderr, err := os.Create(filepath.Join(job.OutputPath, models.StderrFile))
+	if err != nil {
+		return models.JobResultRequest{ExitCode: -1, ErrorMessage: fmt.Sprintf("failed to create stderr file: %v", err)}
+	}
+	defer stderr.Close()
+
+	result := runner.Run(ctx, runner.Spec{
+		Shell:       a.cfg.Shell,
+		Script:      job.Script,
+		Dir:         job.OutputPath,
+		Env:         jobEnv(job),
+		Stdout:      stdout,
+		Stderr:      stderr,
+		GracePeriod: a.cfg.GracePeriod,
+	})
+
+	errorMessage := ""
+	if result.Stopped {
+		errorMessage = a.stopReason(job.JobID)
+	} else if result.Err != nil {
+		errorMessage = result.Err.Error()
+	} else if result.ExitCode != 0 {
+		errorMessage = fmt.Sprintf("process exited with code %d", result.ExitCode)
+	}
+
+	return models.JobResultRequest{ExitCode: result.ExitCode, ErrorMessage: errorMessage}
+}
+
+// jobEnv returns the environment variables passed to the job's script
+func jobEnv(job models.AssignedJob) []string {
+	env := runner.JobEnv(job.JobID, job.CPUCores, job.MemoryGB, job.GPUCount, job.OutputPath)
+	if job.ArrayJobID != nil && job.ArrayIndex != nil {
+		env = append(env, runner.ArrayEnv(*job.ArrayJobID, *job.ArrayIndex)...)
+	}
+	return env
+}
+
+// report posts a job's result, retrying so a brief outage does not lose it
+func (a *Agent) report(jobID int, result models.JobResultRequest) {
+	for attempt := 1; attempt <= 5; attempt++ {
+		err := a.client.ReportResult(a.workerID, jobID, result)
+		if err == nil {
+			log.Printf("Job %d finished (exit code %d)", jobID, result.ExitCode)
+			return
+		}
+		if errors.Is(err, errJobNotAssigned) {
+			log.Printf("Job %d was requeued or reassigned, discarding its result", jobID)
+			return
+		}
+		log.Printf("Reporting job %d failed (attempt %d): %v", jobID, attempt, err)
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+	log.Printf("Giving up reporting job %d", jobID)
+}
+
+// stopJob asks a running job to stop, keeping the first reason given
+func (a *Agent) stopJob(jobID int, reason string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	job, ok := a.jobs[jobID]
+	if !ok {
+		return false
+	}
+	if job.stopReason == "" {
+		job.stopReason = reason
+	}
+	job.stop()
+	return true
+}
+
+// stopAll stops every running job
+func (a *Agent) stopAll(reason string) {
+	for _, jobID := range a.runningJobs() {
+		a.stopJob(jobID, reason)
+	}
+}
+
+// stopReason returns why a job was stopped
+func (a *Agent) stopReason(jobID int) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if job, ok := a.jobs[jobID]; ok {
+		return job.stopReason
+	}
+	return ""
+}
+
+// runningJobs returns the IDs of jobs currently running on this agent
+func (a *Agent) runningJobs() []int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ids := make([]int, 0, len(a.jobs))
+	for jobID := range a.jobs {
+		ids = append(ids, jobID)
+	}
+	sort.Ints(ids)
+	return ids
+}
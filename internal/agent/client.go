@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+// Errors returned by the worker API
+var (
+	errWorkerUnknown  = errors.New("worker is not registered")
+	errJobNotAssigned = errors.New("job is no longer assigned to this worker")
+)
+
+// Client talks to the scheduler's worker API
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// NewClient creates a client for the server at baseURL (e.g. http://localhost:8080)
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/") + "/api/workers",
+		token:   token,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Register registers the agent and returns its worker ID
+func (c *Client) Register(req models.RegisterWorkerRequest) (int, error) {
+	var resp models.RegisterWorkerResponse
+	if err := c.post("/register", req, &resp); err != nil {
+		return 0, err
+	}
+	return resp.WorkerID, nil
+}
+
+// Heartbeat reports running jobs and returns the ones to stop
+func (c *Client) Heartbeat(workerID int, runningJobs []int) ([]int, error) {
+	var resp models.HeartbeatResponse
+	req := models.HeartbeatRequest{RunningJobs: runningJobs}
+	if err := c.post(fmt.Sprintf("/%d/heartbeat", workerID), req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.StopJobs, nil
+}
+
+// Claim fetches jobs newly assigned to the worker
+func (c *Client) Claim(workerID int) ([]models.AssignedJob, error) {
+	var resp models.ClaimJobsResponse
+	if err := c.post(fmt.Sprintf("/%d/claim", workerID), struct{}{}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Jobs, nil
+}
+
+// ReportResult posts a finished job's exit status
+func (c *Client) ReportResult(workerID, jobID int, result models.JobResultRequest) error {
+	return c.post(fmt.Sprintf("/%d/jobs/%d/result", workerID, jobID), result, nil)
+}
+
+// post sends body as JSON and decodes the response into out (if not nil)
+func (c *Client) post(path string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Worker-Token", c.token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return errWorkerUnknown
+	}
+	if resp.StatusCode == http.StatusConflict {
+		return errJobNotAssigned
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		return fmt.Errorf("%s: %d %s", path, resp.StatusCode, apiErr.Error)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
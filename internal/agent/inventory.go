@@ -0,0 +1,53 @@
+package agent
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// DetectCPUCores returns the number of CPUs usable by this process
+func DetectCPUCores() int {
+	return runtime.NumCPU()
+}
+
+// DetectMemoryGB reads total memory from /proc/meminfo (0 if unavailable)
+func DetectMemoryGB() int {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return 0
+			}
+			return kb / (1024 * 1024)
+		}
+	}
+	return 0
+}
+
+// DetectGPUs counts NVIDIA GPUs via nvidia-smi (0 if it is not installed)
+func DetectGPUs() int {
+	out, err := exec.Command("nvidia-smi", "--list-gpus").Output()
+	if err != nil {
+		return 0
+	}
+
+	count := 0
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "GPU ") {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/samik-k21/research-compute-queue/internal/database"
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+type AdminHandler struct {
+	db *database.DB
+}
+
+func NewAdminHandler(db *database.DB) *AdminHandler {
+	return &AdminHandler{db: db}
+}
+
+// ListGroups returns all research groups with their limits
+func (h *AdminHandler) ListGroups(c *gin.Context) {
+	rows, err := h.db.Query(`
+		SELECT id, name, department_id, shares, cpu_quota, priority, max_walltime_hours,
+		       max_running_jobs, max_cpus, max_memory_gb, max_gpus, max_pending_jobs, created_at
+		FROM groups ORDER BY id
+	`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	groups := []models.Group{}
+	for rows.Next() {
+		var g models.Group
+		err := rows.Scan(&g.ID, &g.Name, &g.DepartmentID, &g.Shares, &g.CPUQuota, &g.Priority, &g.MaxWalltimeHours,
+			&g.Limits.MaxRunningJobs, &g.Limits.MaxCPUs, &g.Limits.MaxMemoryGB, &g.Limits.MaxGPUs,
+			&g.Limits.MaxPendingJobs, &g.CreatedAt)
+		if err != nil {
+			continue
+		}
+		groups = append(groups, g)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"groups": groups,
+		"count":  len(groups),
+	})
+}
+
+// SetGroupWalltime sets the maximum walltime jobs in a group may request
+func (h *AdminHandler) SetGroupWalltime(c *gin.Context) {
+	groupID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
+		return
+	}
+
+	var req models.SetWalltimeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.db.Exec(
+		"UPDATE groups SET max_walltime_hours = $1 WHERE id = $2",
+		req.MaxWalltimeHours, groupID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update group"})
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":            "Group walltime updated",
+		"group_id":           groupID,
+		"max_walltime_hours": req.MaxWalltimeHours,
+	})
+}
+
+// SetGroupLimits replaces the limits on a whole group's jobs
+func (h *AdminHandler) SetGroupLimits(c *gin.Context) {
+	h.setLimits(c, "groups", "Group")
+}
+
+// SetUserLimits replaces the limits on one user's jobs
+func (h *AdminHandler) SetUserLimits(c *gin.Context) {
+	h.setLimits(c, "users", "User")
+}
+
+// setLimits updates the limits of the group or user with the ID in the path
+func (h *AdminHandler) setLimits(c *gin.Context, table, kind string) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + strings.ToLower(kind) + " ID"})
+		return
+	}
+
+	var req models.Limits
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.db.Exec(`
+		UPDATE `+table+`
+		SET max_running_jobs = $1, max_cpus = $2, max_memory_gb = $3, max_gpus = $4, max_pending_jobs = $5
+		WHERE id = $6
+	`, req.MaxRunningJobs, req.MaxCPUs, req.MaxMemoryGB, req.MaxGPUs, req.MaxPendingJobs, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update " + strings.ToLower(kind)})
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": kind + " not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": kind + " limits updated",
+		"id":      id,
+		"limits":  req,
+	})
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+// maxArrayTasks limits the number of tasks one job array can create
+const maxArrayTasks = 10000
+
+var (
+	errInvalidJobID = errors.New("invalid job ID")
+	errJobNotFound  = errors.New("job not found")
+)
+
+// parseArraySpec parses a job array spec such as "0-499:1%20": comma-separated
+// indices or ranges ("start-end[:step]"), optionally followed by "%throttle",
+// the maximum number of tasks running at once. Returns sorted unique indices.
+func parseArraySpec(spec string) ([]int, int, error) {
+	ranges, throttle := spec, 0
+	if i := strings.Index(spec, "%"); i >= 0 {
+		ranges = spec[:i]
+		t, err := strconv.Atoi(spec[i+1:])
+		if err != nil || t < 1 {
+			return nil, 0, fmt.Errorf("invalid array throttle %q: must be a positive integer", spec[i+1:])
+		}
+		throttle = t
+	}
+
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(ranges, ",") {
+		part = strings.TrimSpace(part)
+
+		step := 1
+		if i := strings.Index(part, ":"); i >= 0 {
+			s, err := strconv.Atoi(part[i+1:])
+			if err != nil || s < 1 {
+				return nil, 0, fmt.Errorf("invalid array step in %q: must be a positive integer", part)
+			}
+			step = s
+			part = part[:i]
+		}
+
+		start, end := part, part
+		if i := strings.Index(part, "-"); i >= 0 {
+			start, end = part[:i], part[i+1:]
+		}
+		first, err1 := strconv.Atoi(start)
+		last, err2 := strconv.Atoi(end)
+		if err1 != nil || err2 != nil || first < 0 || last < first {
+			return nil, 0, fmt.Errorf("invalid array range %q: expected N or START-END with 0 <= START <= END", part)
+		}
+
+		for index := first; index <= last; index += step {
+			seen[index] = true
+			if len(seen) > maxArrayTasks {
+				return nil, 0, fmt.Errorf("job arrays are limited to %d tasks", maxArrayTasks)
+			}
+		}
+	}
+
+	indices := make([]int, 0, len(seen))
+	for index := range seen {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+	return indices, throttle, nil
+}
+
+// insertArrayTasks creates one task per index, copying the parent array's job spec
+func insertArrayTasks(tx *sql.Tx, arrayID int, indices []int) error {
+	_, err := tx.Exec(`
+		INSERT INTO jobs (user_id, group_id, partition_id, qos_id, script, cpu_cores, memory_gb, gpu_count,
+		                  estimated_hours, priority, status, submitted_at,
+		                  max_retries, retry_backoff, retry_delay_seconds, retry_exit_codes,
+		                  retry_on_node_failure, retry_on_timeout, preemptible, preempt_mode, array_job_id, array_index)
+		SELECT user_id, group_id, partition_id, qos_id, script, cpu_cores, memory_gb, gpu_count,
+		       estimated_hours, priority, status, submitted_at,
+		       max_retries, retry_backoff, retry_delay_seconds, retry_exit_codes,
+		       retry_on_node_failure, retry_on_timeout, preemptible, preempt_mode, id, idx
+		FROM jobs, unnest($2::int[]) AS idx
+		WHERE id = $1
+	`, arrayID, pq.Array(indices))
+	return err
+}
+
+// resolveJobID accepts a job ID ("123") or an array task ("123_7", task index 7
+// of array 123) and returns the job ID
+func (h *JobHandler) resolveJobID(param string) (int, error) {
+	arrayPart, indexPart, isTask := strings.Cut(param, "_")
+
+	arrayID, err := strconv.Atoi(arrayPart)
+	if err != nil {
+		return 0, errInvalidJobID
+	}
+	if !isTask {
+		return arrayID, nil
+	}
+
+	index, err := strconv.Atoi(indexPart)
+	if err != nil {
+		return 0, errInvalidJobID
+	}
+
+	var jobID int
+	err = h.db.QueryRow(
+		"SELECT id FROM jobs WHERE array_job_id = $1 AND array_index = $2", arrayID, index,
+	).Scan(&jobID)
+	if err == sql.ErrNoRows {
+		return 0, errJobNotFound
+	}
+	return jobID, err
+}
+
+// jobIDParam resolves the :id route parameter, writing the error response if it
+// is invalid or names an array task that does not exist
+func (h *JobHandler) jobIDParam(c *gin.Context) (int, bool) {
+	jobID, err := h.resolveJobID(c.Param("id"))
+	switch {
+	case err == errInvalidJobID:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return 0, false
+	case err == errJobNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return 0, false
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return 0, false
+	}
+	return jobID, true
+}
+
+// getArraySummary counts an array's tasks by status
+func (h *JobHandler) getArraySummary(arrayID int, spec string, throttle int) (*models.JobArray, error) {
+	rows, err := h.db.Query(
+		"SELECT status, COUNT(*) FROM jobs WHERE array_job_id = $1 GROUP BY status", arrayID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summary := &models.JobArray{Spec: spec, Throttle: throttle, Tasks: map[string]int{}}
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		summary.Tasks[status] = count
+		summary.TaskCount += count
+	}
+	return summary, rows.Err()
+}
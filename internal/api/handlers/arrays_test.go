@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseArraySpec(t *testing.T) {
+	tests := []struct {
+		spec     string
+		indices  []int
+		throttle int
+	}{
+		{"7", []int{7}, 0},
+		{"0-4", []int{0, 1, 2, 3, 4}, 0},
+		{"0-10:5", []int{0, 5, 10}, 0},
+		{"1-8:3", []int{1, 4, 7}, 0}, // The step runs past the end
+		{"0-3%2", []int{0, 1, 2, 3}, 2},
+		{"0-9:3%2", []int{0, 3, 6, 9}, 2},
+		{"1,3,5-7", []int{1, 3, 5, 6, 7}, 0},
+		{"5,1-3,2", []int{1, 2, 3, 5}, 0}, // Sorted, duplicates dropped
+		{" 1 , 2 ", []int{1, 2}, 0},
+	}
+	for _, tt := range tests {
+		indices, throttle, err := parseArraySpec(tt.spec)
+		if err != nil {
+			t.Errorf("%q: %v", tt.spec, err)
+			continue
+		}
+		if !slices.Equal(indices, tt.indices) || throttle != tt.throttle {
+			t.Errorf("%q: got %v throttled to %d, want %v throttled to %d",
+				tt.spec, indices, throttle, tt.indices, tt.throttle)
+		}
+	}
+
+	// The largest array allowed
+	indices, _, err := parseArraySpec("1-10000")
+	if err != nil || len(indices) != maxArrayTasks || indices[0] != 1 || indices[len(indices)-1] != 10000 {
+		t.Errorf("1-10000: got %d indices, %v", len(indices), err)
+	}
+}
+
+func TestParseArraySpecInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",        // Empty
+		"1,,2",    // Empty part
+		"-1",      // Negative
+		"5-1",     // Reversed range
+		"a-b",     // Not a number
+		"0-4:0",   // Zero step
+		"0-4:-1",  // Negative step
+		"0-4%0",   // Zero throttle
+		"0-4%x",   // Throttle not a number
+		"%2",      // Throttle only
+		"0-10000", // Too many tasks
+	} {
+		if indices, _, err := parseArraySpec(spec); err == nil {
+			t.Errorf("%q: got %v, expected an error", spec, indices)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+var errDependencyCycle = errors.New("dependencies would create a cycle")
+
+// parseDependencies parses Slurm-style dependency specs.
+// Accepted forms: "12" (afterok), "afterok:12", "afterany:12:13", "afternotok:14".
+func parseDependencies(specs []string) ([]models.JobDependency, error) {
+	seen := make(map[int]bool)
+	var deps []models.JobDependency
+
+	for _, spec := range specs {
+		parts := strings.Split(strings.TrimSpace(spec), ":")
+
+		depType := models.DependAfterOK
+		ids := parts
+		if _, err := strconv.Atoi(parts[0]); err != nil {
+			depType = strings.ToLower(parts[0])
+			ids = parts[1:]
+		}
+
+		if depType != models.DependAfterOK && depType != models.DependAfterAny && depType != models.DependAfterNotOK {
+			return nil, fmt.Errorf("invalid dependency type %q (use afterok, afterany or afternotok)", depType)
+		}
+		if len(ids) == 0 {
+			return nil, fmt.Errorf("dependency %q has no job IDs", spec)
+		}
+
+		for _, idStr := range ids {
+			id, err := strconv.Atoi(idStr)
+			if err != nil || id <= 0 {
+				return nil, fmt.Errorf("invalid job ID %q in dependency %q", idStr, spec)
+			}
+			if seen[id] {
+				return nil, fmt.Errorf("job %d is listed more than once in dependencies", id)
+			}
+			seen[id] = true
+			deps = append(deps, models.JobDependency{JobID: id, Type: depType})
+		}
+	}
+
+	return deps, nil
+}
+
+// dependencyParent is what a dependency is checked against: the parent job's group and status
+type dependencyParent struct {
+	groupID int
+	status  string
+}
+
+// validateDependencies checks that every parent job exists, belongs to the
+// caller's group and has not already finished in a way the dependency rules out
+func (h *JobHandler) validateDependencies(deps []models.JobDependency, groupID int) error {
+	if len(deps) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(deps))
+	for i, dep := range deps {
+		ids[i] = int64(dep.JobID)
+	}
+
+	rows, err := h.db.Query("SELECT id, group_id, status FROM jobs WHERE id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	parents := make(map[int]dependencyParent)
+	for rows.Next() {
+		var id int
+		var parent dependencyParent
+		if err := rows.Scan(&id, &parent.groupID, &parent.status); err != nil {
+			return err
+		}
+		parents[id] = parent
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return checkDependencyParents(deps, parents, groupID)
+}
+
+// checkDependencyParents checks each dependency against its parent job
+func checkDependencyParents(deps []models.JobDependency, parents map[int]dependencyParent, groupID int) error {
+	for _, dep := range deps {
+		parent, exists := parents[dep.JobID]
+		if !exists {
+			return fmt.Errorf("dependency job %d does not exist", dep.JobID)
+		}
+		if parent.groupID != groupID {
+			return fmt.Errorf("dependency job %d belongs to another group", dep.JobID)
+		}
+
+		never := false
+		switch dep.Type {
+		case models.DependAfterOK:
+			never = parent.status == models.StatusFailed || parent.status == models.StatusCancelled
+		case models.DependAfterNotOK:
+			never = parent.status == models.StatusCompleted
+		}
+		if never {
+			return fmt.Errorf("dependency job %d is %s, so %s can never be satisfied", dep.JobID, parent.status, dep.Type)
+		}
+	}
+
+	return nil
+}
+
+// insertDependencies stores a job's dependencies and rejects any that would form a cycle
+func insertDependencies(tx *sql.Tx, jobID int, deps []models.JobDependency) error {
+	for _, dep := range deps {
+		_, err := tx.Exec(`
+			INSERT INTO job_dependencies (job_id, depends_on_job_id, dependency_type)
+			VALUES ($1, $2, $3)
+		`, jobID, dep.JobID, dep.Type)
+		if err != nil {
+			return fmt.Errorf("failed to save dependency on job %d: %w", dep.JobID, err)
+		}
+	}
+
+	// Walk the ancestors of the job; reaching the job itself means a cycle
+	var hasCycle bool
+	err := tx.QueryRow(`
+		WITH RECURSIVE ancestors(id) AS (
+			SELECT depends_on_job_id FROM job_dependencies WHERE job_id = $1
+			UNION
+			SELECT d.depends_on_job_id
+			FROM job_dependencies d
+			JOIN ancestors a ON d.job_id = a.id
+		)
+		SELECT EXISTS(SELECT 1 FROM ancestors WHERE id = $1)
+	`, jobID).Scan(&hasCycle)
+	if err != nil {
+		return fmt.Errorf("failed to check dependency cycle: %w", err)
+	}
+	if hasCycle {
+		return errDependencyCycle
+	}
+
+	return nil
+}
+
+// getJobDependencies loads the parents a job waits for
+func (h *JobHandler) getJobDependencies(jobID int) ([]models.JobDependency, error) {
+	rows, err := h.db.Query(`
+		SELECT depends_on_job_id, dependency_type
+		FROM job_dependencies WHERE job_id = $1
+		ORDER BY depends_on_job_id
+	`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deps []models.JobDependency
+	for rows.Next() {
+		var dep models.JobDependency
+		if err := rows.Scan(&dep.JobID, &dep.Type); err != nil {
+			return nil, err
+		}
+		deps = append(deps, dep)
+	}
+	return deps, nil
+}
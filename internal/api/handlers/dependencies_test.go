@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+func TestParseDependencies(t *testing.T) {
+	deps, err := parseDependencies([]string{"12", "afterok:13:14", "AfterAny:15", " afternotok:16 "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.JobDependency{
+		{JobID: 12, Type: models.DependAfterOK},
+		{JobID: 13, Type: models.DependAfterOK},
+		{JobID: 14, Type: models.DependAfterOK},
+		{JobID: 15, Type: models.DependAfterAny},
+		{JobID: 16, Type: models.DependAfterNotOK},
+	}
+	if !slices.Equal(deps, want) {
+		t.Errorf("got %v, want %v", deps, want)
+	}
+
+	if deps, err := parseDependencies(nil); err != nil || deps != nil {
+		t.Errorf("no specs: got %v, %v", deps, err)
+	}
+}
+
+func TestParseDependenciesInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"before:12",     // Unknown type
+		"afterok",       // No job IDs
+		"afterok:",      // Empty job ID
+		"afterok:x",     // Not a number
+		"afterok:0",     // Not a job ID
+		"-3",            // Not a job ID
+		"afterok:12:12", // The same job twice
+	} {
+		if _, err := parseDependencies([]string{spec}); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+
+	if _, err := parseDependencies([]string{"12", "afterany:12"}); err == nil {
+		t.Error("the same job in two specs: expected an error")
+	}
+}
+
+func TestCheckDependencyParents(t *testing.T) {
+	parents := map[int]dependencyParent{
+		1: {groupID: 1, status: models.StatusPending},
+		2: {groupID: 1, status: models.StatusRunning},
+		3: {groupID: 1, status: models.StatusCompleted},
+		4: {groupID: 1, status: models.StatusFailed},
+		5: {groupID: 1, status: models.StatusCancelled},
+		6: {groupID: 2, status: models.StatusPending},
+	}
+
+	tests := []struct {
+		jobID   int
+		depType string
+		ok      bool
+	}{
+		{1, models.DependAfterOK, true},
+		{2, models.DependAfterOK, true},
+		{3, models.DependAfterOK, true},
+		{4, models.DependAfterOK, false},
+		{5, models.DependAfterOK, false},
+		{99, models.DependAfterOK, false}, // Unknown job
+		{6, models.DependAfterOK, false},  // Another group's job
+		{4, models.DependAfterAny, true},
+		{5, models.DependAfterAny, true},
+		{3, models.DependAfterNotOK, false},
+		{4, models.DependAfterNotOK, true},
+		{99, models.DependAfterNotOK, false},
+	}
+	for _, tt := range tests {
+		deps := []models.JobDependency{{JobID: tt.jobID, Type: tt.depType}}
+		err := checkDependencyParents(deps, parents, 1)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s on job %d: got error %v, want ok = %t", tt.depType, tt.jobID, err, tt.ok)
+		}
+	}
+}
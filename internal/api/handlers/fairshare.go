@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+
+	"github.com/samik-k21/research-compute-queue/internal/database"
+	"github.com/samik-k21/research-compute-queue/internal/models"
+	"github.com/samik-k21/research-compute-queue/internal/scheduler"
+)
+
+type FairShareHandler struct {
+	db        *database.DB
+	scheduler *scheduler.Scheduler
+}
+
+func NewFairShareHandler(db *database.DB, sched *scheduler.Scheduler) *FairShareHandler {
+	return &FairShareHandler{db: db, scheduler: sched}
+}
+
+// GetFairShare returns the fair-share tree with each node's shares, decayed
+// usage and level fair-share, and each user's fair-share factor
+func (h *FairShareHandler) GetFairShare(c *gin.Context) {
+	tree, err := h.scheduler.FairShareTree()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	c.JSON(http.StatusOK, tree)
+}
+
+// ListDepartments returns every department with its shares and groups
+func (h *FairShareHandler) ListDepartments(c *gin.Context) {
+	rows, err := h.db.Query(`
+		SELECT d.id, d.name, d.shares,
+		       ARRAY(SELECT id FROM groups WHERE department_id = d.id ORDER BY id)
+		FROM departments d
+		ORDER BY d.name
+	`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	departments := []models.Department{}
+	for rows.Next() {
+		var d models.Department
+		var groups pq.Int64Array
+		if err := rows.Scan(&d.ID, &d.Name, &d.Shares, &groups); err != nil {
+			continue
+		}
+		d.Groups = toInts(groups)
+		departments = append(departments, d)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"departments": departments,
+		"count":       len(departments),
+	})
+}
+
+// CreateDepartment adds a department
+func (h *FairShareHandler) CreateDepartment(c *gin.Context) {
+	var req models.DepartmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var departmentID int
+	err := h.db.QueryRow(
+		"INSERT INTO departments (name, shares) VALUES ($1, $2) RETURNING id", req.Name, req.Shares,
+	).Scan(&departmentID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("department %q already exists", req.Name)})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save department"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":       "Department created",
+		"department_id": departmentID,
+		"name":          req.Name,
+	})
+}
+
+// UpdateDepartment renames a department or changes its shares
+func (h *FairShareHandler) UpdateDepartment(c *gin.Context) {
+	var req models.DepartmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var departmentID int
+	err := h.db.QueryRow(
+		"UPDATE departments SET name = $1, shares = $2 WHERE name = $3 RETURNING id",
+		req.Name, req.Shares, c.Param("name"),
+	).Scan(&departmentID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Department not found"})
+		return
+	}
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("department %q already exists", req.Name)})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save department"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Department saved",
+		"department_id": departmentID,
+		"name":          req.Name,
+	})
+}
+
+// SetGroupShares sets a group's shares and the department it belongs to
+func (h *FairShareHandler) SetGroupShares(c *gin.Context) {
+	groupID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
+		return
+	}
+
+	var req models.SetGroupSharesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var departmentID sql.NullInt64
+	if req.Department != nil {
+		err := h.db.QueryRow("SELECT id FROM departments WHERE name = $1", *req.Department).Scan(&departmentID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown department"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+	}
+
+	result, err := h.db.Exec(
+		"UPDATE groups SET shares = $1, department_id = $2 WHERE id = $3",
+		req.Shares, departmentID, groupID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update group"})
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Group shares updated",
+		"group_id":   groupID,
+		"shares":     req.Shares,
+		"department": req.Department,
+	})
+}
+
+// SetUserShares sets a user's shares within their group
+func (h *FairShareHandler) SetUserShares(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req models.SetSharesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.db.Exec("UPDATE users SET shares = $1 WHERE id = $2", req.Shares, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User shares updated",
+		"user_id": userID,
+		"shares":  req.Shares,
+	})
+}
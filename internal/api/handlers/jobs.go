@@ -1,22 +1,27 @@
 package handlers
 
 import (
+	"database/sql"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 
 	"github.com/samik-k21/research-compute-queue/internal/database"
 	"github.com/samik-k21/research-compute-queue/internal/models"
+	"github.com/samik-k21/research-compute-queue/internal/scheduler"
 )
 
 type JobHandler struct {
-	db *database.DB
+	db        *database.DB
+	scheduler *scheduler.Scheduler
 }
 
-func NewJobHandler(db *database.DB) *JobHandler {
-	return &JobHandler{db: db}
+func NewJobHandler(db *database.DB, sched *scheduler.Scheduler) *JobHandler {
+	return &JobHandler{db: db, scheduler: sched}
 }
 
 // SubmitJob creates a new job
@@ -39,62 +44,294 @@ func (h *JobHandler) SubmitJob(c *gin.Context) {
 	groupIDInterface, _ := c.Get("group_id")
 	groupID := groupIDInterface.(int)
 
-	// Insert job
+	// Parse the job array spec; the array itself is never run, its tasks are
+	var arrayIndices []int
+	var arrayThrottle int
+	var arraySpec interface{}
+	if req.Array != "" {
+		var err error
+		arrayIndices, arrayThrottle, err = parseArraySpec(req.Array)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		arraySpec = req.Array
+	}
+
+	// Parse and validate dependencies
+	deps, err := parseDependencies(req.Dependencies)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.validateDependencies(deps, groupID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Pick the partition: the requested one, else the group's or the system default
+	partition, err := resolvePartition(h.db, groupID, req.Partition)
+	switch {
+	case err == errPartitionNotFound || err == errNoDefaultPartition:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err == errPartitionNotAllowed:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	// Pick the QoS: the requested one (if the group was granted it) or the default
+	qos, err := resolveQoS(h.db, groupID, req.QoS)
+	switch {
+	case err == errQoSNotFound || err == errNoDefaultQoS:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err == errQoSNotAllowed:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	// Validate walltime against the group's, the partition's and the QoS's
+	// maximum; jobs without an estimate get the lowest maximum
+	var maxWalltime sql.NullFloat64
+	err = h.db.QueryRow("SELECT max_walltime_hours FROM groups WHERE id=$1", groupID).Scan(&maxWalltime)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	limitOwner := "your group's"
+	if partition.maxWalltime.Valid && (!maxWalltime.Valid || partition.maxWalltime.Float64 < maxWalltime.Float64) {
+		maxWalltime = partition.maxWalltime
+		limitOwner = fmt.Sprintf("partition %s's", partition.name)
+	}
+	if qos.maxWalltime.Valid && (!maxWalltime.Valid || qos.maxWalltime.Float64 < maxWalltime.Float64) {
+		maxWalltime = qos.maxWalltime
+		limitOwner = fmt.Sprintf("QoS %s's", qos.name)
+	}
+	if maxWalltime.Valid {
+		if req.EstimatedHours > maxWalltime.Float64 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("estimated_hours %.2f exceeds %s maximum walltime of %.2f hours",
+					req.EstimatedHours, limitOwner, maxWalltime.Float64),
+			})
+			return
+		}
+		if req.EstimatedHours == 0 {
+			req.EstimatedHours = maxWalltime.Float64
+		}
+	}
+
+	// Retry policy defaults: a fixed delay, retrying any failure
+	retry := models.RetryPolicy{Backoff: models.RetryFixed, DelaySeconds: 60}
+	if req.RetryPolicy != nil {
+		retry = *req.RetryPolicy
+		if retry.Backoff == "" {
+			retry.Backoff = models.RetryFixed
+		}
+	}
+	var retryExitCodes interface{}
+	if len(retry.ExitCodes) > 0 {
+		retryExitCodes = pq.Array(retry.ExitCodes)
+	}
+
+	// Preempted jobs are requeued unless they ask to be cancelled. Some QoS
+	// classes (e.g. scavenger) make every job preemptible.
+	if req.PreemptMode == "" {
+		req.PreemptMode = models.PreemptRequeue
+	}
+	preemptible := req.Preemptible || qos.preemptible
+
+	// Insert job and its dependencies together
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create job"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Enforce the user's and the group's limits; array tasks count as jobs
+	count := 1
+	if arrayIndices != nil {
+		count = len(arrayIndices)
+	}
+	if err := checkSubmitLimits(tx, userID, groupID, &req, count); err != nil {
+		if limitErr, ok := err.(*limitError); ok {
+			c.JSON(limitErr.status, gin.H{"error": limitErr.message})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
 	var jobID int
-	err := h.db.QueryRow(`
+	err = tx.QueryRow(`
 		INSERT INTO jobs (user_id, group_id, script, cpu_cores, memory_gb, gpu_count, 
-		                  estimated_hours, priority, status, submitted_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		                  estimated_hours, priority, status, submitted_at,
+		                  max_retries, retry_backoff, retry_delay_seconds, retry_exit_codes,
+		                  retry_on_node_failure, retry_on_timeout, is_array, array_spec, array_throttle,
+		                  preemptible, preempt_mode, partition_id, qos_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)
 		RETURNING id
 	`, userID, groupID, req.Script, req.CPUCores, req.MemoryGB, req.GPUCount,
-		req.EstimatedHours, req.Priority, models.StatusPending, time.Now()).Scan(&jobID)
+		req.EstimatedHours, req.Priority, models.StatusPending, time.Now(),
+		req.MaxRetries, retry.Backoff, retry.DelaySeconds, retryExitCodes,
+		retry.NodeFailure, retry.Timeout, arraySpec != nil, arraySpec, arrayThrottle,
+		preemptible, req.PreemptMode, partition.id, qos.id).Scan(&jobID)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create job"})
 		return
 	}
 
+	if err := insertDependencies(tx, jobID, deps); err != nil {
+		if err == errDependencyCycle {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save dependencies"})
+		return
+	}
+
+	// Array tasks share the array's dependencies
+	if arrayIndices != nil {
+		if err := insertArrayTasks(tx, jobID, arrayIndices); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create array tasks"})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create job"})
+		return
+	}
+
+	// Wake the scheduler now that the job is committed
+	database.Notify(h.db, database.EventJobSubmitted, jobID)
+
+	if arrayIndices != nil {
+		c.JSON(http.StatusCreated, gin.H{
+			"message":    "Job array submitted successfully",
+			"job_id":     jobID,
+			"partition":  partition.name,
+			"qos":        qos.name,
+			"task_count": len(arrayIndices),
+			"throttle":   arrayThrottle,
+			"status":     models.StatusPending,
+		})
+		return
+	}
+
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "Job submitted successfully",
-		"job_id":  jobID,
-		"status":  models.StatusPending,
+		"message":   "Job submitted successfully",
+		"job_id":    jobID,
+		"partition": partition.name,
+		"qos":       qos.name,
+		"status":    models.StatusPending,
 	})
 }
 
 // GetJob retrieves a job by ID
 func (h *JobHandler) GetJob(c *gin.Context) {
-	jobIDStr := c.Param("id")
-	jobID, err := strconv.Atoi(jobIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+	jobID, ok := h.jobIDParam(c)
+	if !ok {
 		return
 	}
 
 	var job models.Job
-	err = h.db.QueryRow(`
-		SELECT id, user_id, group_id, script, cpu_cores, memory_gb, gpu_count,
-		       estimated_hours, status, priority, submitted_at, started_at, 
-		       completed_at, exit_code, output_path, error_message, worker_id
-		FROM jobs WHERE id=$1
+	var retry models.RetryPolicy
+	var retryExitCodes pq.Int64Array
+	var isArray bool
+	var arraySpec string
+	var arrayThrottle int
+	err := h.db.QueryRow(`
+		SELECT j.id, j.user_id, j.group_id, COALESCE(p.name, ''), COALESCE(q.name, ''),
+		       j.script, j.cpu_cores, j.memory_gb, j.gpu_count,
+		       COALESCE(j.estimated_hours, 0), j.status, j.priority, j.submitted_at, j.started_at, 
+		       j.completed_at, j.exit_code, COALESCE(j.output_path, ''), COALESCE(j.error_message, ''), j.worker_id,
+		       j.attempt, j.max_retries, j.retry_count, j.retry_backoff, j.retry_delay_seconds,
+		       j.retry_exit_codes, j.retry_on_node_failure, j.retry_on_timeout, j.eligible_at,
+		       j.array_job_id, j.array_index, j.is_array, COALESCE(j.array_spec, ''), j.array_throttle,
+		       j.preemptible, j.preempt_mode, CASE WHEN j.status = 'pending' THEN COALESCE(j.pending_reason, '') ELSE '' END,
+		       CASE WHEN j.status = 'pending' THEN COALESCE(j.pending_detail, '') ELSE '' END,
+		       CASE WHEN j.status = 'pending' THEN j.pending_reason_at END,
+		       CASE WHEN j.status = 'pending' THEN j.expected_start END,
+		       CASE WHEN j.status = 'pending' THEN j.expected_worker_id END
+		FROM jobs j
+		LEFT JOIN partitions p ON p.id = j.partition_id
+		LEFT JOIN qos q ON q.id = j.qos_id
+		WHERE j.id=$1
 	`, jobID).Scan(
-		&job.ID, &job.UserID, &job.GroupID, &job.Script, &job.CPUCores,
+		&job.ID, &job.UserID, &job.GroupID, &job.Partition, &job.QoS, &job.Script, &job.CPUCores,
 		&job.MemoryGB, &job.GPUCount, &job.EstimatedHours, &job.Status,
 		&job.Priority, &job.SubmittedAt, &job.StartedAt, &job.CompletedAt,
 		&job.ExitCode, &job.OutputPath, &job.ErrorMessage, &job.WorkerID,
+		&job.Attempt, &job.MaxRetries, &job.RetryCount, &retry.Backoff, &retry.DelaySeconds,
+		&retryExitCodes, &retry.NodeFailure, &retry.Timeout, &job.EligibleAt,
+		&job.ArrayJobID, &job.ArrayIndex, &isArray, &arraySpec, &arrayThrottle,
+		&job.Preemptible, &job.PreemptMode, &job.PendingReason, &job.PendingDetail, &job.EvaluatedAt,
+		&job.ExpectedStart, &job.ExpectedWorker,
 	)
 
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
 		return
 	}
+	if !canAccessJob(c, job.UserID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this job"})
+		return
+	}
+
+	job.Dependencies, err = h.getJobDependencies(jobID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if job.MaxRetries > 0 {
+		for _, code := range retryExitCodes {
+			retry.ExitCodes = append(retry.ExitCodes, int(code))
+		}
+		job.RetryPolicy = &retry
+	}
+
+	job.Attempts, err = h.getJobAttempts(jobID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	job.Preemptions, err = h.getJobPreemptions(jobID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if isArray {
+		job.Array, err = h.getArraySummary(jobID, arraySpec, arrayThrottle)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+	}
 
 	c.JSON(http.StatusOK, job)
 }
 
 // ListJobs retrieves all jobs for a user
 func (h *JobHandler) ListJobs(c *gin.Context) {
-	// TODO: Get user ID from JWT
-	userID := 1
+	// Get user ID from JWT (stored in context by middleware)
+	userIDInterface, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	userID := userIDInterface.(int)
 
 	// Optional filters
 	status := c.Query("status")
@@ -103,18 +340,54 @@ func (h *JobHandler) ListJobs(c *gin.Context) {
 
 	// Build query
 	query := `
-		SELECT id, user_id, group_id, script, cpu_cores, memory_gb, gpu_count,
-		       status, priority, submitted_at, started_at, completed_at
-		FROM jobs WHERE user_id=$1
+		SELECT j.id, j.user_id, j.group_id, COALESCE(p.name, ''), COALESCE(q.name, ''), j.script,
+		       j.cpu_cores, j.memory_gb, j.gpu_count, j.status, j.priority, j.submitted_at,
+		       j.started_at, j.completed_at, j.array_job_id, j.array_index,
+		       CASE WHEN j.status = 'pending' THEN COALESCE(j.pending_reason, '') ELSE '' END,
+		       CASE WHEN j.status = 'pending' THEN COALESCE(j.pending_detail, '') ELSE '' END,
+		       CASE WHEN j.status = 'pending' THEN j.pending_reason_at END
+		FROM jobs j
+		LEFT JOIN partitions p ON p.id = j.partition_id
+		LEFT JOIN qos q ON q.id = j.qos_id
+		WHERE j.user_id=$1
 	`
 	args := []interface{}{userID}
 
 	if status != "" {
-		query += " AND status=$2"
 		args = append(args, status)
+		query += " AND j.status=$" + strconv.Itoa(len(args))
+	}
+
+	if partition := c.Query("partition"); partition != "" {
+		args = append(args, partition)
+		query += " AND p.name=$" + strconv.Itoa(len(args))
 	}
 
-	query += " ORDER BY submitted_at DESC LIMIT $" + strconv.Itoa(len(args)+1)
+	if qos := c.Query("qos"); qos != "" {
+		args = append(args, qos)
+		query += " AND q.name=$" + strconv.Itoa(len(args))
+	}
+
+	// Pending jobs the last scheduling cycle held for a reason, e.g. Resources
+	if reason := c.Query("pending_reason"); reason != "" {
+		args = append(args, reason)
+		query += " AND j.status='pending' AND j.pending_reason=$" + strconv.Itoa(len(args))
+	}
+
+	// Array tasks are listed through their array unless array_id is given
+	if arrayID := c.Query("array_id"); arrayID != "" {
+		id, err := strconv.Atoi(arrayID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid array_id"})
+			return
+		}
+		args = append(args, id)
+		query += " AND j.array_job_id=$" + strconv.Itoa(len(args))
+	} else {
+		query += " AND j.array_job_id IS NULL"
+	}
+
+	query += " ORDER BY j.submitted_at DESC LIMIT $" + strconv.Itoa(len(args)+1)
 	args = append(args, limit)
 
 	rows, err := h.db.Query(query, args...)
@@ -128,9 +401,10 @@ func (h *JobHandler) ListJobs(c *gin.Context) {
 	for rows.Next() {
 		var job models.Job
 		err := rows.Scan(
-			&job.ID, &job.UserID, &job.GroupID, &job.Script, &job.CPUCores,
+			&job.ID, &job.UserID, &job.GroupID, &job.Partition, &job.QoS, &job.Script, &job.CPUCores,
 			&job.MemoryGB, &job.GPUCount, &job.Status, &job.Priority,
 			&job.SubmittedAt, &job.StartedAt, &job.CompletedAt,
+			&job.ArrayJobID, &job.ArrayIndex, &job.PendingReason, &job.PendingDetail, &job.EvaluatedAt,
 		)
 		if err != nil {
 			continue
@@ -144,37 +418,161 @@ func (h *JobHandler) ListJobs(c *gin.Context) {
 	})
 }
 
-// CancelJob cancels a pending or running job
+// CancelJob cancels a pending or running job. Cancelling a job array cancels
+// all of its unfinished tasks; a single task is cancelled by its ID or "array_index".
 func (h *JobHandler) CancelJob(c *gin.Context) {
-	jobIDStr := c.Param("id")
-	jobID, err := strconv.Atoi(jobIDStr)
+	jobID, ok := h.jobIDParam(c)
+	if !ok {
+		return
+	}
+
+	var ownerID int
+	var isArray bool
+	err := h.db.QueryRow("SELECT user_id, is_array FROM jobs WHERE id=$1", jobID).Scan(&ownerID, &isArray)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if !canAccessJob(c, ownerID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this job"})
 		return
 	}
 
-	// TODO: Verify user owns this job
+	if isArray {
+		h.cancelArray(c, jobID)
+		return
+	}
 
-	// Update job status
-	result, err := h.db.Exec(`
+	// Update job status; worker_id is only set once the job has started running
+	var workerID sql.NullInt64
+	err = h.db.QueryRow(`
 		UPDATE jobs 
-		SET status=$1, completed_at=$2 
-		WHERE id=$3 AND status IN ($4, $5)
-	`, models.StatusCancelled, time.Now(), jobID, models.StatusPending, models.StatusRunning)
+		SET status=$1, completed_at=$2, error_message=$3
+		WHERE id=$4 AND status IN ($5, $6)
+		RETURNING worker_id
+	`, models.StatusCancelled, time.Now(), "Cancelled by user", jobID,
+		models.StatusPending, models.StatusRunning).Scan(&workerID)
 
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusConflict, gin.H{"error": "Job already finished"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel job"})
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found or already completed"})
-		return
+	// Stop the running process; the executor frees the worker and logs usage once it exits
+	if workerID.Valid {
+		h.scheduler.CancelJob(jobID, "Cancelled by user")
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Job cancelled successfully",
 		"job_id":  jobID,
 	})
+}
+
+// cancelArray cancels every unfinished task of a job array and the array itself
+func (h *JobHandler) cancelArray(c *gin.Context, arrayID int) {
+	rows, err := h.db.Query(`
+		UPDATE jobs
+		SET status=$1, completed_at=$2, error_message=$3
+		WHERE (id=$4 OR array_job_id=$4) AND status IN ($5, $6)
+		RETURNING id, worker_id
+	`, models.StatusCancelled, time.Now(), "Cancelled by user", arrayID,
+		models.StatusPending, models.StatusRunning)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel job array"})
+		return
+	}
+
+	// Collect running tasks first; stopping them needs the rows closed
+	var running []int
+	cancelled := 0
+	for rows.Next() {
+		var jobID int
+		var workerID sql.NullInt64
+		if err := rows.Scan(&jobID, &workerID); err != nil {
+			continue
+		}
+		if jobID != arrayID {
+			cancelled++
+		}
+		if workerID.Valid {
+			running = append(running, jobID)
+		}
+	}
+	rows.Close()
+
+	if cancelled == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Job array already finished"})
+		return
+	}
+
+	for _, jobID := range running {
+		h.scheduler.CancelJob(jobID, "Cancelled by user")
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "Job array cancelled successfully",
+		"job_id":          arrayID,
+		"tasks_cancelled": cancelled,
+	})
+}
+
+// getJobAttempts returns the history of a job's finished runs, oldest first
+func (h *JobHandler) getJobAttempts(jobID int) ([]models.JobAttempt, error) {
+	rows, err := h.db.Query(`
+		SELECT attempt, worker_id, started_at, ended_at, exit_code, status,
+		       COALESCE(error_message, '')
+		FROM job_attempts
+		WHERE job_id = $1
+		ORDER BY id
+	`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []models.JobAttempt{}
+	for rows.Next() {
+		var a models.JobAttempt
+		err := rows.Scan(&a.Attempt, &a.WorkerID, &a.StartedAt, &a.EndedAt, &a.ExitCode,
+			&a.Status, &a.ErrorMessage)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
+
+// getJobPreemptions returns the times a job was stopped for a higher-priority job, oldest first
+func (h *JobHandler) getJobPreemptions(jobID int) ([]models.Preemption, error) {
+	rows, err := h.db.Query(`
+		SELECT preemptor_job_id, worker_id, action, COALESCE(reason, ''), preempted_at
+		FROM preemption_events
+		WHERE job_id = $1
+		ORDER BY id
+	`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	preemptions := []models.Preemption{}
+	for rows.Next() {
+		var p models.Preemption
+		err := rows.Scan(&p.PreemptorJobID, &p.WorkerID, &p.Action, &p.Reason, &p.PreemptedAt)
+		if err != nil {
+			return nil, err
+		}
+		preemptions = append(preemptions, p)
+	}
+	return preemptions, rows.Err()
 }
\ No newline at end of file
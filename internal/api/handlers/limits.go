@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+// Advisory lock classes that serialise submissions per group and per user;
+// the second key is the group's or the user's ID
+const (
+	submitLockGroup int32 = 0x52435147 // "RCQG"
+	submitLockUser  int32 = 0x52435155 // "RCQU"
+)
+
+// limitError rejects a submission that breaks a user or group limit
+type limitError struct {
+	status  int
+	message string
+}
+
+func (e *limitError) Error() string {
+	return e.message
+}
+
+// checkSubmitLimits rejects submitting count jobs (array tasks count one each)
+// that could never run under the user's or the group's running limits, or
+// that would take them past their pending limit. The group's and the user's
+// submissions are serialised until tx ends, so concurrent ones cannot slip
+// past the pending limit together; the limits are read after the locks.
+func checkSubmitLimits(tx *sql.Tx, userID, groupID int, req *models.CreateJobRequest, count int) error {
+	accounts := []struct {
+		table, column, owner string
+		lockClass            int32
+		id                   int
+	}{
+		{"groups", "group_id", "your group's", submitLockGroup, groupID},
+		{"users", "user_id", "your", submitLockUser, userID},
+	}
+
+	// Always group then user, so submissions cannot deadlock
+	for _, account := range accounts {
+		if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1, $2)", account.lockClass, account.id); err != nil {
+			return err
+		}
+	}
+
+	for _, account := range accounts {
+		var l models.Limits
+		err := tx.QueryRow(`
+			SELECT max_cpus, max_memory_gb, max_gpus, max_pending_jobs
+			FROM `+account.table+` WHERE id = $1
+		`, account.id).Scan(&l.MaxCPUs, &l.MaxMemoryGB, &l.MaxGPUs, &l.MaxPendingJobs)
+		if err != nil {
+			return err
+		}
+
+		if err := checkRunningFit(&l, req, account.owner); err != nil {
+			return err
+		}
+
+		if l.MaxPendingJobs == nil {
+			continue
+		}
+		var pending int
+		err = tx.QueryRow(`
+			SELECT COUNT(*) FROM jobs
+			WHERE `+account.column+` = $1 AND status = 'pending' AND NOT is_array
+		`, account.id).Scan(&pending)
+		if err != nil {
+			return err
+		}
+		if err := checkPendingLimit(&l, pending, count, account.owner); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRunningFit rejects a job bigger than one of the running limits in l,
+// which would hold it forever
+func checkRunningFit(l *models.Limits, req *models.CreateJobRequest, owner string) error {
+	for _, r := range []struct {
+		name      string
+		requested int
+		max       *int
+	}{
+		{"cpu_cores", req.CPUCores, l.MaxCPUs},
+		{"memory_gb", req.MemoryGB, l.MaxMemoryGB},
+		{"gpu_count", req.GPUCount, l.MaxGPUs},
+	} {
+		if r.max != nil && r.requested > *r.max {
+			return &limitError{http.StatusBadRequest, fmt.Sprintf(
+				"%s %d exceeds %s limit of %d for running jobs", r.name, r.requested, owner, *r.max)}
+		}
+	}
+	return nil
+}
+
+// checkPendingLimit rejects submitting count more jobs on top of pending ones
+// if that goes past the pending limit in l
+func checkPendingLimit(l *models.Limits, pending, count int, owner string) error {
+	if l.MaxPendingJobs != nil && pending+count > *l.MaxPendingJobs {
+		return &limitError{http.StatusTooManyRequests, fmt.Sprintf(
+			"submitting %d job(s) would exceed %s limit of %d pending jobs (%d pending now)",
+			count, owner, *l.MaxPendingJobs, pending)}
+	}
+	return nil
+}
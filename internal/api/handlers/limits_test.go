@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+func TestCheckRunningFit(t *testing.T) {
+	eight, sixtyFour, one := 8, 64, 1
+	limits := &models.Limits{MaxCPUs: &eight, MaxMemoryGB: &sixtyFour, MaxGPUs: &one}
+
+	tests := []struct {
+		cpu, mem, gpu int
+		ok            bool
+	}{
+		{8, 64, 1, true}, // Exactly the limits
+		{9, 1, 0, false},
+		{1, 65, 0, false},
+		{1, 1, 2, false},
+	}
+	for _, tt := range tests {
+		req := &models.CreateJobRequest{CPUCores: tt.cpu, MemoryGB: tt.mem, GPUCount: tt.gpu}
+		err := checkRunningFit(limits, req, "your")
+		if (err == nil) != tt.ok {
+			t.Errorf("%d CPU, %d GB, %d GPU: got %v, want ok = %t", tt.cpu, tt.mem, tt.gpu, err, tt.ok)
+		}
+		if limitErr, isLimit := err.(*limitError); err != nil && (!isLimit || limitErr.status != http.StatusBadRequest) {
+			t.Errorf("%d CPU, %d GB, %d GPU: got %#v, want a 400 limitError", tt.cpu, tt.mem, tt.gpu, err)
+		}
+	}
+
+	// No limits, no rejection
+	req := &models.CreateJobRequest{CPUCores: 1000, MemoryGB: 1000, GPUCount: 100}
+	if err := checkRunningFit(&models.Limits{}, req, "your"); err != nil {
+		t.Errorf("no limits: got %v", err)
+	}
+}
+
+func TestCheckPendingLimit(t *testing.T) {
+	ten := 10
+	limits := &models.Limits{MaxPendingJobs: &ten}
+
+	tests := []struct {
+		pending, count int
+		ok             bool
+	}{
+		{0, 1, true},
+		{9, 1, true},
+		{10, 1, false},
+		{0, 10, true},  // An array filling the limit
+		{0, 11, false}, // Every array task counts
+		{5, 6, false},
+	}
+	for _, tt := range tests {
+		err := checkPendingLimit(limits, tt.pending, tt.count, "your")
+		if (err == nil) != tt.ok {
+			t.Errorf("%d pending, %d more: got %v, want ok = %t", tt.pending, tt.count, err, tt.ok)
+		}
+		if limitErr, isLimit := err.(*limitError); err != nil && (!isLimit || limitErr.status != http.StatusTooManyRequests) {
+			t.Errorf("%d pending, %d more: got %#v, want a 429 limitError", tt.pending, tt.count, err)
+		}
+	}
+
+	if err := checkPendingLimit(&models.Limits{}, 1000, 1000, "your"); err != nil {
+		t.Errorf("no limit: got %v", err)
+	}
+}
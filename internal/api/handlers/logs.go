@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+const (
+	logPollInterval = 500 * time.Millisecond
+	logChunkSize    = 64 << 10 // Max bytes sent per SSE event
+)
+
+// LogEvent is the payload of a stdout/stderr SSE event
+type LogEvent struct {
+	Stream  string `json:"stream"`
+	Offset  int64  `json:"offset"`
+	Content string `json:"content"`
+}
+
+// StreamJobLogs streams a job's stdout/stderr as Server-Sent Events.
+// Each event ID is "<stdout_offset>:<stderr_offset>" so a reconnecting client
+// resumes where it left off via the Last-Event-ID header.
+func (h *JobHandler) StreamJobLogs(c *gin.Context) {
+	jobID, ok := h.jobIDParam(c)
+	if !ok {
+		return
+	}
+
+	follow := c.DefaultQuery("follow", "false") == "true"
+
+	stdoutOffset, stderrOffset, err := parseLogOffsets(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var ownerID int
+	var status string
+	var outputPath sql.NullString
+	err = h.db.QueryRow(
+		"SELECT user_id, status, output_path FROM jobs WHERE id = $1", jobID,
+	).Scan(&ownerID, &status, &outputPath)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if !canAccessJob(c, ownerID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this job"})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Disable proxy buffering (nginx)
+
+	ticker := time.NewTicker(logPollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Send everything written since the last poll
+		if outputPath.Valid && outputPath.String != "" {
+			stdoutOffset, err = h.sendLogChunks(c, "stdout", filepath.Join(outputPath.String, models.StdoutFile), stdoutOffset, stderrOffset, true)
+			if err != nil {
+				return
+			}
+			stderrOffset, err = h.sendLogChunks(c, "stderr", filepath.Join(outputPath.String, models.StderrFile), stderrOffset, stdoutOffset, false)
+			if err != nil {
+				return
+			}
+		}
+
+		// Terminal jobs produce no more output; status is re-checked after the
+		// final drain above so nothing written before completion is lost
+		if isTerminalStatus(status) || !follow {
+			h.sendEndEvent(c, jobID, status, stdoutOffset, stderrOffset)
+			return
+		}
+
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-ticker.C:
+		}
+
+		err = h.db.QueryRow(
+			"SELECT status, output_path FROM jobs WHERE id = $1", jobID,
+		).Scan(&status, &outputPath)
+		if err != nil {
+			sse.Encode(c.Writer, sse.Event{Event: "error", Data: gin.H{"error": "Failed to read job status"}})
+			c.Writer.Flush()
+			return
+		}
+	}
+}
+
+// sendLogChunks sends any bytes after offset as SSE events and returns the new offset
+func (h *JobHandler) sendLogChunks(c *gin.Context, stream, path string, offset, otherOffset int64, isStdout bool) (int64, error) {
+	for {
+		chunk, err := readOutputChunk(path, offset, logChunkSize, 0)
+		if err != nil {
+			return offset, err
+		}
+		if chunk.NextOffset == offset {
+			return offset, nil
+		}
+
+		id := fmt.Sprintf("%d:%d", chunk.NextOffset, otherOffset)
+		if !isStdout {
+			id = fmt.Sprintf("%d:%d", otherOffset, chunk.NextOffset)
+		}
+
+		err = sse.Encode(c.Writer, sse.Event{
+			Id:    id,
+			Event: stream,
+			Data:  LogEvent{Stream: stream, Offset: chunk.Offset, Content: chunk.Content},
+		})
+		if err != nil {
+			return offset, err
+		}
+		c.Writer.Flush()
+
+		offset = chunk.NextOffset
+		if !chunk.Truncated {
+			return offset, nil
+		}
+	}
+}
+
+// sendEndEvent tells the client the stream is finished
+func (h *JobHandler) sendEndEvent(c *gin.Context, jobID int, status string, stdoutOffset, stderrOffset int64) {
+	sse.Encode(c.Writer, sse.Event{
+		Id:    fmt.Sprintf("%d:%d", stdoutOffset, stderrOffset),
+		Event: "end",
+		Data:  gin.H{"job_id": jobID, "status": status},
+	})
+	c.Writer.Flush()
+}
+
+// parseLogOffsets reads resume offsets from Last-Event-ID or the stdout_offset/stderr_offset query params
+func parseLogOffsets(c *gin.Context) (int64, int64, error) {
+	if lastID := c.GetHeader("Last-Event-ID"); lastID != "" {
+		parts := strings.SplitN(lastID, ":", 2)
+		if len(parts) == 2 {
+			stdoutOffset, err1 := strconv.ParseInt(parts[0], 10, 64)
+			stderrOffset, err2 := strconv.ParseInt(parts[1], 10, 64)
+			if err1 == nil && err2 == nil && stdoutOffset >= 0 && stderrOffset >= 0 {
+				return stdoutOffset, stderrOffset, nil
+			}
+		}
+		return 0, 0, fmt.Errorf("invalid Last-Event-ID %q, expected <stdout_offset>:<stderr_offset>", lastID)
+	}
+
+	stdoutOffset, err := strconv.ParseInt(c.DefaultQuery("stdout_offset", "0"), 10, 64)
+	if err != nil || stdoutOffset < 0 {
+		return 0, 0, fmt.Errorf("stdout_offset must be a non-negative integer")
+	}
+	stderrOffset, err := strconv.ParseInt(c.DefaultQuery("stderr_offset", "0"), 10, 64)
+	if err != nil || stderrOffset < 0 {
+		return 0, 0, fmt.Errorf("stderr_offset must be a non-negative integer")
+	}
+	return stdoutOffset, stderrOffset, nil
+}
+
+// isTerminalStatus reports whether a job can no longer change state
+func isTerminalStatus(status string) bool {
+	return status == models.StatusCompleted || status == models.StatusFailed || status == models.StatusCancelled
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+const (
+	defaultOutputLimit = 1 << 20  // 1 MiB per stream unless the caller asks for more
+	maxOutputLimit     = 10 << 20 // Never return more than 10 MiB per stream in one response
+)
+
+// OutputChunk holds a byte range read from one of a job's output files
+type OutputChunk struct {
+	Content    string `json:"content"`
+	Offset     int64  `json:"offset"`      // Byte offset the content starts at
+	NextOffset int64  `json:"next_offset"` // Pass as ?offset= to continue reading
+	Size       int64  `json:"size"`        // Current total file size
+	Truncated  bool   `json:"truncated"`   // More data is available after next_offset
+}
+
+// GetJobOutput returns a job's stdout/stderr with byte-range and tail support
+func (h *JobHandler) GetJobOutput(c *gin.Context) {
+	jobID, ok := h.jobIDParam(c)
+	if !ok {
+		return
+	}
+
+	stream := c.DefaultQuery("stream", "all")
+	if stream != "all" && stream != "stdout" && stream != "stderr" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "stream must be one of: all, stdout, stderr"})
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.DefaultQuery("offset", "0"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+		return
+	}
+
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", strconv.Itoa(defaultOutputLimit)), 10, 64)
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+		return
+	}
+	if limit > maxOutputLimit {
+		limit = maxOutputLimit
+	}
+
+	// tail=N returns the last N bytes and takes precedence over offset
+	var tail int64
+	if tailStr := c.Query("tail"); tailStr != "" {
+		tail, err = strconv.ParseInt(tailStr, 10, 64)
+		if err != nil || tail <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "tail must be a positive integer"})
+			return
+		}
+		if tail > limit {
+			tail = limit
+		}
+	}
+
+	var ownerID int
+	var status string
+	var outputPath sql.NullString
+	err = h.db.QueryRow(
+		"SELECT user_id, status, output_path FROM jobs WHERE id = $1", jobID,
+	).Scan(&ownerID, &status, &outputPath)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if !canAccessJob(c, ownerID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this job"})
+		return
+	}
+
+	if !outputPath.Valid || outputPath.String == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job has not produced any output yet", "status": status})
+		return
+	}
+
+	response := gin.H{
+		"job_id":      jobID,
+		"status":      status,
+		"output_path": outputPath.String,
+	}
+
+	for _, name := range []string{"stdout", "stderr"} {
+		if stream != "all" && stream != name {
+			continue
+		}
+
+		fileName := models.StdoutFile
+		if name == "stderr" {
+			fileName = models.StderrFile
+		}
+
+		chunk, err := readOutputChunk(filepath.Join(outputPath.String, fileName), offset, limit, tail)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read job output"})
+			return
+		}
+		response[name] = chunk
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// readOutputChunk reads up to limit bytes from path starting at offset, or the last tail bytes
+func readOutputChunk(path string, offset, limit, tail int64) (OutputChunk, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		// The process has not created the file yet
+		return OutputChunk{Offset: offset, NextOffset: offset}, nil
+	}
+	if err != nil {
+		return OutputChunk{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return OutputChunk{}, err
+	}
+	size := info.Size()
+
+	if tail > 0 {
+		offset = size - tail
+		if offset < 0 {
+			offset = 0
+		}
+	}
+	if offset > size {
+		offset = size
+	}
+
+	toRead := size - offset
+	if toRead > limit {
+		toRead = limit
+	}
+
+	buf := make([]byte, toRead)
+	n, err := file.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return OutputChunk{}, err
+	}
+
+	return OutputChunk{
+		Content:    string(buf[:n]),
+		Offset:     offset,
+		NextOffset: offset + int64(n),
+		Size:       size,
+		Truncated:  offset+int64(n) < size,
+	}, nil
+}
+
+// canAccessJob reports whether the authenticated user owns the job or is an admin
+func canAccessJob(c *gin.Context, ownerID int) bool {
+	if isAdmin, _ := c.Get("is_admin"); isAdmin == true {
+		return true
+	}
+	userID, _ := c.Get("user_id")
+	return userID == ownerID
+}
@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+
+	"github.com/samik-k21/research-compute-queue/internal/database"
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+var (
+	errPartitionNotFound   = errors.New("partition not found")
+	errPartitionNotAllowed = errors.New("your group may not use this partition")
+	errNoDefaultPartition  = errors.New("no default partition is configured; choose one with \"partition\"")
+)
+
+type PartitionHandler struct {
+	db *database.DB
+}
+
+func NewPartitionHandler(db *database.DB) *PartitionHandler {
+	return &PartitionHandler{db: db}
+}
+
+// jobPartition is the partition a job is submitted to
+type jobPartition struct {
+	id          int
+	name        string
+	maxWalltime sql.NullFloat64
+}
+
+// resolvePartition returns the named partition, or the group's default, or the
+// system default, and checks the group may use it
+func resolvePartition(db *database.DB, groupID int, name string) (*jobPartition, error) {
+	var p jobPartition
+	var err error
+	if name != "" {
+		err = db.QueryRow(
+			"SELECT id, name, max_walltime_hours FROM partitions WHERE name = $1", name,
+		).Scan(&p.id, &p.name, &p.maxWalltime)
+		if err == sql.ErrNoRows {
+			return nil, errPartitionNotFound
+		}
+	} else {
+		err = db.QueryRow(`
+			SELECT p.id, p.name, p.max_walltime_hours
+			FROM partitions p
+			LEFT JOIN partition_groups pg ON pg.partition_id = p.id AND pg.group_id = $1 AND pg.is_default
+			WHERE pg.group_id IS NOT NULL OR p.is_default
+			ORDER BY pg.group_id IS NULL
+			LIMIT 1
+		`, groupID).Scan(&p.id, &p.name, &p.maxWalltime)
+		if err == sql.ErrNoRows {
+			return nil, errNoDefaultPartition
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var allowed bool
+	err = db.QueryRow(`
+		SELECT NOT EXISTS (SELECT 1 FROM partition_groups WHERE partition_id = $1)
+		    OR EXISTS (SELECT 1 FROM partition_groups WHERE partition_id = $1 AND group_id = $2)
+	`, p.id, groupID).Scan(&allowed)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, errPartitionNotAllowed
+	}
+	return &p, nil
+}
+
+// ListPartitions returns every partition with its limits, workers and groups
+func (h *PartitionHandler) ListPartitions(c *gin.Context) {
+	rows, err := h.db.Query(`
+		SELECT p.id, p.name, p.max_walltime_hours, p.max_jobs_per_user, p.max_running_jobs,
+		       p.priority_tier, p.is_default, p.created_at,
+		       ARRAY(SELECT worker_id FROM partition_workers WHERE partition_id = p.id ORDER BY worker_id),
+		       ARRAY(SELECT group_id FROM partition_groups WHERE partition_id = p.id ORDER BY group_id),
+		       ARRAY(SELECT group_id FROM partition_groups WHERE partition_id = p.id AND is_default ORDER BY group_id)
+		FROM partitions p
+		ORDER BY p.priority_tier DESC, p.name
+	`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	partitions := []models.Partition{}
+	for rows.Next() {
+		var p models.Partition
+		var workers, allowed, defaults pq.Int64Array
+		err := rows.Scan(&p.ID, &p.Name, &p.MaxWalltimeHours, &p.MaxJobsPerUser, &p.MaxRunningJobs,
+			&p.PriorityTier, &p.IsDefault, &p.CreatedAt, &workers, &allowed, &defaults)
+		if err != nil {
+			continue
+		}
+		p.Workers, p.AllowedGroups, p.DefaultGroups = toInts(workers), toInts(allowed), toInts(defaults)
+		partitions = append(partitions, p)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"partitions": partitions,
+		"count":      len(partitions),
+	})
+}
+
+// CreatePartition adds a partition
+func (h *PartitionHandler) CreatePartition(c *gin.Context) {
+	var req models.PartitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.savePartition(c, 0, req)
+}
+
+// UpdatePartition replaces a partition's limits, workers and groups
+func (h *PartitionHandler) UpdatePartition(c *gin.Context) {
+	var req models.PartitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var partitionID int
+	err := h.db.QueryRow("SELECT id FROM partitions WHERE name = $1", c.Param("name")).Scan(&partitionID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Partition not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	h.savePartition(c, partitionID, req)
+}
+
+// savePartition inserts (partitionID 0) or updates a partition and replaces its
+// workers and groups in one transaction
+func (h *PartitionHandler) savePartition(c *gin.Context, partitionID int, req models.PartitionRequest) {
+	if err := validatePartitionGroups(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save partition"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Only one partition can be the system default
+	if req.IsDefault {
+		if _, err := tx.Exec("UPDATE partitions SET is_default = FALSE WHERE is_default AND id != $1", partitionID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save partition"})
+			return
+		}
+	}
+
+	if partitionID == 0 {
+		err = tx.QueryRow(`
+			INSERT INTO partitions (name, max_walltime_hours, max_jobs_per_user, max_running_jobs,
+			                        priority_tier, is_default)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id
+		`, req.Name, req.MaxWalltimeHours, req.MaxJobsPerUser, req.MaxRunningJobs,
+			req.PriorityTier, req.IsDefault).Scan(&partitionID)
+	} else {
+		_, err = tx.Exec(`
+			UPDATE partitions
+			SET name = $1, max_walltime_hours = $2, max_jobs_per_user = $3, max_running_jobs = $4,
+			    priority_tier = $5, is_default = $6
+			WHERE id = $7
+		`, req.Name, req.MaxWalltimeHours, req.MaxJobsPerUser, req.MaxRunningJobs,
+			req.PriorityTier, req.IsDefault, partitionID)
+	}
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("partition %q already exists", req.Name)})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save partition"})
+		return
+	}
+
+	if err := replacePartitionMembers(tx, partitionID, req); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown worker or group ID"})
+			return
+		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "A group can only have one default partition"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save partition"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save partition"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Partition saved",
+		"partition_id": partitionID,
+		"name":         req.Name,
+	})
+}
+
+// validatePartitionGroups checks that default groups may use the partition
+func validatePartitionGroups(req models.PartitionRequest) error {
+	if len(req.AllowedGroups) == 0 {
+		return nil
+	}
+	allowed := make(map[int]bool, len(req.AllowedGroups))
+	for _, groupID := range req.AllowedGroups {
+		allowed[groupID] = true
+	}
+	for _, groupID := range req.DefaultGroups {
+		if !allowed[groupID] {
+			return fmt.Errorf("default group %d is not in allowed_groups", groupID)
+		}
+	}
+	return nil
+}
+
+// replacePartitionMembers sets a partition's workers and groups
+func replacePartitionMembers(tx *sql.Tx, partitionID int, req models.PartitionRequest) error {
+	if _, err := tx.Exec("DELETE FROM partition_workers WHERE partition_id = $1", partitionID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM partition_groups WHERE partition_id = $1", partitionID); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO partition_workers (partition_id, worker_id)
+		SELECT $1, unnest($2::int[])
+		ON CONFLICT DO NOTHING
+	`, partitionID, pq.Array(req.Workers))
+	if err != nil {
+		return err
+	}
+
+	// Default groups are allowed too; with no allowed_groups the partition stays
+	// open to every group, and only the defaults get a row
+	_, err = tx.Exec(`
+		INSERT INTO partition_groups (partition_id, group_id, is_default)
+		SELECT $1, g, g = ANY($3::int[])
+		FROM unnest($2::int[] || $3::int[]) AS g
+		ON CONFLICT DO NOTHING
+	`, partitionID, pq.Array(req.AllowedGroups), pq.Array(req.DefaultGroups))
+	return err
+}
+
+// DeletePartition removes a partition that has no pending or running jobs
+func (h *PartitionHandler) DeletePartition(c *gin.Context) {
+	var partitionID, active int
+	err := h.db.QueryRow(`
+		SELECT p.id, COUNT(j.id)
+		FROM partitions p
+		LEFT JOIN jobs j ON j.partition_id = p.id AND j.status IN ('pending', 'running')
+		WHERE p.name = $1
+		GROUP BY p.id
+	`, c.Param("name")).Scan(&partitionID, &active)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Partition not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if active > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": fmt.Sprintf("partition has %d pending or running jobs", active),
+		})
+		return
+	}
+
+	if _, err := h.db.Exec("DELETE FROM partitions WHERE id = $1", partitionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete partition"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Partition deleted",
+		"partition_id": partitionID,
+	})
+}
+
+// toInts converts a scanned integer array
+func toInts(values pq.Int64Array) []int {
+	ints := make([]int, len(values))
+	for i, v := range values {
+		ints[i] = int(v)
+	}
+	return ints
+}
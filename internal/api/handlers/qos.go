@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+
+	"github.com/samik-k21/research-compute-queue/internal/database"
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+var (
+	errQoSNotFound   = errors.New("QoS not found")
+	errQoSNotAllowed = errors.New("your group may not use this QoS")
+	errNoDefaultQoS  = errors.New("no default QoS is configured; choose one with \"qos\"")
+)
+
+type QoSHandler struct {
+	db *database.DB
+}
+
+func NewQoSHandler(db *database.DB) *QoSHandler {
+	return &QoSHandler{db: db}
+}
+
+// jobQoS is the QoS a job is submitted with
+type jobQoS struct {
+	id          int
+	name        string
+	maxWalltime sql.NullFloat64
+	preemptible bool
+}
+
+// resolveQoS returns the named QoS, or the default one, and checks the group
+// may use it. The default QoS is open to every group; others need a grant.
+func resolveQoS(db *database.DB, groupID int, name string) (*jobQoS, error) {
+	var q jobQoS
+	var isDefault, granted bool
+	err := db.QueryRow(`
+		SELECT q.id, q.name, q.max_walltime_hours, q.preemptible, q.is_default,
+		       EXISTS (SELECT 1 FROM group_qos gq WHERE gq.qos_id = q.id AND gq.group_id = $1)
+		FROM qos q
+		WHERE ($2 = '' AND q.is_default) OR q.name = $2
+	`, groupID, name).Scan(&q.id, &q.name, &q.maxWalltime, &q.preemptible, &isDefault, &granted)
+	if err == sql.ErrNoRows {
+		if name == "" {
+			return nil, errNoDefaultQoS
+		}
+		return nil, errQoSNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !isDefault && !granted {
+		return nil, errQoSNotAllowed
+	}
+	return &q, nil
+}
+
+// ListQoS returns every QoS with its limits and the groups granted it
+func (h *QoSHandler) ListQoS(c *gin.Context) {
+	rows, err := h.db.Query(`
+		SELECT q.id, q.name, COALESCE(q.description, ''), q.priority_factor,
+		       q.max_jobs_per_user, q.max_cpus_per_user, q.max_jobs_per_group, q.max_cpus_per_group,
+		       q.max_walltime_hours, q.can_preempt, q.preemptible, q.is_default, q.created_at,
+		       ARRAY(SELECT group_id FROM group_qos WHERE qos_id = q.id ORDER BY group_id)
+		FROM qos q
+		ORDER BY q.priority_factor DESC, q.name
+	`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	classes := []models.QoS{}
+	for rows.Next() {
+		var q models.QoS
+		var groups pq.Int64Array
+		err := rows.Scan(&q.ID, &q.Name, &q.Description, &q.PriorityFactor,
+			&q.MaxJobsPerUser, &q.MaxCPUsPerUser, &q.MaxJobsPerGroup, &q.MaxCPUsPerGroup,
+			&q.MaxWalltimeHours, &q.CanPreempt, &q.Preemptible, &q.IsDefault, &q.CreatedAt, &groups)
+		if err != nil {
+			continue
+		}
+		q.Groups = toInts(groups)
+		classes = append(classes, q)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"qos":   classes,
+		"count": len(classes),
+	})
+}
+
+// CreateQoS adds a QoS
+func (h *QoSHandler) CreateQoS(c *gin.Context) {
+	var req models.QoSRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.saveQoS(c, 0, req)
+}
+
+// UpdateQoS replaces a QoS's priority factor, limits and preemption rights
+func (h *QoSHandler) UpdateQoS(c *gin.Context) {
+	var req models.QoSRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var qosID int
+	err := h.db.QueryRow("SELECT id FROM qos WHERE name = $1", c.Param("name")).Scan(&qosID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "QoS not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	h.saveQoS(c, qosID, req)
+}
+
+// saveQoS inserts (qosID 0) or updates a QoS
+func (h *QoSHandler) saveQoS(c *gin.Context, qosID int, req models.QoSRequest) {
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save QoS"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Only one QoS can be the default
+	if req.IsDefault {
+		if _, err := tx.Exec("UPDATE qos SET is_default = FALSE WHERE is_default AND id != $1", qosID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save QoS"})
+			return
+		}
+	}
+
+	if qosID == 0 {
+		err = tx.QueryRow(`
+			INSERT INTO qos (name, description, priority_factor, max_jobs_per_user, max_cpus_per_user,
+			                 max_jobs_per_group, max_cpus_per_group, max_walltime_hours,
+			                 can_preempt, preemptible, is_default)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			RETURNING id
+		`, req.Name, req.Description, req.PriorityFactor, req.MaxJobsPerUser, req.MaxCPUsPerUser,
+			req.MaxJobsPerGroup, req.MaxCPUsPerGroup, req.MaxWalltimeHours,
+			req.CanPreempt, req.Preemptible, req.IsDefault).Scan(&qosID)
+	} else {
+		_, err = tx.Exec(`
+			UPDATE qos
+			SET name = $1, description = $2, priority_factor = $3, max_jobs_per_user = $4,
+			    max_cpus_per_user = $5, max_jobs_per_group = $6, max_cpus_per_group = $7,
+			    max_walltime_hours = $8, can_preempt = $9, preemptible = $10, is_default = $11
+			WHERE id = $12
+		`, req.Name, req.Description, req.PriorityFactor, req.MaxJobsPerUser, req.MaxCPUsPerUser,
+			req.MaxJobsPerGroup, req.MaxCPUsPerGroup, req.MaxWalltimeHours,
+			req.CanPreempt, req.Preemptible, req.IsDefault, qosID)
+	}
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("QoS %q already exists", req.Name)})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save QoS"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save QoS"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "QoS saved",
+		"qos_id":  qosID,
+		"name":    req.Name,
+	})
+}
+
+// SetGroupQoS replaces the QoS classes a group may use (besides the default)
+func (h *QoSHandler) SetGroupQoS(c *gin.Context) {
+	groupID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
+		return
+	}
+
+	var req models.SetGroupQoSRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update group"})
+		return
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM groups WHERE id = $1)", groupID).Scan(&exists); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	}
+
+	if _, err := tx.Exec("DELETE FROM group_qos WHERE group_id = $1", groupID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update group"})
+		return
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO group_qos (group_id, qos_id)
+		SELECT $1, id FROM qos WHERE name = ANY($2::varchar[])
+	`, groupID, pq.Array(req.QoS))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update group"})
+		return
+	}
+	if granted, _ := result.RowsAffected(); int(granted) != len(uniqueStrings(req.QoS)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown QoS name"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update group"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Group QoS updated",
+		"group_id": groupID,
+		"qos":      req.QoS,
+	})
+}
+
+// uniqueStrings returns values without duplicates
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var unique []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
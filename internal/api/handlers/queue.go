@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/samik-k21/research-compute-queue/internal/database"
+	"github.com/samik-k21/research-compute-queue/internal/models"
+	"github.com/samik-k21/research-compute-queue/internal/scheduler"
+)
+
+type QueueHandler struct {
+	db        *database.DB
+	scheduler *scheduler.Scheduler
+}
+
+func NewQueueHandler(db *database.DB, sched *scheduler.Scheduler) *QueueHandler {
+	return &QueueHandler{db: db, scheduler: sched}
+}
+
+// GetQueuePriority lists every pending job in scheduling order with its
+// priority factors and pending reason
+func (h *QueueHandler) GetQueuePriority(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	explained, err := h.scheduler.ExplainQueue()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	total := len(explained)
+	if len(explained) > limit {
+		explained = explained[:limit]
+	}
+	// The fair-share path names the user, so it is only shown to them
+	for i := range explained {
+		if !canAccessJob(c, explained[i].UserID) {
+			explained[i].FairSharePath = nil
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":  explained,
+		"count": len(explained),
+		"total": total,
+	})
+}
+
+// GetQueueForecast lists the pending jobs by expected start, as forecast by the
+// last scheduling cycle. Jobs whose start cannot be predicted come last.
+func (h *QueueHandler) GetQueueForecast(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	rows, err := h.db.Query(`
+		SELECT j.id, j.user_id, j.group_id, COALESCE(p.name, ''), j.cpu_cores, j.memory_gb,
+		       j.gpu_count, COALESCE(j.estimated_hours, 0), COALESCE(j.pending_reason, ''),
+		       j.expected_start, j.expected_worker_id, COALESCE(w.hostname, '')
+		FROM jobs j
+		LEFT JOIN partitions p ON p.id = j.partition_id
+		LEFT JOIN workers w ON w.id = j.expected_worker_id
+		WHERE j.status = 'pending' AND NOT j.is_array
+		ORDER BY j.expected_start ASC NULLS LAST, j.submitted_at ASC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	forecast := []models.JobForecast{}
+	for rows.Next() {
+		var f models.JobForecast
+		err := rows.Scan(&f.JobID, &f.UserID, &f.GroupID, &f.Partition, &f.CPUCores, &f.MemoryGB,
+			&f.GPUCount, &f.EstimatedHours, &f.PendingReason, &f.ExpectedStart, &f.WorkerID, &f.Worker)
+		if err != nil {
+			continue
+		}
+		forecast = append(forecast, f)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":  forecast,
+		"count": len(forecast),
+	})
+}
+
+// GetJobPriority explains a pending job's priority and its place in the queue
+func (h *JobHandler) GetJobPriority(c *gin.Context) {
+	jobID, ok := h.jobIDParam(c)
+	if !ok {
+		return
+	}
+
+	var ownerID int
+	var status string
+	var isArray bool
+	err := h.db.QueryRow("SELECT user_id, status, is_array FROM jobs WHERE id = $1", jobID).Scan(&ownerID, &status, &isArray)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	if !canAccessJob(c, ownerID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this job"})
+		return
+	}
+	if isArray {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Job array tasks are ranked one by one; ask for {array_id}_{index}"})
+		return
+	}
+	if status != models.StatusPending {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("job is %s; only pending jobs have a queue priority", status)})
+		return
+	}
+
+	explained, err := h.scheduler.ExplainQueue()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	ranked := 0
+	var job *models.JobPriority
+	for i := range explained {
+		if explained[i].Rank != nil {
+			ranked++
+		}
+		if explained[i].JobID == jobID {
+			job = &explained[i]
+		}
+	}
+	if job == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "job is no longer pending"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"job":          job,
+		"queue_length": ranked,
+	})
+}
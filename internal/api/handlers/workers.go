@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/samik-k21/research-compute-queue/internal/models"
+	"github.com/samik-k21/research-compute-queue/internal/scheduler"
+)
+
+type WorkerHandler struct {
+	scheduler *scheduler.Scheduler
+}
+
+func NewWorkerHandler(sched *scheduler.Scheduler) *WorkerHandler {
+	return &WorkerHandler{scheduler: sched}
+}
+
+// Register adds a worker agent and returns its worker ID
+func (h *WorkerHandler) Register(c *gin.Context) {
+	var req models.RegisterWorkerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	workerID, err := h.scheduler.RegisterWorker(req)
+	if errors.Is(err, scheduler.ErrHostnameInUse) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register worker"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RegisterWorkerResponse{WorkerID: workerID})
+}
+
+// Heartbeat records that an agent is alive and tells it which jobs to stop
+func (h *WorkerHandler) Heartbeat(c *gin.Context) {
+	workerID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid worker ID"})
+		return
+	}
+
+	var req models.HeartbeatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stopJobs, err := h.scheduler.Heartbeat(workerID, req.RunningJobs)
+	if errors.Is(err, scheduler.ErrWorkerNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Worker not found, register again"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record heartbeat"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.HeartbeatResponse{StopJobs: stopJobs})
+}
+
+// ClaimJobs returns the jobs newly assigned to the agent
+func (h *WorkerHandler) ClaimJobs(c *gin.Context) {
+	workerID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid worker ID"})
+		return
+	}
+
+	jobs, err := h.scheduler.ClaimJobs(workerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to claim jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ClaimJobsResponse{Jobs: jobs})
+}
+
+// ReportJobResult records a job's exit status reported by its agent
+func (h *WorkerHandler) ReportJobResult(c *gin.Context) {
+	workerID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid worker ID"})
+		return
+	}
+	jobID, err := strconv.Atoi(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	var req models.JobResultRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.scheduler.ReportJobResult(workerID, jobID, req)
+	if errors.Is(err, scheduler.ErrJobNotAssigned) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record job result"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Job result recorded", "job_id": jobID})
+}
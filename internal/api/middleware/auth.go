@@ -63,4 +63,19 @@ func (am *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 		// Continue to next handler
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// RequireAdmin rejects requests from non-admin users (use after RequireAuth)
+func (am *AuthMiddleware) RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isAdmin, _ := c.Get("is_admin"); isAdmin != true {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Admin access required",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
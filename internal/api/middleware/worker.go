@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WorkerTokenHeader carries the shared secret worker agents authenticate with
+const WorkerTokenHeader = "X-Worker-Token"
+
+// RequireWorkerToken checks the shared worker agent token.
+// An empty token disables the worker API entirely.
+func RequireWorkerToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error": "Worker agents are disabled (WORKER_TOKEN is not set)",
+			})
+			c.Abort()
+			return
+		}
+
+		provided := c.GetHeader(WorkerTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Invalid worker token",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
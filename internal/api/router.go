@@ -7,10 +7,11 @@ import (
 	"github.com/samik-k21/research-compute-queue/internal/api/middleware"
 	"github.com/samik-k21/research-compute-queue/internal/auth"
 	"github.com/samik-k21/research-compute-queue/internal/database"
+	"github.com/samik-k21/research-compute-queue/internal/scheduler"
 )
 
 // SetupRouter creates and configures the Gin router
-func SetupRouter(db *database.DB, jwtManager *auth.JWTManager) *gin.Engine {
+func SetupRouter(db *database.DB, jwtManager *auth.JWTManager, sched *scheduler.Scheduler, workerToken string) *gin.Engine {
 	// Create router
 	router := gin.New()
 
@@ -23,7 +24,13 @@ func SetupRouter(db *database.DB, jwtManager *auth.JWTManager) *gin.Engine {
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(db, jwtManager)
-	jobHandler := handlers.NewJobHandler(db)
+	jobHandler := handlers.NewJobHandler(db, sched)
+	adminHandler := handlers.NewAdminHandler(db)
+	workerHandler := handlers.NewWorkerHandler(sched)
+	partitionHandler := handlers.NewPartitionHandler(db)
+	qosHandler := handlers.NewQoSHandler(db)
+	fairShareHandler := handlers.NewFairShareHandler(db, sched)
+	queueHandler := handlers.NewQueueHandler(db, sched)
 
 	// Health check (no auth required)
 	router.GET("/health", handlers.HealthCheck)
@@ -45,8 +52,65 @@ func SetupRouter(db *database.DB, jwtManager *auth.JWTManager) *gin.Engine {
 			jobs.POST("", jobHandler.SubmitJob)
 			jobs.GET("", jobHandler.ListJobs)
 			jobs.GET("/:id", jobHandler.GetJob)
+			jobs.GET("/:id/output", jobHandler.GetJobOutput)
+			jobs.GET("/:id/logs", jobHandler.StreamJobLogs)
+			jobs.GET("/:id/priority", jobHandler.GetJobPriority)
 			jobs.DELETE("/:id", jobHandler.CancelJob)
 		}
+
+		// Queue routes (auth required)
+		queue := api.Group("/queue")
+		queue.Use(authMiddleware.RequireAuth())
+		{
+			queue.GET("/priority", queueHandler.GetQueuePriority)
+			queue.GET("/forecast", queueHandler.GetQueueForecast)
+		}
+
+		// Partition routes (auth required)
+		partitions := api.Group("/partitions")
+		partitions.Use(authMiddleware.RequireAuth())
+		{
+			partitions.GET("", partitionHandler.ListPartitions)
+		}
+
+		// QoS routes (auth required)
+		qos := api.Group("/qos")
+		qos.Use(authMiddleware.RequireAuth())
+		{
+			qos.GET("", qosHandler.ListQoS)
+		}
+
+		// Worker agent routes (shared worker token required)
+		workers := api.Group("/workers")
+		workers.Use(middleware.RequireWorkerToken(workerToken))
+		{
+			workers.POST("/register", workerHandler.Register)
+			workers.POST("/:id/heartbeat", workerHandler.Heartbeat)
+			workers.POST("/:id/claim", workerHandler.ClaimJobs)
+			workers.POST("/:id/jobs/:job_id/result", workerHandler.ReportJobResult)
+		}
+
+		// Admin routes (auth + admin required)
+		admin := api.Group("/admin")
+		admin.Use(authMiddleware.RequireAuth(), authMiddleware.RequireAdmin())
+		{
+			admin.GET("/groups", adminHandler.ListGroups)
+			admin.PUT("/groups/:id/walltime", adminHandler.SetGroupWalltime)
+			admin.PUT("/groups/:id/limits", adminHandler.SetGroupLimits)
+			admin.PUT("/users/:id/limits", adminHandler.SetUserLimits)
+			admin.POST("/partitions", partitionHandler.CreatePartition)
+			admin.PUT("/partitions/:name", partitionHandler.UpdatePartition)
+			admin.DELETE("/partitions/:name", partitionHandler.DeletePartition)
+			admin.POST("/qos", qosHandler.CreateQoS)
+			admin.PUT("/qos/:name", qosHandler.UpdateQoS)
+			admin.PUT("/groups/:id/qos", qosHandler.SetGroupQoS)
+			admin.GET("/fairshare", fairShareHandler.GetFairShare)
+			admin.GET("/departments", fairShareHandler.ListDepartments)
+			admin.POST("/departments", fairShareHandler.CreateDepartment)
+			admin.PUT("/departments/:name", fairShareHandler.UpdateDepartment)
+			admin.PUT("/groups/:id/shares", fairShareHandler.SetGroupShares)
+			admin.PUT("/users/:id/shares", fairShareHandler.SetUserShares)
+		}
 	}
 
 	return router
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"strconv"
@@ -10,15 +11,42 @@ import (
 
 // Config holds the application configuration
 type Config struct {
-	DatabaseURL  string
-	Port         string
-	Environment  string
-	JWTSecret    string
+	DatabaseURL            string
+	Port                   string
+	Environment            string
+	JWTSecret              string
 	JWTExpiryHours         int
 	SchedulerIntervalSecs  int
 	MaxConcurrentJobs      int
 	LogDirectory           string
 	OutputDirectory        string
+	ExecutorMode           string // "local" runs job scripts, "simulate" sleeps for estimated_hours
+	JobShell               string
+	CancelGraceSecs        int     // Time between SIGTERM and SIGKILL when stopping a job
+	WalltimeGraceFactor    float64 // Jobs are killed after estimated_hours × this factor
+	PlacementStrategy      string  // first-fit, best-fit, worst-fit or gpu-avoid
+	BackfillEnabled        bool    // EASY backfill around the highest-priority blocked job
+	WorkerToken            string  // Shared secret for worker agents (empty disables the worker API)
+	HeartbeatTimeoutSecs   int     // Agents silent for longer than this are marked offline
+	MaxNodeFailureRequeues int     // Requeues after node failures before a job is failed
+	RecoveryPolicy         string  // What happens to local jobs lost when their replica stopped: "requeue" or "fail"
+	SchedulerEventsEnabled bool    // Run a cycle on LISTEN/NOTIFY events, not only on the interval
+	SchedulerDebounceMs    int     // How long to gather events before running a cycle
+	ForecastIntervalSecs   int     // How often start times of pending jobs are forecast
+	PreemptionEnabled      bool    // Stop preemptible jobs to make room for higher-priority ones
+	FairShareHalfLifeHours float64 // Past usage counts half as much after this long (0 = no decay)
+	PriorityMaxAgeHours    float64 // Wait after which a job's age factor is maxed out
+	PriorityFavorSmall     bool    // Job size factor favours small jobs instead of large ones
+	PriorityWeights        PriorityWeights
+}
+
+// PriorityWeights scales each multifactor priority factor
+type PriorityWeights struct {
+	Age       float64
+	FairShare float64
+	JobSize   float64
+	QoS       float64
+	Partition float64
 }
 
 // Load reads configuration from environment variables
@@ -29,15 +57,39 @@ func Load() *Config {
 	}
 
 	return &Config{
-		DatabaseURL:           getEnv("DATABASE_URL", ""),
-		Port:                  getEnv("PORT", "8080"),
-		Environment:           getEnv("ENVIRONMENT", "development"),
-		JWTSecret:             getEnv("JWT_SECRET", ""),
-		JWTExpiryHours:        getEnvAsInt("JWT_EXPIRY_HOURS", 24),
-		SchedulerIntervalSecs: getEnvAsInt("SCHEDULER_INTERVAL_SECONDS", 30),
-		MaxConcurrentJobs:     getEnvAsInt("MAX_CONCURRENT_JOBS", 10),
-		LogDirectory:          getEnv("LOG_DIRECTORY", "./logs"),
-		OutputDirectory:       getEnv("OUTPUT_DIRECTORY", "./output"),
+		DatabaseURL:            getEnv("DATABASE_URL", ""),
+		Port:                   getEnv("PORT", "8080"),
+		Environment:            getEnv("ENVIRONMENT", "development"),
+		JWTSecret:              getEnv("JWT_SECRET", ""),
+		JWTExpiryHours:         getEnvAsInt("JWT_EXPIRY_HOURS", 24),
+		SchedulerIntervalSecs:  getEnvAsInt("SCHEDULER_INTERVAL_SECONDS", 30),
+		MaxConcurrentJobs:      getEnvAsInt("MAX_CONCURRENT_JOBS", 10),
+		LogDirectory:           getEnv("LOG_DIRECTORY", "./logs"),
+		OutputDirectory:        getEnv("OUTPUT_DIRECTORY", "./output"),
+		ExecutorMode:           getEnv("EXECUTOR_MODE", "local"),
+		JobShell:               getEnv("JOB_SHELL", "/bin/bash"),
+		CancelGraceSecs:        getEnvAsInt("CANCEL_GRACE_SECONDS", 10),
+		WalltimeGraceFactor:    getEnvAsFloat("WALLTIME_GRACE_FACTOR", 1.1),
+		PlacementStrategy:      getEnv("PLACEMENT_STRATEGY", "best-fit"),
+		BackfillEnabled:        getEnvAsBool("BACKFILL_ENABLED", true),
+		WorkerToken:            getEnv("WORKER_TOKEN", ""),
+		HeartbeatTimeoutSecs:   getEnvAsInt("WORKER_HEARTBEAT_TIMEOUT_SECONDS", 90),
+		MaxNodeFailureRequeues: getEnvAsInt("MAX_NODE_FAILURE_REQUEUES", 3),
+		RecoveryPolicy:         getEnv("RECOVERY_POLICY", "requeue"),
+		SchedulerEventsEnabled: getEnvAsBool("SCHEDULER_EVENTS_ENABLED", true),
+		SchedulerDebounceMs:    getEnvAsInt("SCHEDULER_DEBOUNCE_MS", 500),
+		ForecastIntervalSecs:   getEnvAsInt("FORECAST_INTERVAL_SECONDS", 60),
+		PreemptionEnabled:      getEnvAsBool("PREEMPTION_ENABLED", true),
+		FairShareHalfLifeHours: getEnvAsFloat("FAIRSHARE_HALF_LIFE_HOURS", 168),
+		PriorityMaxAgeHours:    getEnvAsFloat("PRIORITY_MAX_AGE_HOURS", 168),
+		PriorityFavorSmall:     getEnvAsBool("PRIORITY_FAVOR_SMALL", false),
+		PriorityWeights: PriorityWeights{
+			Age:       getEnvAsFloat("PRIORITY_WEIGHT_AGE", 1000),
+			FairShare: getEnvAsFloat("PRIORITY_WEIGHT_FAIRSHARE", 10000),
+			JobSize:   getEnvAsFloat("PRIORITY_WEIGHT_JOB_SIZE", 1000),
+			QoS:       getEnvAsFloat("PRIORITY_WEIGHT_QOS", 2000),
+			Partition: getEnvAsFloat("PRIORITY_WEIGHT_PARTITION", 1000),
+		},
 	}
 }
 
@@ -58,6 +110,24 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getEnvAsFloat reads an environment variable as a float or returns a default
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	valueStr := os.Getenv(key)
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvAsBool reads an environment variable as a boolean or returns a default
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := os.Getenv(key)
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
 // Validate checks if required configuration values are set
 func (c *Config) Validate() error {
 	if c.DatabaseURL == "" {
@@ -66,5 +136,48 @@ func (c *Config) Validate() error {
 	if c.JWTSecret == "" {
 		log.Fatal("JWT_SECRET is required")
 	}
+	if c.ExecutorMode != "local" && c.ExecutorMode != "simulate" {
+		return fmt.Errorf("EXECUTOR_MODE must be 'local' or 'simulate', got %q", c.ExecutorMode)
+	}
+	if c.HeartbeatTimeoutSecs <= 0 {
+		return fmt.Errorf("WORKER_HEARTBEAT_TIMEOUT_SECONDS must be positive, got %d", c.HeartbeatTimeoutSecs)
+	}
+	if c.MaxNodeFailureRequeues < 0 {
+		return fmt.Errorf("MAX_NODE_FAILURE_REQUEUES cannot be negative, got %d", c.MaxNodeFailureRequeues)
+	}
+	if c.RecoveryPolicy != "requeue" && c.RecoveryPolicy != "fail" {
+		return fmt.Errorf("RECOVERY_POLICY must be 'requeue' or 'fail', got %q", c.RecoveryPolicy)
+	}
+	if c.ForecastIntervalSecs <= 0 {
+		return fmt.Errorf("FORECAST_INTERVAL_SECONDS must be positive, got %d", c.ForecastIntervalSecs)
+	}
+	return c.ValidateScheduling()
+}
+
+// ValidateScheduling checks the settings the scheduling loop itself uses, and
+// which the offline simulator shares with the server
+func (c *Config) ValidateScheduling() error {
+	if c.SchedulerIntervalSecs <= 0 {
+		return fmt.Errorf("SCHEDULER_INTERVAL_SECONDS must be positive, got %d", c.SchedulerIntervalSecs)
+	}
+	if c.WalltimeGraceFactor < 1.0 {
+		return fmt.Errorf("WALLTIME_GRACE_FACTOR must be at least 1.0, got %v", c.WalltimeGraceFactor)
+	}
+	if c.SchedulerDebounceMs < 0 {
+		return fmt.Errorf("SCHEDULER_DEBOUNCE_MS cannot be negative, got %d", c.SchedulerDebounceMs)
+	}
+	if c.FairShareHalfLifeHours < 0 {
+		return fmt.Errorf("FAIRSHARE_HALF_LIFE_HOURS cannot be negative, got %v", c.FairShareHalfLifeHours)
+	}
+	if c.PriorityMaxAgeHours <= 0 {
+		return fmt.Errorf("PRIORITY_MAX_AGE_HOURS must be positive, got %v", c.PriorityMaxAgeHours)
+	}
+	w := c.PriorityWeights
+	if w.Age < 0 || w.FairShare < 0 || w.JobSize < 0 || w.QoS < 0 || w.Partition < 0 {
+		return fmt.Errorf("PRIORITY_WEIGHT_* values cannot be negative")
+	}
+	if w.Age+w.FairShare+w.JobSize+w.QoS+w.Partition == 0 {
+		return fmt.Errorf("at least one PRIORITY_WEIGHT_* value must be positive")
+	}
 	return nil
 }
\ No newline at end of file
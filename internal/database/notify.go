@@ -0,0 +1,33 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// SchedulerChannel is the LISTEN/NOTIFY channel that wakes the scheduler
+const SchedulerChannel = "rcq_scheduler"
+
+// Scheduler events (payload is "<event>:<id>")
+const (
+	EventJobSubmitted = "job_submitted"
+	EventJobCompleted = "job_completed"
+	EventWorkerOnline = "worker_online"
+)
+
+// Execer is satisfied by *DB and *sql.Tx
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// Notify wakes the scheduler. Send it after the change it announces has
+// committed, not inside the transaction: a failed pg_notify would abort it.
+// Failures are logged, not returned: the scheduler's ticker picks the change
+// up anyway.
+func Notify(ex Execer, event string, id int) {
+	payload := fmt.Sprintf("%s:%d", event, id)
+	if _, err := ex.Exec("SELECT pg_notify($1, $2)", SchedulerChannel, payload); err != nil {
+		log.Printf("Error sending %s notification: %v", payload, err)
+	}
+}
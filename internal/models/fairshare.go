@@ -0,0 +1,41 @@
+package models
+
+// FairShareNode is a department, group or user in the fair-share tree
+type FairShareNode struct {
+	Kind       string          `json:"kind"` // root, department, group or user
+	ID         int             `json:"id,omitempty"`
+	Name       string          `json:"name,omitempty"`
+	Shares     float64         `json:"shares"`
+	NormShares float64         `json:"norm_shares"`          // Fraction of its siblings' shares
+	Usage      float64         `json:"usage_cpu_hours"`      // Decayed CPU hours
+	NormUsage  float64         `json:"norm_usage"`           // Fraction of its siblings' usage
+	LevelFS    *float64        `json:"level_fs"`             // norm_shares / norm_usage (null = no usage, ranked first)
+	FairShare  *float64        `json:"fair_share,omitempty"` // Users only: the priority factor, from 0 to 1
+	Children   []FairShareNode `json:"children,omitempty"`
+}
+
+// SetSharesRequest sets a user's fair-share shares
+type SetSharesRequest struct {
+	Shares int `json:"shares" binding:"required,min=1"`
+}
+
+// SetGroupSharesRequest sets a group's fair-share shares and department
+// (null = top level of the tree)
+type SetGroupSharesRequest struct {
+	Shares     int     `json:"shares" binding:"required,min=1"`
+	Department *string `json:"department"`
+}
+
+// DepartmentRequest creates or replaces a department
+type DepartmentRequest struct {
+	Name   string `json:"name" binding:"required,max=100"`
+	Shares int    `json:"shares" binding:"required,min=1"`
+}
+
+// Department is the top level of the fair-share tree
+type Department struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Shares int    `json:"shares"`
+	Groups []int  `json:"groups"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// JobForecast is when and where a pending job is expected to start
+type JobForecast struct {
+	JobID          int        `json:"job_id"`
+	UserID         int        `json:"user_id"`
+	GroupID        int        `json:"group_id"`
+	Partition      string     `json:"partition,omitempty"`
+	CPUCores       int        `json:"cpu_cores"`
+	MemoryGB       int        `json:"memory_gb"`
+	GPUCount       int        `json:"gpu_count"`
+	EstimatedHours float64    `json:"estimated_hours"`
+	PendingReason  string     `json:"pending_reason,omitempty"`
+	ExpectedStart  *time.Time `json:"expected_start"` // null = cannot be predicted
+	WorkerID       *int       `json:"worker_id,omitempty"`
+	Worker         string     `json:"worker,omitempty"` // Hostname of the expected worker
+}
@@ -4,23 +4,60 @@ import "time"
 
 // Job represents a compute job
 type Job struct {
-	ID             int        `json:"id"`
-	UserID         int        `json:"user_id"`
-	GroupID        int        `json:"group_id"`
-	Script         string     `json:"script"`
-	CPUCores       int        `json:"cpu_cores"`
-	MemoryGB       int        `json:"memory_gb"`
-	GPUCount       int        `json:"gpu_count"`
-	EstimatedHours float64    `json:"estimated_hours,omitempty"`
-	Status         string     `json:"status"`
-	Priority       int        `json:"priority"`
-	SubmittedAt    time.Time  `json:"submitted_at"`
-	StartedAt      *time.Time `json:"started_at,omitempty"`
-	CompletedAt    *time.Time `json:"completed_at,omitempty"`
-	ExitCode       *int       `json:"exit_code,omitempty"`
-	OutputPath     string     `json:"output_path,omitempty"`
-	ErrorMessage   string     `json:"error_message,omitempty"`
-	WorkerID       *int       `json:"worker_id,omitempty"`
+	ID             int             `json:"id"`
+	UserID         int             `json:"user_id"`
+	GroupID        int             `json:"group_id"`
+	Partition      string          `json:"partition,omitempty"`
+	QoS            string          `json:"qos,omitempty"`
+	Script         string          `json:"script"`
+	CPUCores       int             `json:"cpu_cores"`
+	MemoryGB       int             `json:"memory_gb"`
+	GPUCount       int             `json:"gpu_count"`
+	EstimatedHours float64         `json:"estimated_hours,omitempty"`
+	Status         string          `json:"status"`
+	Priority       int             `json:"priority"`
+	PendingReason  string          `json:"pending_reason,omitempty"` // Why the job is still pending (a Pending* code)
+	PendingDetail  string          `json:"pending_detail,omitempty"`
+	EvaluatedAt    *time.Time      `json:"pending_reason_at,omitempty"` // When the scheduler last evaluated the pending job
+	ExpectedStart  *time.Time      `json:"expected_start,omitempty"`    // Forecast start of a pending job
+	ExpectedWorker *int            `json:"expected_worker_id,omitempty"`
+	SubmittedAt    time.Time       `json:"submitted_at"`
+	StartedAt      *time.Time      `json:"started_at,omitempty"`
+	CompletedAt    *time.Time      `json:"completed_at,omitempty"`
+	ExitCode       *int            `json:"exit_code,omitempty"`
+	OutputPath     string          `json:"output_path,omitempty"`
+	ErrorMessage   string          `json:"error_message,omitempty"`
+	WorkerID       *int            `json:"worker_id,omitempty"`
+	Dependencies   []JobDependency `json:"dependencies,omitempty"`
+	Attempt        int             `json:"attempt"`
+	MaxRetries     int             `json:"max_retries"`
+	RetryCount     int             `json:"retry_count"`
+	RetryPolicy    *RetryPolicy    `json:"retry_policy,omitempty"`
+	EligibleAt     *time.Time      `json:"eligible_at,omitempty"` // Retry backoff: not started before this
+	Attempts       []JobAttempt    `json:"attempts,omitempty"`
+	ArrayJobID     *int            `json:"array_job_id,omitempty"` // Parent array, for array tasks
+	ArrayIndex     *int            `json:"array_index,omitempty"`
+	Array          *JobArray       `json:"array,omitempty"` // Set on array parents
+	Preemptible    bool            `json:"preemptible"`
+	PreemptMode    string          `json:"preempt_mode,omitempty"` // What happens when preempted: requeue or cancel
+	Preemptions    []Preemption    `json:"preemptions,omitempty"`
+}
+
+// Preemption records a run of a job stopped to make room for a higher-priority job
+type Preemption struct {
+	PreemptorJobID *int      `json:"preemptor_job_id,omitempty"`
+	WorkerID       *int      `json:"worker_id,omitempty"`
+	Action         string    `json:"action"`
+	Reason         string    `json:"reason,omitempty"`
+	PreemptedAt    time.Time `json:"preempted_at"`
+}
+
+// JobArray summarises the tasks of a job array
+type JobArray struct {
+	Spec      string         `json:"spec"`
+	Throttle  int            `json:"throttle,omitempty"` // Max tasks running at once
+	TaskCount int            `json:"task_count"`
+	Tasks     map[string]int `json:"tasks"` // Task count by status
 }
 
 // JobStatus constants
@@ -32,13 +69,106 @@ const (
 	StatusCancelled = "cancelled"
 )
 
+// TimeoutReason prefixes error_message for jobs killed for exceeding their walltime
+const TimeoutReason = "timeout"
+
+// NodeFailureReason prefixes error_message for jobs lost because their worker died
+const NodeFailureReason = "node failure"
+
+// Pending reasons: why the last scheduling cycle left a pending job waiting
+const (
+	PendingPriority       = "Priority"         // Its worker is reserved for a higher-priority job
+	PendingResources      = "Resources"        // No worker has enough free CPU, memory or GPUs
+	PendingPartitionDown  = "PartitionDown"    // No online worker in the job's partition
+	PendingConcurrency    = "ConcurrencyLimit" // MAX_CONCURRENT_JOBS jobs are running
+	PendingPreemption     = "Preempting"       // Lower-priority jobs are being stopped for it
+	PendingDependency     = "Dependency"       // A parent job has not finished
+	PendingBeginTime      = "BeginTime"        // Retry backoff: eligible_at is in the future
+	PendingArrayTaskLimit = "ArrayTaskLimit"   // The array is running its maximum of tasks
+	PendingPartitionLimit = "PartitionLimit"
+	PendingQoSMaxJobs     = "QOSMaxJobs"
+	PendingQoSMaxCPUs     = "QOSMaxCPUs"
+	PendingUserLimit      = "UserLimit"
+	PendingGroupLimit     = "GroupLimit"
+)
+
+// Retry backoff modes
+const (
+	RetryFixed       = "fixed"       // Wait delay_seconds before every retry
+	RetryExponential = "exponential" // Double the wait after each retry
+)
+
+// Attempt outcome for a run ended by a node failure or preemption and requeued
+const AttemptRequeued = "requeued"
+
+// PreemptedReason prefixes error_message for jobs stopped to make room for a higher-priority job
+const PreemptedReason = "preempted"
+
+// Preempt modes: what happens to a preemptible job when it is preempted
+const (
+	PreemptRequeue = "requeue" // Back to pending, to run again from the start
+	PreemptCancel  = "cancel"  // Cancelled
+)
+
+// RetryPolicy controls when and how soon a failed job is retried. With no exit
+// codes and node_failure false, any failure is retried; otherwise only failures
+// with a listed exit code, or caused by a node failure, are. Walltime kills are
+// only retried with timeout set, since the next run would likely time out too.
+type RetryPolicy struct {
+	Backoff      string `json:"backoff" binding:"omitempty,oneof=fixed exponential"`
+	DelaySeconds int    `json:"delay_seconds" binding:"min=0"`
+	ExitCodes    []int  `json:"exit_codes,omitempty"`
+	NodeFailure  bool   `json:"node_failure"`
+	Timeout      bool   `json:"timeout"`
+}
+
+// JobAttempt records one finished run of a job
+type JobAttempt struct {
+	Attempt      int        `json:"attempt"`
+	WorkerID     *int       `json:"worker_id,omitempty"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	EndedAt      *time.Time `json:"ended_at,omitempty"`
+	ExitCode     *int       `json:"exit_code,omitempty"`
+	Status       string     `json:"status"`
+	ErrorMessage string     `json:"error_message,omitempty"`
+}
+
+// Dependency types (Slurm-style)
+const (
+	DependAfterOK    = "afterok"    // Start after the parent completed successfully
+	DependAfterAny   = "afterany"   // Start after the parent finished in any state
+	DependAfterNotOK = "afternotok" // Start after the parent failed or was cancelled
+)
+
+// JobDependency links a job to a parent job it waits for
+type JobDependency struct {
+	JobID int    `json:"job_id"`
+	Type  string `json:"type"`
+}
+
+// Job output file names (inside the job's output directory)
+const (
+	StdoutFile = "stdout.log"
+	StderrFile = "stderr.log"
+
+	// ExitCodeFile holds a local job's exit code so it survives a server restart
+	ExitCodeFile = "exit_code"
+)
+
 // CreateJobRequest represents a job submission request
 type CreateJobRequest struct {
-	Script         string   `json:"script" binding:"required"`
-	CPUCores       int      `json:"cpu_cores" binding:"required,min=1"`
-	MemoryGB       int      `json:"memory_gb" binding:"required,min=1"`
-	GPUCount       int      `json:"gpu_count"`
-	EstimatedHours float64  `json:"estimated_hours"`
-	Priority       int      `json:"priority" binding:"min=1,max=10"`
-	Dependencies   []string `json:"dependencies"` // Job IDs this job depends on
-}
\ No newline at end of file
+	Script         string       `json:"script" binding:"required"`
+	CPUCores       int          `json:"cpu_cores" binding:"required,min=1"`
+	MemoryGB       int          `json:"memory_gb" binding:"required,min=1"`
+	GPUCount       int          `json:"gpu_count"`
+	EstimatedHours float64      `json:"estimated_hours" binding:"min=0"` // Also the walltime limit
+	Priority       int          `json:"priority" binding:"min=1,max=10"`
+	Dependencies   []string     `json:"dependencies"` // e.g. "12", "afterok:12:13", "afterany:14"
+	MaxRetries     int          `json:"max_retries" binding:"min=0,max=20"`
+	RetryPolicy    *RetryPolicy `json:"retry_policy"` // Defaults to a fixed 60s delay on any failure
+	Array          string       `json:"array"`        // Job array index spec, e.g. "0-499:1%20"
+	Preemptible    bool         `json:"preemptible"`  // May be stopped for higher-priority jobs
+	PreemptMode    string       `json:"preempt_mode" binding:"omitempty,oneof=requeue cancel"`
+	Partition      string       `json:"partition"` // Defaults to the group's, then the system default partition
+	QoS            string       `json:"qos"`       // Defaults to the default QoS
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Partition is a named queue scheduled on its own workers with its own limits
+type Partition struct {
+	ID               int       `json:"id"`
+	Name             string    `json:"name"`
+	MaxWalltimeHours *float64  `json:"max_walltime_hours,omitempty"`
+	MaxJobsPerUser   *int      `json:"max_jobs_per_user,omitempty"` // Running jobs per user
+	MaxRunningJobs   *int      `json:"max_running_jobs,omitempty"`
+	PriorityTier     int       `json:"priority_tier"` // Higher tiers are scheduled first
+	IsDefault        bool      `json:"is_default"`
+	Workers          []int     `json:"workers"`        // Member worker IDs (empty = every worker)
+	AllowedGroups    []int     `json:"allowed_groups"` // Empty = every group
+	DefaultGroups    []int     `json:"default_groups"` // Groups whose jobs go here unless they pick another
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// PartitionRequest creates or replaces a partition
+type PartitionRequest struct {
+	Name             string   `json:"name" binding:"required,max=50"`
+	MaxWalltimeHours *float64 `json:"max_walltime_hours" binding:"omitempty,gt=0"`
+	MaxJobsPerUser   *int     `json:"max_jobs_per_user" binding:"omitempty,min=1"`
+	MaxRunningJobs   *int     `json:"max_running_jobs" binding:"omitempty,min=1"`
+	PriorityTier     int      `json:"priority_tier" binding:"min=0"`
+	IsDefault        bool     `json:"is_default"`
+	Workers          []int    `json:"workers"`
+	AllowedGroups    []int    `json:"allowed_groups"`
+	DefaultGroups    []int    `json:"default_groups"` // Must also be allowed (if allowed_groups is set)
+}
@@ -0,0 +1,26 @@
+package models
+
+// JobPriority explains a pending job's priority and its place in the queue
+type JobPriority struct {
+	JobID         int              `json:"job_id"`
+	UserID        int              `json:"user_id"`
+	GroupID       int              `json:"group_id"`
+	Priority      float64          `json:"priority"` // Sum of the factors' contributions
+	Rank          *int             `json:"rank"`     // Place in the scheduling order (1 = next); null while not yet eligible
+	PendingReason string           `json:"pending_reason,omitempty"`
+	PendingDetail string           `json:"pending_detail,omitempty"`
+	BasePriority  float64          `json:"base_priority"` // group_priority + job_priority / 10
+	GroupPriority int              `json:"group_priority"`
+	JobPriority   int              `json:"job_priority"`
+	Factors       []PriorityFactor `json:"factors"`
+	FairSharePath []FairShareNode  `json:"fair_share_path,omitempty"` // The user's department, group and user nodes
+}
+
+// PriorityFactor is one weighted factor of a job's priority
+type PriorityFactor struct {
+	Name         string  `json:"name"`  // age, fair_share, job_size, qos or partition
+	Value        float64 `json:"value"` // From 0 to 1
+	Weight       float64 `json:"weight"`
+	Contribution float64 `json:"contribution"` // base_priority × weight × value
+	Detail       string  `json:"detail,omitempty"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// QoS is a quality-of-service class: a priority factor, running limits and
+// preemption rights. Groups need an admin grant to use any QoS but the default.
+type QoS struct {
+	ID               int       `json:"id"`
+	Name             string    `json:"name"`
+	Description      string    `json:"description,omitempty"`
+	PriorityFactor   float64   `json:"priority_factor"`
+	MaxJobsPerUser   *int      `json:"max_jobs_per_user,omitempty"`
+	MaxCPUsPerUser   *int      `json:"max_cpus_per_user,omitempty"`
+	MaxJobsPerGroup  *int      `json:"max_jobs_per_group,omitempty"`
+	MaxCPUsPerGroup  *int      `json:"max_cpus_per_group,omitempty"`
+	MaxWalltimeHours *float64  `json:"max_walltime_hours,omitempty"`
+	CanPreempt       bool      `json:"can_preempt"`
+	Preemptible      bool      `json:"preemptible"`
+	IsDefault        bool      `json:"is_default"`
+	Groups           []int     `json:"groups"` // Groups granted this QoS
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// QoSRequest creates or replaces a QoS
+type QoSRequest struct {
+	Name             string   `json:"name" binding:"required,max=50"`
+	Description      string   `json:"description"`
+	PriorityFactor   float64  `json:"priority_factor" binding:"required,gt=0"`
+	MaxJobsPerUser   *int     `json:"max_jobs_per_user" binding:"omitempty,min=1"`
+	MaxCPUsPerUser   *int     `json:"max_cpus_per_user" binding:"omitempty,min=1"`
+	MaxJobsPerGroup  *int     `json:"max_jobs_per_group" binding:"omitempty,min=1"`
+	MaxCPUsPerGroup  *int     `json:"max_cpus_per_group" binding:"omitempty,min=1"`
+	MaxWalltimeHours *float64 `json:"max_walltime_hours" binding:"omitempty,gt=0"`
+	CanPreempt       bool     `json:"can_preempt"`
+	Preemptible      bool     `json:"preemptible"`
+	IsDefault        bool     `json:"is_default"`
+}
+
+// SetGroupQoSRequest replaces the QoS classes a group may use
+type SetGroupQoSRequest struct {
+	QoS []string `json:"qos"`
+}
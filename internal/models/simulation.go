@@ -0,0 +1,45 @@
+package models
+
+// SimCluster describes the workers and accounts an offline simulation runs
+// against (cmd/simulate -cluster)
+type SimCluster struct {
+	Workers     []SimWorker     `json:"workers"`
+	Departments []SimDepartment `json:"departments"`
+	Groups      []SimGroup      `json:"groups"`
+	Users       []SimUser       `json:"users"`
+}
+
+// SimWorker is one worker, or Count identical ones
+type SimWorker struct {
+	Hostname string `json:"hostname"`
+	CPUCores int    `json:"cpu_cores"`
+	MemoryGB int    `json:"memory_gb"`
+	GPUCount int    `json:"gpu_count"`
+	Count    int    `json:"count"` // Defaults to 1
+}
+
+// SimDepartment is a department of the fair-share tree
+type SimDepartment struct {
+	Name   string  `json:"name"`
+	Shares float64 `json:"shares"`
+}
+
+// SimGroup is a research group. Groups missing from the cluster file get
+// priority 1 and 1 share at the top level.
+type SimGroup struct {
+	ID         int     `json:"id"`
+	Name       string  `json:"name"`
+	Department string  `json:"department,omitempty"`
+	Priority   int     `json:"priority"`
+	Shares     float64 `json:"shares"`
+	Limits     Limits  `json:"limits"`
+}
+
+// SimUser is a user in a group. Users missing from the cluster file get 1
+// share in the group of their first job.
+type SimUser struct {
+	ID      int     `json:"id"`
+	GroupID int     `json:"group_id"`
+	Shares  float64 `json:"shares"`
+	Limits  Limits  `json:"limits"`
+}
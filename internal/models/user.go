@@ -14,9 +14,28 @@ type User struct {
 
 // Group represents a research group
 type Group struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	CPUQuota  int       `json:"cpu_quota"`
-	Priority  int       `json:"priority"`
-	CreatedAt time.Time `json:"created_at"`
-}
\ No newline at end of file
+	ID               int       `json:"id"`
+	Name             string    `json:"name"`
+	DepartmentID     *int      `json:"department_id,omitempty"`
+	Shares           int       `json:"shares"` // Fair-share entitlement relative to sibling groups
+	CPUQuota         int       `json:"cpu_quota"`
+	Priority         int       `json:"priority"`
+	MaxWalltimeHours *float64  `json:"max_walltime_hours,omitempty"`
+	Limits           Limits    `json:"limits"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// Limits caps the jobs of a user or a whole group (null = unlimited). Running
+// limits hold jobs in the queue; the pending limit rejects new submissions.
+type Limits struct {
+	MaxRunningJobs *int `json:"max_running_jobs" binding:"omitempty,min=1"`
+	MaxCPUs        *int `json:"max_cpus" binding:"omitempty,min=1"`
+	MaxMemoryGB    *int `json:"max_memory_gb" binding:"omitempty,min=1"`
+	MaxGPUs        *int `json:"max_gpus" binding:"omitempty,min=1"`
+	MaxPendingJobs *int `json:"max_pending_jobs" binding:"omitempty,min=1"`
+}
+
+// SetWalltimeRequest sets a group's maximum walltime (null removes the limit)
+type SetWalltimeRequest struct {
+	MaxWalltimeHours *float64 `json:"max_walltime_hours" binding:"omitempty,gt=0"`
+}
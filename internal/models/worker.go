@@ -4,19 +4,71 @@ import "time"
 
 // Worker represents a compute node
 type Worker struct {
-	ID            int       `json:"id"`
-	Hostname      string    `json:"hostname"`
-	CPUCores      int       `json:"cpu_cores"`
-	MemoryGB      int       `json:"memory_gb"`
-	GPUCount      int       `json:"gpu_count"`
-	Status        string    `json:"status"`
-	LastHeartbeat time.Time `json:"last_heartbeat"`
-	CreatedAt     time.Time `json:"created_at"`
+	ID                int       `json:"id"`
+	Hostname          string    `json:"hostname"`
+	CPUCores          int       `json:"cpu_cores"`
+	MemoryGB          int       `json:"memory_gb"`
+	GPUCount          int       `json:"gpu_count"`
+	Status            string    `json:"status"`
+	IsAgent           bool      `json:"is_agent"`
+	AllocatedCPUCores int       `json:"allocated_cpu_cores"`
+	AllocatedMemoryGB int       `json:"allocated_memory_gb"`
+	AllocatedGPUs     int       `json:"allocated_gpus"`
+	LastHeartbeat     time.Time `json:"last_heartbeat"`
+	CreatedAt         time.Time `json:"created_at"`
 }
 
-// Worker status constants
+// Worker status constants (derived from allocated resources)
 const (
-	WorkerIdle    = "idle"
-	WorkerBusy    = "busy"
+	WorkerIdle    = "idle"  // Nothing allocated
+	WorkerMixed   = "mixed" // Some resources allocated, room for more jobs
+	WorkerFull    = "full"  // No CPU or memory left
 	WorkerOffline = "offline"
-)
\ No newline at end of file
+)
+
+// RegisterWorkerRequest is sent by a worker agent when it starts
+type RegisterWorkerRequest struct {
+	Hostname string `json:"hostname" binding:"required"`
+	CPUCores int    `json:"cpu_cores" binding:"required,min=1"`
+	MemoryGB int    `json:"memory_gb" binding:"required,min=1"`
+	GPUCount int    `json:"gpu_count" binding:"min=0"`
+}
+
+// RegisterWorkerResponse tells the agent its worker ID
+type RegisterWorkerResponse struct {
+	WorkerID int `json:"worker_id"`
+}
+
+// HeartbeatRequest reports the jobs an agent is currently running
+type HeartbeatRequest struct {
+	RunningJobs []int `json:"running_jobs"`
+}
+
+// HeartbeatResponse lists running jobs the agent must stop (cancelled or reassigned)
+type HeartbeatResponse struct {
+	StopJobs []int `json:"stop_jobs"`
+}
+
+// AssignedJob is a job handed to an agent through the claim API
+type AssignedJob struct {
+	JobID           int    `json:"job_id"`
+	Script          string `json:"script"`
+	CPUCores        int    `json:"cpu_cores"`
+	MemoryGB        int    `json:"memory_gb"`
+	GPUCount        int    `json:"gpu_count"`
+	OutputPath      string `json:"output_path"`
+	WalltimeSeconds int64  `json:"walltime_seconds"`       // 0 = no limit
+	ArrayJobID      *int   `json:"array_job_id,omitempty"` // Set for job array tasks
+	ArrayIndex      *int   `json:"array_index,omitempty"`
+}
+
+// ClaimJobsResponse holds the jobs newly assigned to an agent
+type ClaimJobsResponse struct {
+	Jobs []AssignedJob `json:"jobs"`
+}
+
+// JobResultRequest is posted by an agent when a job's process exits
+type JobResultRequest struct {
+	ExitCode     int    `json:"exit_code"`
+	ErrorMessage string `json:"error_message"`
+}
@@ -0,0 +1,207 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Spec describes a script to run as a child process
+type Spec struct {
+	Shell  string    // Interpreter used to run the script (e.g. /bin/bash)
+	Script string    // Script body passed to the shell with -c
+	Dir    string    // Working directory (empty = current directory)
+	Env    []string  // Extra environment variables (KEY=value)
+	Stdout io.Writer // Where stdout is written (nil = discarded)
+	Stderr io.Writer // Where stderr is written (nil = discarded)
+
+	// GracePeriod is how long the process group gets to exit after SIGTERM
+	// before it is sent SIGKILL when the context is cancelled
+	GracePeriod time.Duration
+
+	// ExitCodeFile, if set, receives the script's exit code when it exits, so
+	// the result survives a restart of the process that started it
+	ExitCodeFile string
+
+	// OnStart is called with the process ID once the process has started
+	OnStart func(pid int)
+}
+
+// Result holds the outcome of a finished process
+type Result struct {
+	ExitCode int
+	Stopped  bool  // The process was terminated because the context was cancelled
+	Err      error // Set when the process could not be started or waited on
+}
+
+// Run executes the script and blocks until it exits. Cancelling ctx sends
+// SIGTERM to the whole process group, then SIGKILL after spec.GracePeriod.
+func Run(ctx context.Context, spec Spec) Result {
+	cmd := exec.Command(spec.Shell, "-c", spec.Script)
+	if spec.ExitCodeFile != "" {
+		// A stale file from an earlier run must not be mistaken for this run's result
+		os.Remove(spec.ExitCodeFile)
+		cmd = exec.Command("/bin/sh", "-c", exitCodeWrapper, spec.Shell, spec.Script, spec.ExitCodeFile)
+	}
+	cmd.Dir = spec.Dir
+	cmd.Env = append(os.Environ(), spec.Env...)
+	cmd.Stdout = spec.Stdout
+	cmd.Stderr = spec.Stderr
+
+	// Run in its own process group so signals reach everything the script spawns
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		return Result{ExitCode: -1, Err: fmt.Errorf("failed to start process: %w", err)}
+	}
+
+	if spec.OnStart != nil {
+		spec.OnStart(cmd.Process.Pid)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return waitResult(err)
+	case <-ctx.Done():
+	}
+
+	// Ask the process group to stop, then force it
+	pgid := cmd.Process.Pid
+	syscall.Kill(-pgid, syscall.SIGTERM)
+
+	grace := time.NewTimer(spec.GracePeriod)
+	defer grace.Stop()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-grace.C:
+		syscall.Kill(-pgid, syscall.SIGKILL)
+		err = <-done
+	}
+
+	result := waitResult(err)
+	result.Stopped = true
+	return result
+}
+
+// exitCodeWrapper runs the script with the job shell ($0 -c $1) and writes
+// its exit code to $2
+const exitCodeWrapper = `"$0" -c "$1"; rc=$?; echo "$rc" > "$2"; exit "$rc"`
+
+// Adopt watches a process started by an earlier instance of this program
+// (which can no longer wait on it) until it exits, and reads its exit code
+// from exitCodeFile. Cancelling ctx stops its process group like Run does.
+func Adopt(ctx context.Context, pid int, gracePeriod time.Duration, exitCodeFile string) Result {
+	poll := time.NewTicker(time.Second)
+	defer poll.Stop()
+
+	stopped := false
+	cancelled := ctx.Done()
+	var kill <-chan time.Time
+	for ProcessExists(pid) {
+		select {
+		case <-poll.C:
+		case <-cancelled:
+			// Ask the process group to stop, then force it after the grace period
+			stopped = true
+			cancelled = nil
+			syscall.Kill(-pid, syscall.SIGTERM)
+			kill = time.After(gracePeriod)
+		case <-kill:
+			kill = nil
+			syscall.Kill(-pid, syscall.SIGKILL)
+		}
+	}
+
+	result := readExitCode(exitCodeFile)
+	result.Stopped = stopped
+	return result
+}
+
+// JobProcessAlive reports whether pid is still the process started for jobID,
+// so a recycled PID is not mistaken for the job
+func JobProcessAlive(pid, jobID int) bool {
+	if !ProcessExists(pid) {
+		return false
+	}
+
+	environ, err := os.ReadFile(fmt.Sprintf("/proc/%d/environ", pid))
+	if err != nil {
+		return false
+	}
+
+	marker := fmt.Sprintf("RCQ_JOB_ID=%d", jobID)
+	for _, kv := range strings.Split(string(environ), "\x00") {
+		if kv == marker {
+			return true
+		}
+	}
+	return false
+}
+
+// ProcessExists reports whether a process with the given PID exists
+func ProcessExists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// readExitCode reads the exit code written by exitCodeWrapper
+func readExitCode(path string) Result {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Result{ExitCode: -1, Err: errors.New("process exited without recording its exit code")}
+	}
+
+	code, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return Result{ExitCode: -1, Err: fmt.Errorf("invalid exit code file: %w", err)}
+	}
+	return Result{ExitCode: code}
+}
+
+// JobEnv returns the environment variables describing a job to its script
+func JobEnv(jobID, cpuCores, memoryGB, gpuCount int, outputDir string) []string {
+	return []string{
+		fmt.Sprintf("RCQ_JOB_ID=%d", jobID),
+		fmt.Sprintf("RCQ_CPU_CORES=%d", cpuCores),
+		fmt.Sprintf("RCQ_MEMORY_GB=%d", memoryGB),
+		fmt.Sprintf("RCQ_GPU_COUNT=%d", gpuCount),
+		fmt.Sprintf("RCQ_OUTPUT_DIR=%s", outputDir),
+	}
+}
+
+// ArrayEnv returns the environment variables identifying a job array task
+func ArrayEnv(arrayJobID, index int) []string {
+	return []string{
+		fmt.Sprintf("RCQ_ARRAY_JOB_ID=%d", arrayJobID),
+		fmt.Sprintf("RCQ_ARRAY_TASK_ID=%d", index),
+	}
+}
+
+// waitResult converts the error returned by Wait into a Result
+func waitResult(err error) Result {
+	if err == nil {
+		return Result{ExitCode: 0}
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// ExitCode is -1 when the process was killed by a signal
+		return Result{ExitCode: exitErr.ExitCode()}
+	}
+
+	return Result{ExitCode: -1, Err: err}
+}
@@ -0,0 +1,158 @@
+package scheduler
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+// workerStatusSQL recomputes a worker's status from its allocations.
+// GPU exhaustion alone does not make a worker full: CPU-only jobs can still use it.
+const workerStatusSQL = `
+	UPDATE workers w
+	SET status = CASE
+		WHEN a.cpu = 0 AND a.mem = 0 AND a.gpu = 0 THEN 'idle'
+		WHEN a.cpu >= w.cpu_cores OR a.mem >= w.memory_gb THEN 'full'
+		ELSE 'mixed'
+	END
+	FROM (
+		SELECT COALESCE(SUM(cpu_cores), 0) AS cpu,
+		       COALESCE(SUM(memory_gb), 0) AS mem,
+		       COALESCE(SUM(gpu_count), 0) AS gpu
+		FROM worker_allocations WHERE worker_id = $1
+	) a
+	WHERE w.id = $1 AND w.status != 'offline'
+`
+
+// FreeCPUCores returns the CPU cores not allocated to running jobs
+func (w *Worker) FreeCPUCores() int {
+	return w.CPUCores - w.AllocatedCPUCores
+}
+
+// FreeMemoryGB returns the memory not allocated to running jobs
+func (w *Worker) FreeMemoryGB() int {
+	return w.MemoryGB - w.AllocatedMemoryGB
+}
+
+// FreeGPUs returns the GPUs not allocated to running jobs
+func (w *Worker) FreeGPUs() int {
+	return w.GPUCount - w.AllocatedGPUs
+}
+
+// IsFull reports whether the worker has no CPU or memory left for another job
+func (w *Worker) IsFull() bool {
+	return w.FreeCPUCores() <= 0 || w.FreeMemoryGB() <= 0
+}
+
+// allocate reserves the job's resources on the worker (in memory only)
+func (w *Worker) allocate(job *JobWithPriority) {
+	w.AllocatedCPUCores += job.CPUCores
+	w.AllocatedMemoryGB += job.MemoryGB
+	w.AllocatedGPUs += job.GPUCount
+}
+
+// Errors returned when a job cannot be assigned
+var (
+	errJobUnavailable        = errors.New("job is no longer pending or is being started by another scheduler")
+	errInsufficientResources = errors.New("worker no longer has enough free resources")
+)
+
+// lockWorkerSQL locks a worker row so allocations on it are serialized.
+// Taking it before workerStatusSQL makes the status read committed allocations.
+const lockWorkerSQL = "SELECT status FROM workers WHERE id = $1 FOR UPDATE"
+
+// allocateWorker records the job's resources against the worker and updates its
+// status. It re-checks free capacity under the worker's row lock, so another
+// scheduler (or a stale view of the worker) can never over-allocate it.
+func (e *Executor) allocateWorker(tx *sql.Tx, job *JobWithPriority, worker *Worker) error {
+	var status string
+	if err := tx.QueryRow(lockWorkerSQL, worker.ID).Scan(&status); err != nil {
+		return fmt.Errorf("failed to lock worker: %w", err)
+	}
+	if status == models.WorkerOffline {
+		return errInsufficientResources
+	}
+
+	var freeCPU, freeMem, freeGPU int
+	err := tx.QueryRow(`
+		SELECT w.cpu_cores - COALESCE(SUM(a.cpu_cores), 0),
+		       w.memory_gb - COALESCE(SUM(a.memory_gb), 0),
+		       w.gpu_count - COALESCE(SUM(a.gpu_count), 0)
+		FROM workers w
+		LEFT JOIN worker_allocations a ON a.worker_id = w.id
+		WHERE w.id = $1
+		GROUP BY w.id
+	`, worker.ID).Scan(&freeCPU, &freeMem, &freeGPU)
+	if err != nil {
+		return fmt.Errorf("failed to check worker capacity: %w", err)
+	}
+	if job.CPUCores > freeCPU || job.MemoryGB > freeMem || job.GPUCount > freeGPU {
+		return errInsufficientResources
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO worker_allocations (job_id, worker_id, cpu_cores, memory_gb, gpu_count)
+		VALUES ($1, $2, $3, $4, $5)
+	`, job.ID, worker.ID, job.CPUCores, job.MemoryGB, job.GPUCount)
+	if err != nil {
+		return fmt.Errorf("failed to allocate worker: %w", err)
+	}
+
+	if _, err := tx.Exec(workerStatusSQL, worker.ID); err != nil {
+		return fmt.Errorf("failed to update worker status: %w", err)
+	}
+	return nil
+}
+
+// releaseWorker frees the resources held by a job. Safe to call more than once;
+// returns true only for the call that actually released the allocation.
+func (e *Executor) releaseWorker(jobID int) bool {
+	tx, err := e.db.Begin()
+	if err != nil {
+		log.Printf("Error releasing allocation for job %d: %v", jobID, err)
+		return false
+	}
+	defer tx.Rollback()
+
+	released, err := releaseAllocation(tx, jobID)
+	if err != nil {
+		log.Printf("Error releasing allocation for job %d: %v", jobID, err)
+		return false
+	}
+	if !released {
+		return false // Already released
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error releasing allocation for job %d: %v", jobID, err)
+		return false
+	}
+	return true
+}
+
+// releaseAllocation deletes a job's allocation and updates its worker's status
+// inside tx. Returns false if the job holds no allocation.
+func releaseAllocation(tx *sql.Tx, jobID int) (bool, error) {
+	var workerID int
+	err := tx.QueryRow(
+		"DELETE FROM worker_allocations WHERE job_id = $1 RETURNING worker_id", jobID,
+	).Scan(&workerID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var status string
+	if err := tx.QueryRow(lockWorkerSQL, workerID).Scan(&status); err != nil {
+		return false, fmt.Errorf("failed to lock worker %d: %w", workerID, err)
+	}
+	if _, err := tx.Exec(workerStatusSQL, workerID); err != nil {
+		return false, fmt.Errorf("failed to update status of worker %d: %w", workerID, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,72 @@
+package scheduler
+
+import (
+	"log"
+)
+
+// syncArrayStatuses updates each unfinished job array's status from its tasks:
+// running while any task runs, pending while any waits, and once all are done
+// failed if any failed, cancelled if any was cancelled, otherwise completed.
+// Jobs depending on an array see this status.
+func (s *Scheduler) syncArrayStatuses() {
+	rows, err := s.db.Query(`
+		UPDATE jobs p
+		SET status = t.status, started_at = t.started_at,
+		    completed_at = CASE WHEN t.status IN ('completed', 'failed', 'cancelled')
+		                        THEN t.completed_at END
+		FROM (
+			SELECT array_job_id,
+			       CASE WHEN bool_or(status = 'running') THEN 'running'
+			            WHEN bool_or(status = 'pending') THEN 'pending'
+			            WHEN bool_or(status = 'failed') THEN 'failed'
+			            WHEN bool_or(status = 'cancelled') THEN 'cancelled'
+			            ELSE 'completed'
+			       END AS status,
+			       MIN(started_at) AS started_at, MAX(completed_at) AS completed_at
+			FROM jobs
+			WHERE array_job_id IS NOT NULL
+			GROUP BY array_job_id
+		) t
+		WHERE p.id = t.array_job_id AND p.is_array
+		  AND p.status IN ('pending', 'running') AND p.status != t.status
+		RETURNING p.id, p.status
+	`)
+	if err != nil {
+		log.Printf("Error updating job array statuses: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var arrayID int
+		var status string
+		if err := rows.Scan(&arrayID, &status); err != nil {
+			continue
+		}
+		log.Printf("Job array %d is now %s", arrayID, status)
+	}
+}
+
+// getRunningArrayTasks returns the number of running tasks per job array
+func (s *Scheduler) getRunningArrayTasks() (map[int]int, error) {
+	rows, err := s.db.Query(`
+		SELECT array_job_id, COUNT(*)
+		FROM jobs
+		WHERE status = 'running' AND array_job_id IS NOT NULL
+		GROUP BY array_job_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	running := make(map[int]int)
+	for rows.Next() {
+		var arrayID, count int
+		if err := rows.Scan(&arrayID, &count); err != nil {
+			return nil, err
+		}
+		running[arrayID] = count
+	}
+	return running, rows.Err()
+}
@@ -0,0 +1,205 @@
+package scheduler
+
+import (
+	"sort"
+	"time"
+)
+
+// ActiveJob is a job currently holding resources on a worker
+type ActiveJob struct {
+	ID             int
+	WorkerID       int
+	CPUCores       int
+	MemoryGB       int
+	GPUCount       int
+	StartedAt      time.Time
+	EstimatedHours float64 // 0 = unknown, the job is assumed to never finish
+	UserID         int
+	GroupID        int
+	QoSID          int // 0 = none
+	PartitionID    int // 0 = none
+	ArrayJobID     int // 0 = not an array task
+}
+
+// reservation holds the earliest start promised to the highest-priority
+// blocked job of a partition
+type reservation struct {
+	jobID       int
+	partitionID int
+	workerID    int
+	startAt     time.Time
+
+	// Resources left on the reserved worker at startAt once the reserved job is
+	// placed; backfilled jobs that outlive startAt must fit in these
+	extraCPU int
+	extraMem int
+	extraGPU int
+}
+
+// backfillPlanner implements EASY backfill: in each partition, the first job
+// that cannot start gets a reservation, and lower-priority jobs only start if
+// they cannot delay any reservation
+type backfillPlanner struct {
+	now           time.Time
+	walltimeGrace float64
+	active        []ActiveJob
+	reservations  []*reservation
+}
+
+// newBackfillPlanner creates a planner from the jobs currently running
+func newBackfillPlanner(now time.Time, walltimeGrace float64, active []ActiveJob) *backfillPlanner {
+	return &backfillPlanner{now: now, walltimeGrace: walltimeGrace, active: active}
+}
+
+// endTime returns when a job started at startedAt is guaranteed to have stopped.
+// Jobs are killed at estimated_hours × grace, so that is the latest possible end.
+func (bp *backfillPlanner) endTime(startedAt time.Time, estimatedHours float64) (time.Time, bool) {
+	if estimatedHours <= 0 {
+		return time.Time{}, false
+	}
+	end := startedAt.Add(time.Duration(estimatedHours * bp.walltimeGrace * float64(time.Hour)))
+	if end.Before(bp.now) {
+		end = bp.now // Overdue: about to be killed
+	}
+	return end, true
+}
+
+// reserved reports whether the partition already has a reservation this cycle
+func (bp *backfillPlanner) reserved(partitionID int) bool {
+	for _, res := range bp.reservations {
+		if res.partitionID == partitionID {
+			return true
+		}
+	}
+	return false
+}
+
+// allows reports whether starting job on worker now cannot delay a reservation
+func (bp *backfillPlanner) allows(job *JobWithPriority, worker *Worker) bool {
+	for _, res := range bp.reservations {
+		if worker.ID != res.workerID || job.ID == res.jobID {
+			continue
+		}
+		if end, ok := bp.endTime(bp.now, job.EstimatedHours); ok && !end.After(res.startAt) {
+			continue
+		}
+		if job.CPUCores > res.extraCPU || job.MemoryGB > res.extraMem || job.GPUCount > res.extraGPU {
+			return false
+		}
+	}
+	return true
+}
+
+// started records a job started this cycle
+func (bp *backfillPlanner) started(job *JobWithPriority, worker *Worker) {
+	bp.active = append(bp.active, ActiveJob{
+		ID:             job.ID,
+		WorkerID:       worker.ID,
+		CPUCores:       job.CPUCores,
+		MemoryGB:       job.MemoryGB,
+		GPUCount:       job.GPUCount,
+		StartedAt:      bp.now,
+		EstimatedHours: job.EstimatedHours,
+	})
+
+	// A job still running at a reservation consumes the reserved worker's leftovers
+	for _, res := range bp.reservations {
+		if worker.ID != res.workerID {
+			continue
+		}
+		if end, ok := bp.endTime(bp.now, job.EstimatedHours); !ok || end.After(res.startAt) {
+			res.extraCPU -= job.CPUCores
+			res.extraMem -= job.MemoryGB
+			res.extraGPU -= job.GPUCount
+		}
+	}
+}
+
+// reserve finds the earliest time and worker the job could start on, among
+// the workers member accepts, assuming running jobs end at their walltime.
+// A worker already reserved for another partition is not reserved twice.
+// Returns nil if the job can never be placed.
+func (bp *backfillPlanner) reserve(job *JobWithPriority, workers []Worker, member func(*Worker) bool) *reservation {
+	var best *reservation
+	for i := range workers {
+		w := &workers[i]
+		if w.CPUCores < job.CPUCores || w.MemoryGB < job.MemoryGB || w.GPUCount < job.GPUCount {
+			continue // Too small even when empty
+		}
+		if !member(w) || bp.workerReserved(w.ID) {
+			continue
+		}
+
+		startAt, cpu, mem, gpu, ok := bp.earliestFit(job, w)
+		if !ok {
+			continue
+		}
+		if best == nil || startAt.Before(best.startAt) {
+			best = &reservation{
+				jobID:       job.ID,
+				partitionID: job.PartitionID,
+				workerID:    w.ID,
+				startAt:     startAt,
+				extraCPU:    cpu - job.CPUCores,
+				extraMem:    mem - job.MemoryGB,
+				extraGPU:    gpu - job.GPUCount,
+			}
+		}
+	}
+
+	if best != nil {
+		bp.reservations = append(bp.reservations, best)
+	}
+	return best
+}
+
+// reservationOn returns the reservation held on the worker, or nil
+func (bp *backfillPlanner) reservationOn(workerID int) *reservation {
+	for _, res := range bp.reservations {
+		if res.workerID == workerID {
+			return res
+		}
+	}
+	return nil
+}
+
+// workerReserved reports whether a reservation is already held on the worker
+func (bp *backfillPlanner) workerReserved(workerID int) bool {
+	return bp.reservationOn(workerID) != nil
+}
+
+// earliestFit walks the worker's running jobs in end order until enough is free for the job
+func (bp *backfillPlanner) earliestFit(job *JobWithPriority, w *Worker) (time.Time, int, int, int, bool) {
+	cpu, mem, gpu := w.FreeCPUCores(), w.FreeMemoryGB(), w.FreeGPUs()
+	fits := func() bool {
+		return cpu >= job.CPUCores && mem >= job.MemoryGB && gpu >= job.GPUCount
+	}
+	if fits() {
+		return bp.now, cpu, mem, gpu, true
+	}
+
+	type release struct {
+		at            time.Time
+		cpu, mem, gpu int
+	}
+	var releases []release
+	for _, a := range bp.active {
+		if a.WorkerID != w.ID {
+			continue
+		}
+		if end, ok := bp.endTime(a.StartedAt, a.EstimatedHours); ok {
+			releases = append(releases, release{end, a.CPUCores, a.MemoryGB, a.GPUCount})
+		}
+	}
+	sort.Slice(releases, func(i, j int) bool { return releases[i].at.Before(releases[j].at) })
+
+	for _, r := range releases {
+		cpu += r.cpu
+		mem += r.mem
+		gpu += r.gpu
+		if fits() {
+			return r.at, cpu, mem, gpu, true
+		}
+	}
+	return time.Time{}, 0, 0, 0, false
+}
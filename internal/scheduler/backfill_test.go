@@ -0,0 +1,151 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackfillReserve(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	all := func(*Worker) bool { return true }
+
+	// Worker 1 frees 2 cores in an hour and 4 more in two; worker 2 is busy for three
+	workers := []Worker{
+		{ID: 1, CPUCores: 8, MemoryGB: 32, AllocatedCPUCores: 6, AllocatedMemoryGB: 6},
+		{ID: 2, CPUCores: 8, MemoryGB: 32, AllocatedCPUCores: 8, AllocatedMemoryGB: 8},
+	}
+	active := []ActiveJob{
+		{ID: 1, WorkerID: 1, CPUCores: 2, MemoryGB: 2, StartedAt: now, EstimatedHours: 1},
+		{ID: 2, WorkerID: 1, CPUCores: 4, MemoryGB: 4, StartedAt: now, EstimatedHours: 2},
+		{ID: 3, WorkerID: 2, CPUCores: 8, MemoryGB: 8, StartedAt: now, EstimatedHours: 3},
+	}
+
+	tests := []struct {
+		name     string
+		job      JobWithPriority
+		worker   int
+		startAt  time.Time
+		extraCPU int
+	}{
+		{"fits now", JobWithPriority{ID: 10, CPUCores: 2, MemoryGB: 2}, 1, now, 0},
+		{"waits for one job", JobWithPriority{ID: 10, CPUCores: 4, MemoryGB: 2}, 1, now.Add(time.Hour), 0},
+		{"waits for both jobs", JobWithPriority{ID: 10, CPUCores: 6, MemoryGB: 2}, 1, now.Add(2 * time.Hour), 2},
+		{"needs all of a worker", JobWithPriority{ID: 10, CPUCores: 8, MemoryGB: 8}, 1, now.Add(2 * time.Hour), 0},
+	}
+	for _, tt := range tests {
+		bp := newBackfillPlanner(now, 1, active)
+		res := bp.reserve(&tt.job, workers, all)
+		if res == nil {
+			t.Errorf("%s: no reservation", tt.name)
+			continue
+		}
+		if res.workerID != tt.worker || !res.startAt.Equal(tt.startAt) || res.extraCPU != tt.extraCPU {
+			t.Errorf("%s: got worker %d at %v with %d CPU left, want worker %d at %v with %d",
+				tt.name, res.workerID, res.startAt, res.extraCPU, tt.worker, tt.startAt, tt.extraCPU)
+		}
+	}
+
+	// Only workers the partition accepts are reserved
+	bp := newBackfillPlanner(now, 1, active)
+	res := bp.reserve(&JobWithPriority{ID: 10, CPUCores: 8, MemoryGB: 8}, workers, func(w *Worker) bool { return w.ID == 2 })
+	if res == nil || res.workerID != 2 || !res.startAt.Equal(now.Add(3*time.Hour)) {
+		t.Errorf("member workers: got %+v, want worker 2 in three hours", res)
+	}
+
+	// The walltime grace factor pushes the reservation back
+	bp = newBackfillPlanner(now, 1.5, active)
+	res = bp.reserve(&JobWithPriority{ID: 10, CPUCores: 8, MemoryGB: 8}, workers, all)
+	if res == nil || res.workerID != 1 || !res.startAt.Equal(now.Add(3*time.Hour)) {
+		t.Errorf("grace factor: got %+v, want worker 1 in three hours", res)
+	}
+}
+
+func TestBackfillReserveNever(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	all := func(*Worker) bool { return true }
+	workers := []Worker{{ID: 1, CPUCores: 8, MemoryGB: 32, AllocatedCPUCores: 8, AllocatedMemoryGB: 8}}
+
+	// A running job without an estimate is assumed to never end
+	unknown := []ActiveJob{{ID: 1, WorkerID: 1, CPUCores: 8, MemoryGB: 8, StartedAt: now}}
+	if res := newBackfillPlanner(now, 1, unknown).reserve(&JobWithPriority{CPUCores: 1, MemoryGB: 1}, workers, all); res != nil {
+		t.Errorf("running job without an estimate: got %+v", res)
+	}
+
+	// Bigger than the worker even when it is empty
+	if res := newBackfillPlanner(now, 1, nil).reserve(&JobWithPriority{CPUCores: 16, MemoryGB: 1}, workers, all); res != nil {
+		t.Errorf("job bigger than the worker: got %+v", res)
+	}
+
+	// An overdue job is about to be killed, so the reservation is now
+	overdue := []ActiveJob{{ID: 1, WorkerID: 1, CPUCores: 8, MemoryGB: 8, StartedAt: now.Add(-5 * time.Hour), EstimatedHours: 2}}
+	res := newBackfillPlanner(now, 1, overdue).reserve(&JobWithPriority{CPUCores: 8, MemoryGB: 8}, workers, all)
+	if res == nil || !res.startAt.Equal(now) {
+		t.Errorf("overdue job: got %+v, want a reservation now", res)
+	}
+
+	// A worker is reserved for one partition at a time
+	bp := newBackfillPlanner(now, 1, overdue)
+	bp.reserve(&JobWithPriority{ID: 10, CPUCores: 8, MemoryGB: 8, PartitionID: 1}, workers, all)
+	if res := bp.reserve(&JobWithPriority{ID: 11, CPUCores: 1, MemoryGB: 1, PartitionID: 2}, workers, all); res != nil {
+		t.Errorf("worker reserved twice: %+v", res)
+	}
+	if !bp.reserved(1) || bp.reserved(2) {
+		t.Error("expected a reservation for partition 1 only")
+	}
+}
+
+func TestBackfillAllows(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Worker 1 has 16 cores, 12 of them busy for another hour. The blocked job
+	// needs 14, so it gets worker 1 in an hour with 2 cores to spare.
+	workers := []Worker{
+		{ID: 1, CPUCores: 16, MemoryGB: 64, AllocatedCPUCores: 12, AllocatedMemoryGB: 12},
+		{ID: 2, CPUCores: 4, MemoryGB: 64},
+	}
+	active := []ActiveJob{{ID: 1, WorkerID: 1, CPUCores: 12, MemoryGB: 12, StartedAt: now, EstimatedHours: 1}}
+	blocked := JobWithPriority{ID: 10, CPUCores: 14, MemoryGB: 14}
+
+	bp := newBackfillPlanner(now, 1, active)
+	if res := bp.reserve(&blocked, workers, func(*Worker) bool { return true }); res == nil || res.workerID != 1 || res.extraCPU != 2 {
+		t.Fatalf("got reservation %+v, want worker 1 with 2 CPU left", res)
+	}
+
+	tests := []struct {
+		name   string
+		job    JobWithPriority
+		worker int
+		want   bool
+	}{
+		{"ends before the reservation", JobWithPriority{ID: 20, CPUCores: 4, MemoryGB: 4, EstimatedHours: 0.5}, 1, true},
+		{"ends as it starts", JobWithPriority{ID: 20, CPUCores: 4, MemoryGB: 4, EstimatedHours: 1}, 1, true},
+		{"outlives it in the leftovers", JobWithPriority{ID: 20, CPUCores: 2, MemoryGB: 2, EstimatedHours: 3}, 1, true},
+		{"outlives it beyond the leftover CPU", JobWithPriority{ID: 20, CPUCores: 4, MemoryGB: 2, EstimatedHours: 3}, 1, false},
+		{"outlives it beyond the leftover memory", JobWithPriority{ID: 20, CPUCores: 1, MemoryGB: 60, EstimatedHours: 3}, 1, false},
+		{"no estimate, in the leftovers", JobWithPriority{ID: 20, CPUCores: 1, MemoryGB: 1}, 1, true},
+		{"no estimate, beyond the leftovers", JobWithPriority{ID: 20, CPUCores: 4, MemoryGB: 4}, 1, false},
+		{"another worker", JobWithPriority{ID: 20, CPUCores: 4, MemoryGB: 4, EstimatedHours: 3}, 2, true},
+		{"the reserved job", blocked, 1, true},
+	}
+	for _, tt := range tests {
+		if got := bp.allows(&tt.job, &workers[tt.worker-1]); got != tt.want {
+			t.Errorf("%s: allows = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+
+	// Jobs still running at the reservation use up the leftovers
+	long := JobWithPriority{ID: 30, CPUCores: 1, MemoryGB: 1, EstimatedHours: 3}
+	short := JobWithPriority{ID: 40, CPUCores: 1, MemoryGB: 1, EstimatedHours: 0.5}
+	bp.started(&short, &workers[0])
+	bp.started(&long, &workers[0])
+	if !bp.allows(&long, &workers[0]) {
+		t.Error("one spare core left: expected a long job to be allowed")
+	}
+	bp.started(&long, &workers[0])
+	if bp.allows(&long, &workers[0]) {
+		t.Error("no spare cores left: expected a long job to be refused")
+	}
+	if !bp.allows(&short, &workers[0]) {
+		t.Error("no spare cores left: expected a short job to be allowed")
+	}
+}
@@ -0,0 +1,64 @@
+package scheduler
+
+import (
+	"log"
+	"time"
+)
+
+// dependencySatisfiedSQL is true when the dependency row d on parent job p is satisfied
+const dependencySatisfiedSQL = `(
+	(d.dependency_type = 'afterok' AND p.status = 'completed') OR
+	(d.dependency_type = 'afterany' AND p.status IN ('completed', 'failed', 'cancelled')) OR
+	(d.dependency_type = 'afternotok' AND p.status IN ('failed', 'cancelled'))
+)`
+
+// dependencyNeverSatisfiedSQL is true when parent job p finished in a state that can never satisfy d
+const dependencyNeverSatisfiedSQL = `(
+	(d.dependency_type = 'afterok' AND p.status IN ('failed', 'cancelled')) OR
+	(d.dependency_type = 'afternotok' AND p.status = 'completed')
+)`
+
+// unmetDependenciesSQL is true when pending job j still waits on a parent.
+// Array tasks share the dependencies declared on their array.
+const unmetDependenciesSQL = `EXISTS (
+	SELECT 1 FROM job_dependencies d
+	JOIN jobs p ON p.id = d.depends_on_job_id
+	WHERE d.job_id = COALESCE(j.array_job_id, j.id) AND NOT ` + dependencySatisfiedSQL + `
+)`
+
+// cancelUnsatisfiableJobs cancels pending jobs whose dependencies can never be met,
+// repeating until the cancellation has cascaded through the whole DAG
+func (s *Scheduler) cancelUnsatisfiableJobs() {
+	for {
+		rows, err := s.db.Query(`
+			UPDATE jobs j
+			SET status = 'cancelled', completed_at = $1,
+			    error_message = 'dependency never satisfied: job ' || p.id || ' is ' || p.status ||
+			                    ' (' || d.dependency_type || ')'
+			FROM job_dependencies d
+			JOIN jobs p ON p.id = d.depends_on_job_id
+			WHERE d.job_id = COALESCE(j.array_job_id, j.id) AND j.status = 'pending'
+			  AND `+dependencyNeverSatisfiedSQL+`
+			RETURNING j.id, p.id
+		`, time.Now())
+		if err != nil {
+			log.Printf("Error cancelling jobs with failed dependencies: %v", err)
+			return
+		}
+
+		cancelled := 0
+		for rows.Next() {
+			var jobID, parentID int
+			if err := rows.Scan(&jobID, &parentID); err != nil {
+				continue
+			}
+			log.Printf("Cancelled job %d: dependency on job %d can never be satisfied", jobID, parentID)
+			cancelled++
+		}
+		rows.Close()
+
+		if cancelled == 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package scheduler
+
+import (
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/samik-k21/research-compute-queue/internal/database"
+)
+
+// listenInterval is how often an idle listener checks its connection
+const listenInterval = 90 * time.Second
+
+// listen forwards scheduler notifications to s.wake until the scheduler stops.
+// The ticker still runs, so a lost notification only delays a job.
+func (s *Scheduler) listen() {
+	listener := pq.NewListener(s.databaseURL, 10*time.Second, time.Minute,
+		func(ev pq.ListenerEventType, err error) {
+			if err != nil {
+				log.Printf("Scheduler event listener: %v", err)
+			}
+		})
+	defer listener.Close()
+
+	if err := listener.Listen(database.SchedulerChannel); err != nil {
+		log.Printf("Error listening for scheduler events, relying on the ticker: %v", err)
+		return
+	}
+	log.Printf("Listening for scheduler events on %q", database.SchedulerChannel)
+
+	for {
+		select {
+		case n := <-listener.Notify:
+			// n is nil after a reconnect; events may have been missed, so wake anyway
+			if n != nil {
+				log.Printf("Scheduler event: %s", n.Extra)
+			}
+			s.triggerCycle()
+		case <-time.After(listenInterval):
+			go listener.Ping()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// triggerCycle requests a scheduling cycle without blocking. Requests arriving
+// while one is already queued are merged into it.
+func (s *Scheduler) triggerCycle() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
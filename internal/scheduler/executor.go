@@ -1,48 +1,232 @@
 package scheduler
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
+	"github.com/samik-k21/research-compute-queue/internal/config"
 	"github.com/samik-k21/research-compute-queue/internal/database"
+	"github.com/samik-k21/research-compute-queue/internal/models"
+	"github.com/samik-k21/research-compute-queue/internal/runner"
+)
+
+// Executor modes
+const (
+	ExecutorLocal    = "local"    // Run job scripts as local child processes
+	ExecutorSimulate = "simulate" // Sleep for estimated_hours and always succeed
 )
 
 // Executor handles job execution
 type Executor struct {
-	db *database.DB
+	db            *database.DB
+	mode          string
+	shell         string
+	outputDir     string
+	cancelGrace   time.Duration
+	walltimeGrace float64
+	replicaID     string // Recorded on local jobs so only this replica watches them
+
+	mu      sync.Mutex
+	running map[int]*runningJob // Jobs executing in this process, by job ID
+}
+
+// runningJob tracks a job executing in this process so it can be stopped
+type runningJob struct {
+	stop       context.CancelFunc
+	stopReason string      // Why the job was asked to stop (empty while running normally)
+	walltime   *time.Timer // Fires when the job exceeds its walltime (nil = no limit)
 }
 
 // NewExecutor creates a new executor
-func NewExecutor(db *database.DB) *Executor {
-	return &Executor{db: db}
+func NewExecutor(db *database.DB, cfg *config.Config, replicaID string) *Executor {
+	// Store absolute paths so output_path stays valid regardless of working directory
+	outputDir := cfg.OutputDirectory
+	if absDir, err := filepath.Abs(outputDir); err == nil {
+		outputDir = absDir
+	}
+	return &Executor{
+		db:            db,
+		mode:          cfg.ExecutorMode,
+		shell:         cfg.JobShell,
+		outputDir:     outputDir,
+		cancelGrace:   time.Duration(cfg.CancelGraceSecs) * time.Second,
+		walltimeGrace: cfg.WalltimeGraceFactor,
+		replicaID:     replicaID,
+		running:       make(map[int]*runningJob),
+	}
 }
 
 // StartJob assigns a job to a worker and starts it
 func (e *Executor) StartJob(job *JobWithPriority, worker *Worker) error {
 	now := time.Now()
 	
-	// Update job status to running
-	_, err := e.db.Exec(`
-		UPDATE jobs
-		SET status = 'running', started_at = $1, worker_id = $2
-		WHERE id = $3
-	`, now, worker.ID, job.ID)
+	// Each job gets its own output directory for stdout/stderr
+	outputPath := filepath.Join(e.outputDir, fmt.Sprintf("job-%d", job.ID))
+	if err := os.MkdirAll(outputPath, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	
+	// Register the job first so a cancellation racing with the start still reaches it
+	ctx := e.track(job.ID)
 	
+	if err := e.assignJob(job, worker, now, outputPath); err != nil {
+		e.untrack(job.ID)
+		return err
+	}
+	
+	// Agent workers pull the job through the claim API and run it themselves
+	if worker.IsAgent {
+		e.untrack(job.ID)
+		log.Printf("Assigned job %d to agent worker %s", job.ID, worker.Hostname)
+		return nil
+	}
+	
+	log.Printf("Started job %d on worker %s (%s mode)", job.ID, worker.Hostname, e.mode)
+	
+	// Enforce walltime: estimated_hours × grace factor
+	if job.EstimatedHours > 0 {
+		e.enforceWalltime(job, now)
+	}
+	
+	// Execute job in background
+	if e.mode == ExecutorSimulate {
+		go e.simulateJobExecution(ctx, job, worker, outputPath)
+	} else {
+		go e.runJobProcess(ctx, job, worker, outputPath)
+	}
+	
+	return nil
+}
+
+// assignJob marks the job running on the worker and records its allocation in
+// one transaction. The job row is locked with SKIP LOCKED, so if another
+// scheduler is starting it, or it was cancelled since the cycle read it, this fails.
+func (e *Executor) assignJob(job *JobWithPriority, worker *Worker, now time.Time, outputPath string) error {
+	tx, err := e.db.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to start job: %w", err)
 	}
+	defer tx.Rollback()
+	
+	var id int
+	err = tx.QueryRow(
+		"SELECT id FROM jobs WHERE id = $1 AND status = 'pending' FOR UPDATE SKIP LOCKED", job.ID,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return errJobUnavailable
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock job: %w", err)
+	}
 	
-	log.Printf("Started job %d on worker %s", job.ID, worker.Hostname)
+	_, err = tx.Exec(`
+		UPDATE jobs
+		SET status = 'running', started_at = $1, worker_id = $2, output_path = $3, pid = NULL,
+		    attempt = attempt + 1, replica_id = $4
+		WHERE id = $5
+	`, now, worker.ID, outputPath, sql.NullString{String: e.replicaID, Valid: !worker.IsAgent}, job.ID)
+	if err != nil {
+		return fmt.Errorf("failed to start job: %w", err)
+	}
 	
-	// Simulate job execution in background
-	go e.simulateJobExecution(job, worker)
+	// Reserve the job's resources on the worker
+	if err := e.allocateWorker(tx, job, worker); err != nil {
+		return err
+	}
 	
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to start job: %w", err)
+	}
 	return nil
 }
 
-// simulateJobExecution simulates a job running (since we don't have real compute)
-func (e *Executor) simulateJobExecution(job *JobWithPriority, worker *Worker) {
+// runJobProcess runs the job script as a local child process
+func (e *Executor) runJobProcess(ctx context.Context, job *JobWithPriority, worker *Worker, outputPath string) {
+	defer e.untrack(job.ID)
+	
+	stdout, stderr, err := openOutputFiles(outputPath)
+	if err != nil {
+		e.completeJob(job.ID, worker.ID, -1, err.Error())
+		return
+	}
+	defer stdout.Close()
+	defer stderr.Close()
+	
+	result := runner.Run(ctx, runner.Spec{
+		Shell:       e.shell,
+		Script:      job.Script,
+		Dir:         outputPath,
+		Stdout:      stdout,
+		Stderr:      stderr,
+		GracePeriod: e.cancelGrace,
+		Env:         jobEnv(job, outputPath),
+		
+		// Recorded so the job can be re-adopted if the server restarts while it runs
+		ExitCodeFile: filepath.Join(outputPath, models.ExitCodeFile),
+		OnStart: func(pid int) {
+			if _, err := e.db.Exec("UPDATE jobs SET pid = $1 WHERE id = $2", pid, job.ID); err != nil {
+				log.Printf("Error recording pid of job %d: %v", job.ID, err)
+			}
+		},
+	})
+	
+	e.completeJob(job.ID, worker.ID, result.ExitCode, e.resultMessage(job.ID, result))
+}
+
+// AdoptJob takes over a local job process started before the server restarted.
+// It is watched until it exits and can be cancelled or hit its walltime as usual.
+func (e *Executor) AdoptJob(job *JobWithPriority, workerID, pid int, startedAt time.Time, outputPath string) {
+	e.mu.Lock()
+	_, tracked := e.running[job.ID]
+	e.mu.Unlock()
+	if tracked {
+		return // Already watched by this process
+	}
+	
+	ctx := e.track(job.ID)
+	if job.EstimatedHours > 0 {
+		e.enforceWalltime(job, startedAt)
+	}
+	
+	go func() {
+		defer e.untrack(job.ID)
+		
+		result := runner.Adopt(ctx, pid, e.cancelGrace, filepath.Join(outputPath, models.ExitCodeFile))
+		e.completeJob(job.ID, workerID, result.ExitCode, e.resultMessage(job.ID, result))
+	}()
+}
+
+// jobEnv returns the environment variables passed to the job's script
+func jobEnv(job *JobWithPriority, outputPath string) []string {
+	env := runner.JobEnv(job.ID, job.CPUCores, job.MemoryGB, job.GPUCount, outputPath)
+	if job.ArrayJobID != 0 {
+		env = append(env, runner.ArrayEnv(job.ArrayJobID, job.ArrayIndex)...)
+	}
+	return env
+}
+
+// resultMessage returns the error_message for a finished process (empty on success)
+func (e *Executor) resultMessage(jobID int, result runner.Result) string {
+	if result.Stopped {
+		return e.stopReason(jobID)
+	} else if result.Err != nil {
+		return result.Err.Error()
+	} else if result.ExitCode != 0 {
+		return fmt.Sprintf("process exited with code %d", result.ExitCode)
+	}
+	return ""
+}
+
+// simulateJobExecution simulates a job running (for demo environments without real compute)
+func (e *Executor) simulateJobExecution(ctx context.Context, job *JobWithPriority, worker *Worker, outputPath string) {
+	defer e.untrack(job.ID)
+	
 	// Simulate execution time (use estimated hours, or default to 1-5 minutes for testing)
 	var duration time.Duration
 	if job.EstimatedHours > 0 {
@@ -55,49 +239,182 @@ func (e *Executor) simulateJobExecution(job *JobWithPriority, worker *Worker) {
 	
 	log.Printf("Job %d will run for %v", job.ID, duration)
 	
-	// Wait for "execution" to complete
-	time.Sleep(duration)
+	stdout, stderr, err := openOutputFiles(outputPath)
+	if err != nil {
+		e.completeJob(job.ID, worker.ID, -1, err.Error())
+		return
+	}
+	defer stdout.Close()
+	defer stderr.Close()
+	fmt.Fprintf(stdout, "Simulated execution of job %d on %s for %v\n", job.ID, worker.Hostname, duration)
 	
-	// Mark job as completed
-	e.completeJob(job.ID, worker.ID, true)
+	// Wait for "execution" to complete, or wake early if the job is stopped
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	
+	select {
+	case <-timer.C:
+		e.completeJob(job.ID, worker.ID, 0, "")
+	case <-ctx.Done():
+		e.completeJob(job.ID, worker.ID, -1, e.stopReason(job.ID))
+	}
 }
 
-// completeJob marks a job as completed or failed
-func (e *Executor) completeJob(jobID int, workerID int, success bool) {
+// CancelJob stops a job running in this process. Returns false if the job
+// is not running here (e.g. it already finished).
+func (e *Executor) CancelJob(jobID int, reason string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	
+	rj, ok := e.running[jobID]
+	if !ok {
+		return false
+	}
+	if rj.stopReason == "" {
+		rj.stopReason = reason
+	}
+	rj.stop()
+	
+	log.Printf("Stopping job %d: %s", jobID, reason)
+	return true
+}
+
+// enforceWalltime kills the job if it is still running after its walltime
+func (e *Executor) enforceWalltime(job *JobWithPriority, startedAt time.Time) {
+	limit := time.Duration(job.EstimatedHours * e.walltimeGrace * float64(time.Hour))
+	reason := fmt.Sprintf("%s: exceeded walltime of %v (estimated %.2fh × %.2f grace)",
+		models.TimeoutReason, limit.Round(time.Second), job.EstimatedHours, e.walltimeGrace)
+	
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	
+	if rj, ok := e.running[job.ID]; ok {
+		rj.walltime = time.AfterFunc(time.Until(startedAt.Add(limit)), func() {
+			e.CancelJob(job.ID, reason)
+		})
+	}
+}
+
+// track registers a running job and returns the context that stops it
+func (e *Executor) track(jobID int) context.Context {
+	ctx, stop := context.WithCancel(context.Background())
+	
+	e.mu.Lock()
+	e.running[jobID] = &runningJob{stop: stop}
+	e.mu.Unlock()
+	
+	return ctx
+}
+
+// trackedJobs returns the IDs of jobs executing in this process
+func (e *Executor) trackedJobs() []int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	
+	ids := make([]int, 0, len(e.running))
+	for jobID := range e.running {
+		ids = append(ids, jobID)
+	}
+	return ids
+}
+
+// untrack removes a finished job from the running set
+func (e *Executor) untrack(jobID int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	
+	if rj, ok := e.running[jobID]; ok {
+		if rj.walltime != nil {
+			rj.walltime.Stop()
+		}
+		rj.stop()
+		delete(e.running, jobID)
+	}
+}
+
+// stopReason returns why a job was asked to stop
+func (e *Executor) stopReason(jobID int) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	
+	if rj, ok := e.running[jobID]; ok && rj.stopReason != "" {
+		return rj.stopReason
+	}
+	return "job was stopped"
+}
+
+// openOutputFiles creates the stdout and stderr files in a job's output directory
+func openOutputFiles(outputPath string) (*os.File, *os.File, error) {
+	stdout, err := os.Create(filepath.Join(outputPath, models.StdoutFile))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create stdout file: %w", err)
+	}
+	
+	stderr, err := os.Create(filepath.Join(outputPath, models.StderrFile))
+	if err != nil {
+		stdout.Close()
+		return nil, nil, fmt.Errorf("failed to create stderr file: %w", err)
+	}
+	
+	return stdout, stderr, nil
+}
+
+// completeJob marks a job as completed or failed based on its exit code
+func (e *Executor) completeJob(jobID int, workerID int, exitCode int, errorMessage string) {
 	now := time.Now()
 	status := "completed"
-	exitCode := 0
 	
-	if !success {
+	if exitCode != 0 || errorMessage != "" {
 		status = "failed"
-		exitCode = 1
+		
+		// A preempted job is requeued or cancelled per its preempt mode instead
+		if e.finishPreemption(jobID, exitCode) {
+			return
+		}
+		
+		// Requeue instead of failing while the retry policy allows it
+		if e.retryJob(jobID, exitCode, errorMessage) {
+			return
+		}
 	}
 	
-	// Update job status
-	_, err := e.db.Exec(`
+	// Update job status; a job cancelled while running keeps its cancelled status
+	result, err := e.db.Exec(`
 		UPDATE jobs
-		SET status = $1, completed_at = $2, exit_code = $3
-		WHERE id = $4
-	`, status, now, exitCode, jobID)
+		SET status = $1, completed_at = $2, exit_code = $3, error_message = NULLIF($4, '')
+		WHERE id = $5 AND status = 'running'
+	`, status, now, exitCode, errorMessage, jobID)
 	
 	if err != nil {
 		log.Printf("Error completing job %d: %v", jobID, err)
 		return
 	}
 	
-	// Mark worker as idle again
-	_, err = e.db.Exec("UPDATE workers SET status = 'idle' WHERE id = $1", workerID)
-	if err != nil {
-		log.Printf("Error marking worker %d as idle: %v", workerID, err)
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		// Record when the process actually stopped so usage covers the real runtime
+		_, err = e.db.Exec(`
+			UPDATE jobs
+			SET completed_at = $1, exit_code = $2
+			WHERE id = $3 AND status = 'cancelled'
+		`, now, exitCode, jobID)
+		if err != nil {
+			log.Printf("Error recording stop time for job %d: %v", jobID, err)
+		}
+		status = "cancelled"
 	}
 	
-	// Log usage for fair-share calculation
-	e.logUsage(jobID)
+	// Free the job's resources on the worker and log usage for fair-share
+	// calculation, once per run even if the completion is reported twice
+	if e.releaseWorker(jobID) {
+		e.recordAttempt(jobID, exitCode, status, errorMessage)
+		e.logUsage(jobID)
+		database.Notify(e.db, database.EventJobCompleted, jobID)
+	}
 	
-	log.Printf("Job %d completed with status: %s", jobID, status)
+	log.Printf("Job %d on worker %d completed with status: %s", jobID, workerID, status)
 }
 
-// logUsage records CPU hours used for fair-share tracking
+// logUsage records CPU hours used by the job's latest attempt for fair-share tracking
 func (e *Executor) logUsage(jobID int) {
 	// Calculate CPU hours used
 	var groupID int
@@ -105,9 +422,12 @@ func (e *Executor) logUsage(jobID int) {
 	var startedAt, completedAt time.Time
 	
 	err := e.db.QueryRow(`
-		SELECT group_id, cpu_cores, started_at, completed_at
-		FROM jobs
-		WHERE id = $1
+		SELECT j.group_id, j.cpu_cores, a.started_at, a.ended_at
+		FROM job_attempts a
+		JOIN jobs j ON j.id = a.job_id
+		WHERE a.job_id = $1
+		ORDER BY a.id DESC
+		LIMIT 1
 	`, jobID).Scan(&groupID, &cpuCores, &startedAt, &completedAt)
 	
 	if err != nil {
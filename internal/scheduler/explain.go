@@ -0,0 +1,132 @@
+package scheduler
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+// rankedQueue is every pending job in the order a scheduling cycle would
+// consider it, and what the order was computed from
+type rankedQueue struct {
+	queued     []JobWithPriority // Eligible now, in scheduling order
+	waiting    []JobWithPriority // Waiting on a dependency or a retry backoff, by priority
+	states     map[int]pendingState
+	ctx        *priorityContext
+	partitions map[int]*partition
+}
+
+// rankQueue ranks the pending jobs the way a scheduling cycle does
+func (s *Scheduler) rankQueue() (*rankedQueue, error) {
+	pending, err := s.queryPendingJobs(pendingJobsSQL + ` ORDER BY j.submitted_at ASC`)
+	if err != nil {
+		return nil, err
+	}
+	q := &rankedQueue{}
+	if len(pending) == 0 {
+		return q, nil
+	}
+	q.states, err = s.getPendingStates()
+	if err != nil {
+		return nil, err
+	}
+	q.ctx, err = s.priorityCalc.loadPriorityContext()
+	if err != nil {
+		return nil, err
+	}
+	q.partitions, err = s.getPartitions()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, job := range pending {
+		if q.states[job.ID].waiting {
+			q.waiting = append(q.waiting, job)
+		} else {
+			q.queued = append(q.queued, job)
+		}
+	}
+	s.priorityCalc.rank(q.queued, q.ctx)
+	sortJobsByPartitionTier(q.queued, q.partitions)
+	s.priorityCalc.rank(q.waiting, q.ctx)
+	return q, nil
+}
+
+// ExplainQueue ranks the pending jobs the way a scheduling cycle does and
+// breaks down each one's priority. Jobs waiting on a dependency or a retry
+// backoff come last, without a rank.
+func (s *Scheduler) ExplainQueue() ([]models.JobPriority, error) {
+	q, err := s.rankQueue()
+	if err != nil {
+		return nil, err
+	}
+	queued, waiting, states, ctx := q.queued, q.waiting, q.states, q.ctx
+
+	explained := make([]models.JobPriority, 0, len(queued)+len(waiting))
+	for i := range queued {
+		e := s.priorityCalc.explain(&queued[i], ctx)
+		rank := i + 1
+		e.Rank = &rank
+		reason := states[queued[i].ID].reason
+		e.PendingReason, e.PendingDetail = reason.code, reason.detail
+		explained = append(explained, e)
+	}
+	for i := range waiting {
+		e := s.priorityCalc.explain(&waiting[i], ctx)
+		reason := states[waiting[i].ID].reason
+		e.PendingReason, e.PendingDetail = reason.code, reason.detail
+		explained = append(explained, e)
+	}
+	return explained, nil
+}
+
+// explain breaks a job's priority down into its weighted factors
+func (pc *PriorityCalculator) explain(job *JobWithPriority, ctx *priorityContext) models.JobPriority {
+	f := pc.factors(job, ctx)
+	w := pc.weights
+
+	wait := ctx.now.Sub(job.SubmittedAt)
+	if wait < 0 {
+		wait = 0
+	}
+	fairShareRank := ctx.fairShare.userCount - int(math.Round(f.fairShare*float64(ctx.fairShare.userCount))) + 1
+	jobSize := fmt.Sprintf("%d of %d online CPU cores", job.CPUCores, ctx.clusterCPUs)
+	if pc.favorSmall {
+		jobSize += ", favouring small jobs"
+	}
+
+	e := models.JobPriority{
+		JobID:         job.ID,
+		UserID:        job.UserID,
+		GroupID:       job.GroupID,
+		Priority:      pc.priority(f),
+		BasePriority:  f.base,
+		GroupPriority: job.GroupPriority,
+		JobPriority:   job.Priority,
+		FairSharePath: ctx.fairShare.path(job.UserID),
+	}
+	for _, factor := range []struct {
+		name          string
+		value, weight float64
+		detail        string
+	}{
+		{"age", f.age, w.Age, fmt.Sprintf("waiting %s of %s", wait.Round(time.Minute), pc.maxAge)},
+		{"fair_share", f.fairShare, w.FairShare,
+			fmt.Sprintf("user ranked %d of %d in the fair-share tree", fairShareRank, ctx.fairShare.userCount)},
+		{"job_size", f.jobSize, w.JobSize, jobSize},
+		{"qos", f.qos, w.QoS, fmt.Sprintf("QoS priority factor %.2f of at most %.2f", job.QoSFactor, ctx.maxQoSFactor)},
+		{"partition", f.partition, w.Partition,
+			fmt.Sprintf("priority tier %d of at most %d", ctx.tiers[job.PartitionID], ctx.maxTier)},
+	} {
+		e.Factors = append(e.Factors, models.PriorityFactor{
+			Name:         factor.name,
+			Value:        factor.value,
+			Weight:       factor.weight,
+			Contribution: f.base * factor.weight * factor.value,
+			Detail:       factor.detail,
+		})
+	}
+	return e
+}
@@ -0,0 +1,298 @@
+package scheduler
+
+import (
+	"math"
+	"sort"
+
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+// Fair-share tree kinds
+const (
+	shareRoot       = "root"
+	shareDepartment = "department"
+	shareGroup      = "group"
+	shareUser       = "user"
+)
+
+// shareAccount is a department, group or user with its parent (the department
+// of a group, the group of a user; 0 = top level) and its shares
+type shareAccount struct {
+	id       int
+	parentID int
+	name     string
+	shares   float64
+}
+
+// shareUsage is decayed CPU hours charged to a user in a group
+type shareUsage struct {
+	groupID  int
+	userID   int // 0 = charged to the group only
+	cpuHours float64
+}
+
+// shareNode is a node of the fair-share tree
+type shareNode struct {
+	kind       string
+	id         int
+	name       string
+	shares     float64
+	usage      float64 // Decayed CPU hours, including the children's
+	normShares float64 // Fraction of its siblings' shares
+	normUsage  float64 // Fraction of its siblings' usage
+	levelFS    float64 // normShares / normUsage (+Inf without usage)
+	parent     *shareNode
+	children   []*shareNode
+}
+
+// fairShareTree ranks users FairTree-style. Siblings are ordered by level
+// fair-share, the tree is walked depth-first in that order, and each user's
+// factor is their rank scaled to (0, 1]: the first user reached gets 1.0.
+type fairShareTree struct {
+	root      *shareNode
+	users     map[int]*shareNode
+	factors   map[int]float64 // User ID → fair-share factor
+	userCount int
+}
+
+// newFairShareTree builds the department → group → user tree and ranks its
+// users. Groups without a department sit at the top level next to the
+// departments; users without a group are left out.
+func newFairShareTree(departments, groups, users []shareAccount, usage []shareUsage) *fairShareTree {
+	t := &fairShareTree{
+		root:    &shareNode{kind: shareRoot},
+		factors: make(map[int]float64),
+	}
+
+	departmentNodes := make(map[int]*shareNode)
+	for _, d := range departments {
+		n := &shareNode{kind: shareDepartment, id: d.id, name: d.name, shares: d.shares, parent: t.root}
+		departmentNodes[d.id] = n
+		t.root.children = append(t.root.children, n)
+	}
+
+	groupNodes := make(map[int]*shareNode)
+	for _, g := range groups {
+		parent, ok := departmentNodes[g.parentID]
+		if !ok {
+			parent = t.root
+		}
+		n := &shareNode{kind: shareGroup, id: g.id, name: g.name, shares: g.shares, parent: parent}
+		groupNodes[g.id] = n
+		parent.children = append(parent.children, n)
+	}
+
+	t.users = make(map[int]*shareNode)
+	for _, u := range users {
+		group, ok := groupNodes[u.parentID]
+		if !ok {
+			continue
+		}
+		n := &shareNode{kind: shareUser, id: u.id, name: u.name, shares: u.shares, parent: group}
+		t.users[u.id] = n
+		group.children = append(group.children, n)
+	}
+	t.userCount = len(t.users)
+
+	// Usage from a user's time in another group stays with that group
+	for _, u := range usage {
+		if n, ok := t.users[u.userID]; ok && n.parent.id == u.groupID {
+			n.usage += u.cpuHours
+		} else if n, ok := groupNodes[u.groupID]; ok {
+			n.usage += u.cpuHours
+		}
+	}
+
+	sumUsage(t.root)
+	setLevelFS(t.root)
+	next := t.userCount
+	t.rank(t.root.children, &next)
+	return t
+}
+
+// sumUsage adds each node's descendants' usage to its own
+func sumUsage(n *shareNode) float64 {
+	for _, c := range n.children {
+		n.usage += sumUsage(c)
+	}
+	return n.usage
+}
+
+// setLevelFS computes each child's share of its siblings' shares and usage
+func setLevelFS(n *shareNode) {
+	var shares, usage float64
+	for _, c := range n.children {
+		shares += c.shares
+		usage += c.usage
+	}
+	for _, c := range n.children {
+		if shares > 0 {
+			c.normShares = c.shares / shares
+		}
+		if usage > 0 {
+			c.normUsage = c.usage / usage
+		}
+		if c.normUsage > 0 {
+			c.levelFS = c.normShares / c.normUsage
+		} else {
+			c.levelFS = math.Inf(1)
+		}
+		setLevelFS(c)
+	}
+}
+
+// rank walks nodes in level fair-share order, giving each user reached the
+// next rank. Tied users share a rank; the children of tied accounts are
+// ranked together, as if their parents were one account.
+func (t *fairShareTree) rank(nodes []*shareNode, next *int) {
+	sorted := append([]*shareNode(nil), nodes...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].levelFS > sorted[j].levelFS })
+
+	for i := 0; i < len(sorted); {
+		j := i + 1
+		for j < len(sorted) && sorted[j].levelFS == sorted[i].levelFS {
+			j++
+		}
+
+		var users int
+		var merged []*shareNode
+		for _, n := range sorted[i:j] {
+			if n.kind == shareUser {
+				t.factors[n.id] = float64(*next) / float64(t.userCount)
+				users++
+			} else {
+				merged = append(merged, n.children...)
+			}
+		}
+		*next -= users
+		if len(merged) > 0 {
+			t.rank(merged, next)
+		}
+		i = j
+	}
+}
+
+// nodeModel converts one node, without its children, for the API
+func (t *fairShareTree) nodeModel(n *shareNode) models.FairShareNode {
+	m := models.FairShareNode{
+		Kind:       n.kind,
+		ID:         n.id,
+		Name:       n.name,
+		Shares:     n.shares,
+		NormShares: n.normShares,
+		Usage:      n.usage,
+		NormUsage:  n.normUsage,
+	}
+	if n.kind != shareRoot && !math.IsInf(n.levelFS, 1) {
+		levelFS := n.levelFS
+		m.LevelFS = &levelFS
+	}
+	if factor, ok := t.factors[n.id]; ok && n.kind == shareUser {
+		m.FairShare = &factor
+	}
+	return m
+}
+
+// toModel converts the tree for the API
+func (t *fairShareTree) toModel() models.FairShareNode {
+	var convert func(n *shareNode) models.FairShareNode
+	convert = func(n *shareNode) models.FairShareNode {
+		m := t.nodeModel(n)
+		for _, c := range n.children {
+			m.Children = append(m.Children, convert(c))
+		}
+		return m
+	}
+	return convert(t.root)
+}
+
+// path returns the nodes from the user's department (or top-level group)
+// down to the user
+func (t *fairShareTree) path(userID int) []models.FairShareNode {
+	var path []models.FairShareNode
+	for n := t.users[userID]; n != nil && n.kind != shareRoot; n = n.parent {
+		path = append([]models.FairShareNode{t.nodeModel(n)}, path...)
+	}
+	return path
+}
+
+// loadFairShareTree builds the fair-share tree from the database. Past usage
+// decays with the configured half-life; running jobs count what they have
+// used so far.
+func (pc *PriorityCalculator) loadFairShareTree() (*fairShareTree, error) {
+	departments, err := pc.loadShareAccounts(`SELECT id, 0, name, shares FROM departments ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := pc.loadShareAccounts(`SELECT id, COALESCE(department_id, 0), name, shares FROM groups ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	users, err := pc.loadShareAccounts(`SELECT id, group_id, email, shares FROM users WHERE group_id IS NOT NULL ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+
+	// Usage older than ten half-lives counts for less than 0.1% and is skipped
+	rows, err := pc.db.Query(`
+		SELECT ul.group_id, COALESCE(j.user_id, 0),
+		       SUM(ul.cpu_hours_used::float8 * CASE WHEN $1::float8 > 0
+		           THEN POWER(0.5::float8, EXTRACT(EPOCH FROM NOW() - ul.logged_at)::float8 / $1::float8)
+		           ELSE 1 END)
+		FROM usage_logs ul
+		LEFT JOIN jobs j ON j.id = ul.job_id
+		WHERE $1::float8 = 0 OR ul.logged_at > NOW() - make_interval(secs => $1::float8 * 10)
+		GROUP BY ul.group_id, COALESCE(j.user_id, 0)
+		UNION ALL
+		SELECT group_id, user_id, SUM(cpu_cores * EXTRACT(EPOCH FROM NOW() - started_at)::float8 / 3600)
+		FROM jobs
+		WHERE status = 'running' AND NOT is_array AND started_at IS NOT NULL AND preempted_by IS NULL
+		GROUP BY group_id, user_id
+	`, pc.halfLife.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usage []shareUsage
+	for rows.Next() {
+		var u shareUsage
+		if err := rows.Scan(&u.groupID, &u.userID, &u.cpuHours); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return newFairShareTree(departments, groups, users, usage), nil
+}
+
+// loadShareAccounts reads (id, parent ID, name, shares) rows
+func (pc *PriorityCalculator) loadShareAccounts(query string) ([]shareAccount, error) {
+	rows, err := pc.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []shareAccount
+	for rows.Next() {
+		var a shareAccount
+		if err := rows.Scan(&a.id, &a.parentID, &a.name, &a.shares); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+// FairShareTree returns the current fair-share tree with every user's factor
+func (s *Scheduler) FairShareTree() (models.FairShareNode, error) {
+	t, err := s.priorityCalc.loadFairShareTree()
+	if err != nil {
+		return models.FairShareNode{}, err
+	}
+	return t.toModel(), nil
+}
@@ -0,0 +1,150 @@
+package scheduler
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFairShareTreeRanking(t *testing.T) {
+	tests := []struct {
+		name        string
+		departments []shareAccount
+		groups      []shareAccount
+		users       []shareAccount
+		usage       []shareUsage
+		want        map[int]float64 // User ID → fair-share factor
+	}{
+		{name: "empty tree", want: map[int]float64{}},
+		{
+			name:   "groups without users",
+			groups: []shareAccount{{id: 1, shares: 1}, {id: 2, shares: 1}},
+			usage:  []shareUsage{{groupID: 1, cpuHours: 10}},
+			want:   map[int]float64{},
+		},
+		{
+			name:   "zero-usage siblings tie",
+			groups: []shareAccount{{id: 1, shares: 1}},
+			users:  []shareAccount{{id: 1, parentID: 1, shares: 1}, {id: 2, parentID: 1, shares: 1}},
+			want:   map[int]float64{1: 1, 2: 1},
+		},
+		{
+			name:   "unused group ranks first",
+			groups: []shareAccount{{id: 1, shares: 1}, {id: 2, shares: 1}},
+			users:  []shareAccount{{id: 1, parentID: 1, shares: 1}, {id: 2, parentID: 2, shares: 1}},
+			usage:  []shareUsage{{groupID: 1, userID: 1, cpuHours: 10}},
+			want:   map[int]float64{1: 0.5, 2: 1},
+		},
+		{
+			name:   "shares outweigh usage",
+			groups: []shareAccount{{id: 1, shares: 3}, {id: 2, shares: 1}},
+			users:  []shareAccount{{id: 1, parentID: 1, shares: 1}, {id: 2, parentID: 2, shares: 1}},
+			usage:  []shareUsage{{groupID: 1, userID: 1, cpuHours: 30}, {groupID: 2, userID: 2, cpuHours: 20}},
+			want:   map[int]float64{1: 1, 2: 0.5},
+		},
+		{
+			name:        "departments rank their groups",
+			departments: []shareAccount{{id: 1, shares: 1}},
+			groups:      []shareAccount{{id: 1, parentID: 1, shares: 1}, {id: 2, parentID: 1, shares: 1}, {id: 3, shares: 1}},
+			users: []shareAccount{
+				{id: 1, parentID: 1, shares: 1},
+				{id: 2, parentID: 2, shares: 1},
+				{id: 3, parentID: 3, shares: 1},
+			},
+			usage: []shareUsage{{groupID: 1, userID: 1, cpuHours: 5}, {groupID: 3, userID: 3, cpuHours: 10}},
+			want:  map[int]float64{1: 2.0 / 3, 2: 1, 3: 1.0 / 3},
+		},
+		{
+			name:   "children of tied groups are ranked together",
+			groups: []shareAccount{{id: 1, shares: 1}, {id: 2, shares: 1}},
+			users: []shareAccount{
+				{id: 1, parentID: 1, shares: 1},
+				{id: 2, parentID: 1, shares: 1},
+				{id: 3, parentID: 2, shares: 1},
+				{id: 4, parentID: 2, shares: 1},
+			},
+			usage: []shareUsage{{groupID: 1, userID: 1, cpuHours: 10}, {groupID: 2, userID: 3, cpuHours: 10}},
+			want:  map[int]float64{1: 0.5, 2: 1, 3: 0.5, 4: 1},
+		},
+		{
+			name:   "usage in another group stays with that group",
+			groups: []shareAccount{{id: 1, shares: 1}, {id: 2, shares: 1}},
+			users:  []shareAccount{{id: 1, parentID: 1, shares: 1}, {id: 2, parentID: 2, shares: 1}},
+			usage:  []shareUsage{{groupID: 2, userID: 1, cpuHours: 10}},
+			want:   map[int]float64{1: 1, 2: 0.5},
+		},
+		{
+			name:   "group usage without a user",
+			groups: []shareAccount{{id: 1, shares: 1}, {id: 2, shares: 1}},
+			users:  []shareAccount{{id: 1, parentID: 1, shares: 1}, {id: 2, parentID: 2, shares: 1}},
+			usage:  []shareUsage{{groupID: 1, cpuHours: 10}},
+			want:   map[int]float64{1: 0.5, 2: 1},
+		},
+		{
+			name:   "users without a group are left out",
+			groups: []shareAccount{{id: 1, shares: 1}},
+			users:  []shareAccount{{id: 1, parentID: 1, shares: 1}, {id: 2, parentID: 9, shares: 1}},
+			want:   map[int]float64{1: 1},
+		},
+		{
+			name:   "zero shares",
+			groups: []shareAccount{{id: 1, shares: 0}, {id: 2, shares: 1}},
+			users:  []shareAccount{{id: 1, parentID: 1, shares: 1}, {id: 2, parentID: 2, shares: 1}},
+			usage:  []shareUsage{{groupID: 1, userID: 1, cpuHours: 1}, {groupID: 2, userID: 2, cpuHours: 1}},
+			want:   map[int]float64{1: 0.5, 2: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		tree := newFairShareTree(tt.departments, tt.groups, tt.users, tt.usage)
+		if len(tree.factors) != len(tt.want) {
+			t.Errorf("%s: got factors %v, want %v", tt.name, tree.factors, tt.want)
+			continue
+		}
+		for userID, want := range tt.want {
+			if got, ok := tree.factors[userID]; !ok || math.Abs(got-want) > 1e-9 {
+				t.Errorf("%s: got %v for user %d, want %v", tt.name, got, userID, want)
+			}
+		}
+	}
+}
+
+func TestFairShareTreeUsage(t *testing.T) {
+	tree := newFairShareTree(
+		[]shareAccount{{id: 1, shares: 1}},
+		[]shareAccount{{id: 1, parentID: 1, shares: 2}, {id: 2, parentID: 1, shares: 2}},
+		[]shareAccount{{id: 1, parentID: 1, shares: 1}, {id: 2, parentID: 2, shares: 1}},
+		[]shareUsage{{groupID: 1, userID: 1, cpuHours: 6}, {groupID: 1, cpuHours: 2}, {groupID: 2, userID: 2, cpuHours: 8}},
+	)
+
+	user1, user2 := tree.users[1], tree.users[2]
+	group1, department := user1.parent, user1.parent.parent
+
+	tests := []struct {
+		name       string
+		node       *shareNode
+		usage      float64
+		normShares float64
+		normUsage  float64
+		levelFS    float64
+	}{
+		{"user", user1, 6, 1, 1, 1},
+		{"group with its own usage", group1, 8, 0.5, 0.5, 1},
+		{"other group", user2.parent, 8, 0.5, 0.5, 1},
+		{"department", department, 16, 1, 1, 1},
+		{"root", tree.root, 16, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		n := tt.node
+		if n.usage != tt.usage || n.normShares != tt.normShares || n.normUsage != tt.normUsage || n.levelFS != tt.levelFS {
+			t.Errorf("%s: got usage %v, normShares %v, normUsage %v, levelFS %v; want %v, %v, %v, %v",
+				tt.name, n.usage, n.normShares, n.normUsage, n.levelFS, tt.usage, tt.normShares, tt.normUsage, tt.levelFS)
+		}
+	}
+
+	if path := tree.path(2); len(path) != 3 || path[0].Kind != shareDepartment || path[2].Kind != shareUser {
+		t.Errorf("path of user 2 = %+v, want department → group → user", path)
+	}
+	if path := tree.path(99); len(path) != 0 {
+		t.Errorf("path of an unknown user = %+v, want none", path)
+	}
+}
@@ -0,0 +1,455 @@
+package scheduler
+
+import (
+	"log"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// maxForecastJobs caps how many pending jobs one forecast places; jobs further
+// down the queue get no prediction
+const maxForecastJobs = 2000
+
+// timeline is usage over time as a step function: usage only changes at its
+// points, which are sorted by time
+type timeline struct {
+	points []timelinePoint
+}
+
+// timelinePoint is the usage from at until the next point
+type timelinePoint struct {
+	at    time.Time
+	usage accountUsage
+}
+
+// index returns the index of the last point at or before t, or -1
+func (tl *timeline) index(t time.Time) int {
+	return sort.Search(len(tl.points), func(i int) bool { return tl.points[i].at.After(t) }) - 1
+}
+
+// split makes a point start at t and returns its index
+func (tl *timeline) split(t time.Time) int {
+	i := tl.index(t)
+	if i >= 0 && tl.points[i].at.Equal(t) {
+		return i
+	}
+	var u accountUsage
+	if i >= 0 {
+		u = tl.points[i].usage
+	}
+	tl.points = slices.Insert(tl.points, i+1, timelinePoint{at: t, usage: u})
+	return i + 1
+}
+
+// hold adds u from start until end (zero end = indefinitely)
+func (tl *timeline) hold(start, end time.Time, u accountUsage) {
+	from := tl.split(start)
+	to := len(tl.points)
+	if !end.IsZero() {
+		to = tl.split(end)
+	}
+	for i := from; i < to; i++ {
+		tl.points[i].usage.add(u.jobs, u.cpus, u.memoryGB, u.gpus)
+	}
+}
+
+// peak returns the most jobs and resources held at once between start and
+// end (zero end = from start on), each counted at its own peak
+func (tl *timeline) peak(start, end time.Time) accountUsage {
+	var p accountUsage
+	for i := max(tl.index(start), 0); i < len(tl.points); i++ {
+		pt := &tl.points[i]
+		if !end.IsZero() && !pt.at.Before(end) {
+			break
+		}
+		p.jobs, p.cpus = max(p.jobs, pt.usage.jobs), max(p.cpus, pt.usage.cpus)
+		p.memoryGB, p.gpus = max(p.memoryGB, pt.usage.memoryGB), max(p.gpus, pt.usage.gpus)
+	}
+	return p
+}
+
+// forecastKey identifies what a job's running limits count: the whole
+// cluster, a user, a group, a user or group in a QoS, a partition, a user in
+// a partition, or a job array
+type forecastKey struct {
+	kind string
+	id   int
+	sub  int
+}
+
+// forecastLimit is a limit a job must stay within for its whole runtime
+type forecastLimit struct {
+	key    forecastKey
+	limits accountLimits
+}
+
+// forecastLimits are the running limits a scheduling cycle applies, as loaded
+// for the same cycle's queuePass
+type forecastLimits struct {
+	maxConcurrent int
+	partitions    map[int]*partition
+	qos           *qosState
+	accounts      *limitState
+}
+
+// keys returns everything a running job counts against
+func (fl *forecastLimits) keys(userID, groupID, qosID, partitionID, arrayJobID int) []forecastKey {
+	keys := []forecastKey{{"cluster", 0, 0}, {"user", userID, 0}, {"group", groupID, 0}}
+	if qosID != 0 {
+		keys = append(keys, forecastKey{"qos-user", qosID, userID}, forecastKey{"qos-group", qosID, groupID})
+	}
+	if partitionID != 0 {
+		keys = append(keys, forecastKey{"partition", partitionID, 0}, forecastKey{"partition-user", partitionID, userID})
+	}
+	if arrayJobID != 0 {
+		keys = append(keys, forecastKey{"array", arrayJobID, 0})
+	}
+	return keys
+}
+
+// of returns the limits that hold the job back, the same ones queuePass
+// checks: MAX_CONCURRENT_JOBS, array throttles, partition, QoS, user and group limits
+func (fl *forecastLimits) of(job *JobWithPriority) []forecastLimit {
+	var limits []forecastLimit
+	if fl.maxConcurrent > 0 {
+		limits = append(limits, forecastLimit{forecastKey{"cluster", 0, 0}, accountLimits{maxJobs: fl.maxConcurrent}})
+	}
+	if job.ArrayJobID != 0 && job.ArrayThrottle > 0 {
+		limits = append(limits, forecastLimit{forecastKey{"array", job.ArrayJobID, 0}, accountLimits{maxJobs: job.ArrayThrottle}})
+	}
+	if p := fl.partitions[job.PartitionID]; p != nil {
+		limits = append(limits,
+			forecastLimit{forecastKey{"partition", p.id, 0}, accountLimits{maxJobs: p.maxRunning}},
+			forecastLimit{forecastKey{"partition-user", p.id, job.UserID}, accountLimits{maxJobs: p.maxJobsPerUser}})
+	}
+	if l := fl.qos.limits[job.QoSID]; l != nil {
+		limits = append(limits,
+			forecastLimit{forecastKey{"qos-user", job.QoSID, job.UserID},
+				accountLimits{maxJobs: l.maxJobsPerUser, maxCPUs: l.maxCPUsPerUser}},
+			forecastLimit{forecastKey{"qos-group", job.QoSID, job.GroupID},
+				accountLimits{maxJobs: l.maxJobsPerGroup, maxCPUs: l.maxCPUsPerGroup}})
+	}
+	if l := fl.accounts.users[job.UserID]; l != nil {
+		limits = append(limits, forecastLimit{forecastKey{"user", job.UserID, 0}, *l})
+	}
+	if l := fl.accounts.groups[job.GroupID]; l != nil {
+		limits = append(limits, forecastLimit{forecastKey{"group", job.GroupID, 0}, *l})
+	}
+	return limits
+}
+
+// prediction is when and where a pending job is expected to start
+type prediction struct {
+	start    time.Time
+	end      time.Time // Zero = no estimate
+	workerID int
+}
+
+// forecaster predicts start times by placing pending jobs one at a time, in
+// queue order, at the earliest time a worker has room for their whole
+// estimated runtime and their limits allow it, without delaying the jobs
+// placed before them
+type forecaster struct {
+	now       time.Time
+	strategy  PlacementStrategy
+	limits    *forecastLimits
+	workers   []Worker
+	held      map[int]*timeline         // Worker ID → resources held by running and placed jobs
+	counted   map[forecastKey]*timeline // What running and placed jobs count against limits
+	releases  []time.Time               // When held resources are released, sorted and distinct
+	ends      map[int]time.Time         // Job ID → expected end (zero = unknown)
+	predicted map[int]prediction
+}
+
+// newForecaster starts a forecast from the online workers and the jobs
+// running on them
+func newForecaster(now time.Time, workers []Worker, active []ActiveJob, walltimeGrace float64,
+	limits *forecastLimits, strategy PlacementStrategy) *forecaster {
+	f := &forecaster{
+		now:       now,
+		strategy:  strategy,
+		limits:    limits,
+		held:      make(map[int]*timeline),
+		counted:   make(map[forecastKey]*timeline),
+		ends:      make(map[int]time.Time),
+		predicted: make(map[int]prediction),
+	}
+
+	running := make(map[int]accountUsage)
+	for _, a := range active {
+		end := expectedEnd(now, a.StartedAt, a.EstimatedHours, walltimeGrace)
+		u := accountUsage{jobs: 1, cpus: a.CPUCores, memoryGB: a.MemoryGB, gpus: a.GPUCount}
+		f.hold(a.WorkerID, limits.keys(a.UserID, a.GroupID, a.QoSID, a.PartitionID, a.ArrayJobID), now, end, u)
+		f.ends[a.ID] = end
+		r := running[a.WorkerID]
+		r.add(0, a.CPUCores, a.MemoryGB, a.GPUCount)
+		running[a.WorkerID] = r
+	}
+
+	// Allocations of jobs that have not started yet are held until further notice
+	for _, w := range workers {
+		r := running[w.ID]
+		if w.AllocatedCPUCores > r.cpus || w.AllocatedMemoryGB > r.memoryGB || w.AllocatedGPUs > r.gpus {
+			f.hold(w.ID, nil, now, time.Time{}, accountUsage{
+				cpus:     max(w.AllocatedCPUCores-r.cpus, 0),
+				memoryGB: max(w.AllocatedMemoryGB-r.memoryGB, 0),
+				gpus:     max(w.AllocatedGPUs-r.gpus, 0),
+			})
+		}
+		w.AllocatedCPUCores, w.AllocatedMemoryGB, w.AllocatedGPUs = 0, 0, 0
+		f.workers = append(f.workers, w)
+	}
+	return f
+}
+
+// timelineOf returns the timeline for key, creating it if needed
+func (f *forecaster) timelineOf(key forecastKey) *timeline {
+	tl, ok := f.counted[key]
+	if !ok {
+		tl = &timeline{}
+		f.counted[key] = tl
+	}
+	return tl
+}
+
+// hold records a job holding u on a worker and against keys from start until end
+func (f *forecaster) hold(workerID int, keys []forecastKey, start, end time.Time, u accountUsage) {
+	tl, ok := f.held[workerID]
+	if !ok {
+		tl = &timeline{}
+		f.held[workerID] = tl
+	}
+	tl.hold(start, end, u)
+	for _, key := range keys {
+		f.timelineOf(key).hold(start, end, u)
+	}
+	if !end.IsZero() {
+		i := sort.Search(len(f.releases), func(i int) bool { return !f.releases[i].Before(end) })
+		if i == len(f.releases) || !f.releases[i].Equal(end) {
+			f.releases = slices.Insert(f.releases, i, end)
+		}
+	}
+}
+
+// expectedEnd returns when a running job is expected to finish: after its
+// estimated runtime, or, once past that, when it is killed at its walltime.
+// Zero means the job has no estimate.
+func expectedEnd(now, startedAt time.Time, estimatedHours, walltimeGrace float64) time.Time {
+	if estimatedHours <= 0 {
+		return time.Time{}
+	}
+	end := startedAt.Add(time.Duration(estimatedHours * float64(time.Hour)))
+	if end.Before(now) {
+		end = startedAt.Add(time.Duration(estimatedHours * walltimeGrace * float64(time.Hour)))
+	}
+	if end.Before(now) {
+		end = now // Overdue: about to be killed
+	}
+	return end
+}
+
+// place predicts the job's start at or after earliest on a worker member
+// accepts, and reserves what it needs there. Returns false if no worker will
+// ever have room, or its limits never allow it to start.
+func (f *forecaster) place(job *JobWithPriority, earliest time.Time, member func(*Worker) bool) (prediction, bool) {
+	if earliest.Before(f.now) {
+		earliest = f.now
+	}
+	runtime := time.Duration(job.EstimatedHours * float64(time.Hour))
+	limits := f.limits.of(job)
+	keys := f.limits.keys(job.UserID, job.GroupID, job.QoSID, job.PartitionID, job.ArrayJobID)
+
+	// Room only opens up when a job ends, so those are the times to try
+	times := []time.Time{earliest}
+	i := sort.Search(len(f.releases), func(i int) bool { return f.releases[i].After(earliest) })
+	times = append(times, f.releases[i:]...)
+
+	for _, t := range times {
+		var end time.Time
+		if runtime > 0 {
+			end = t.Add(runtime)
+		}
+		if !f.allowed(job, limits, t, end) {
+			continue
+		}
+
+		// Offer the placement strategy each worker with room for the whole
+		// runtime, as loaded as it will be at its busiest
+		var candidates []*Worker
+		for j := range f.workers {
+			w := f.workers[j]
+			if !member(&w) {
+				continue
+			}
+			if tl := f.held[w.ID]; tl != nil {
+				peak := tl.peak(t, end)
+				w.AllocatedCPUCores, w.AllocatedMemoryGB, w.AllocatedGPUs = peak.cpus, peak.memoryGB, peak.gpus
+			}
+			if w.FreeCPUCores() >= job.CPUCores && w.FreeMemoryGB() >= job.MemoryGB && w.FreeGPUs() >= job.GPUCount {
+				candidates = append(candidates, &w)
+			}
+		}
+		chosen := f.strategy.Choose(job, candidates)
+		if chosen == nil {
+			continue
+		}
+
+		f.hold(chosen.ID, keys, t, end, accountUsage{jobs: 1, cpus: job.CPUCores, memoryGB: job.MemoryGB, gpus: job.GPUCount})
+		p := prediction{start: t, end: end, workerID: chosen.ID}
+		f.predicted[job.ID] = p
+		f.ends[job.ID] = end
+		return p, true
+	}
+	return prediction{}, false
+}
+
+// allowed reports whether the job's limits let it run from start until end
+func (f *forecaster) allowed(job *JobWithPriority, limits []forecastLimit, start, end time.Time) bool {
+	for i := range limits {
+		tl, ok := f.counted[limits[i].key]
+		if !ok {
+			tl = &timeline{}
+		}
+		peak := tl.peak(start, end)
+		if limits[i].limits.exceeds(&peak, job) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// runForecasts updates the start time forecast on its own ticker, apart from
+// scheduling cycles, so a deep queue never holds up scheduling
+func (s *Scheduler) runForecasts() {
+	ticker := time.NewTicker(s.forecastInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if s.isLeader.Load() {
+				s.updateForecast()
+			}
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// updateForecast predicts when and on which worker each pending job will
+// start, and saves the predictions
+func (s *Scheduler) updateForecast() {
+	q, err := s.rankQueue()
+	if err != nil {
+		log.Printf("Error ranking the queue for the forecast: %v", err)
+		return
+	}
+	if len(q.queued) == 0 && len(q.waiting) == 0 {
+		return
+	}
+	workers, err := s.getOnlineWorkers()
+	if err != nil {
+		log.Printf("Error getting workers for the forecast: %v", err)
+		return
+	}
+	active, err := s.getActiveJobs()
+	if err != nil {
+		log.Printf("Error getting running jobs for the forecast: %v", err)
+		return
+	}
+	qos, err := s.getQoSState()
+	if err != nil {
+		log.Printf("Error getting QoS limits for the forecast: %v", err)
+		return
+	}
+	accounts, err := s.getLimitState()
+	if err != nil {
+		log.Printf("Error getting user and group limits for the forecast: %v", err)
+		return
+	}
+
+	limits := &forecastLimits{maxConcurrent: s.maxConcurrent, partitions: q.partitions, qos: qos, accounts: accounts}
+	f := newForecaster(time.Now(), workers, active, s.walltimeGrace, limits, s.resourceMatcher.Strategy())
+	member := func(job *JobWithPriority) func(*Worker) bool {
+		part := q.partitions[job.PartitionID]
+		return func(w *Worker) bool { return part == nil || part.hasWorker(w) }
+	}
+	placed := 0
+	for i := range q.queued {
+		if placed >= maxForecastJobs {
+			break
+		}
+		f.place(&q.queued[i], f.now, member(&q.queued[i]))
+		placed++
+	}
+
+	// Waiting jobs start once their retry backoff is over and their parents
+	// are expected to have finished. Parents may be waiting jobs themselves,
+	// so keep going while jobs can still be placed.
+	done := make(map[int]bool)
+	for progress := true; progress && placed < maxForecastJobs; {
+		progress = false
+		for i := range q.waiting {
+			job := &q.waiting[i]
+			if done[job.ID] || placed >= maxForecastJobs {
+				continue
+			}
+			state := q.states[job.ID]
+			earliest, known := f.now, true
+			if state.eligibleAt != nil && state.eligibleAt.After(earliest) {
+				earliest = *state.eligibleAt
+			}
+			for _, parent := range state.parents {
+				end, ok := f.ends[parent]
+				if !ok || end.IsZero() {
+					known = false
+					break
+				}
+				if end.After(earliest) {
+					earliest = end
+				}
+			}
+			if !known {
+				continue
+			}
+			done[job.ID] = true
+			progress = true
+			f.place(job, earliest, member(job))
+			placed++
+		}
+	}
+
+	s.recordForecast(f.predicted)
+}
+
+// recordForecast saves each pending job's predicted start and worker, and
+// clears them for pending jobs without a prediction
+func (s *Scheduler) recordForecast(predicted map[int]prediction) {
+	ids := make([]int64, 0, len(predicted))
+	starts := make([]time.Time, 0, len(predicted))
+	workers := make([]int64, 0, len(predicted))
+	for id, p := range predicted {
+		ids = append(ids, int64(id))
+		starts = append(starts, p.start)
+		workers = append(workers, int64(p.workerID))
+	}
+
+	_, err := s.db.Exec(`
+		UPDATE jobs j
+		SET expected_start = f.start, expected_worker_id = f.worker_id
+		FROM (
+			SELECT p.id, r.start, r.worker_id
+			FROM jobs p
+			LEFT JOIN unnest($1::int[], $2::timestamptz[], $3::int[]) AS r(id, start, worker_id) ON r.id = p.id
+			WHERE p.status = 'pending' AND NOT p.is_array
+		) f
+		WHERE j.id = f.id
+		  AND (j.expected_start IS DISTINCT FROM f.start OR j.expected_worker_id IS DISTINCT FROM f.worker_id)
+	`, pq.Array(ids), pq.Array(starts), pq.Array(workers))
+	if err != nil {
+		log.Printf("Error saving the start time forecast: %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/lib/pq"
+
+	"github.com/samik-k21/research-compute-queue/internal/database"
+)
+
+// schedulerLockKey is the Postgres advisory lock held by the scheduling leader
+const schedulerLockKey int64 = 0x52435153 // "RCQS"
+
+// leaderLock elects one scheduling leader among server replicas. The advisory
+// lock belongs to a database session, so it is held on a dedicated connection
+// and released automatically if that connection (or the replica) dies.
+type leaderLock struct {
+	db   *database.DB
+	conn *sql.Conn // Non-nil while this replica holds the lock
+}
+
+// held reports whether this replica still holds the lock
+func (l *leaderLock) held(ctx context.Context) bool {
+	if l.conn == nil {
+		return false
+	}
+
+	var one int
+	if err := l.conn.QueryRowContext(ctx, "SELECT 1").Scan(&one); err != nil {
+		// The session is gone and the lock with it
+		l.conn.Close()
+		l.conn = nil
+		return false
+	}
+	return true
+}
+
+// tryAcquire takes the lock if no other replica holds it
+func (l *leaderLock) tryAcquire(ctx context.Context) (bool, error) {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var acquired bool
+	err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", schedulerLockKey).Scan(&acquired)
+	if err != nil || !acquired {
+		conn.Close()
+		return false, err
+	}
+
+	l.conn = conn
+	return true, nil
+}
+
+// release gives up the lock so another replica can take over
+func (l *leaderLock) release() {
+	if l.conn == nil {
+		return
+	}
+	l.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", schedulerLockKey)
+	l.conn.Close()
+	l.conn = nil
+}
+
+// TryBecomeLeader makes this replica the scheduling leader if no other replica
+// is. Every replica serves the API, but only the leader runs scheduling cycles.
+// Taking over does not touch running jobs: each replica keeps watching its own
+// local jobs, and jobs of replicas that stopped are recovered separately.
+func (s *Scheduler) TryBecomeLeader() bool {
+	if s.leader.held(s.ctx) {
+		return true
+	}
+	if s.isLeader.Load() {
+		log.Println("Lost scheduling leadership")
+		s.isLeader.Store(false)
+	}
+
+	acquired, err := s.leader.tryAcquire(s.ctx)
+	if err != nil {
+		log.Printf("Error acquiring scheduling leadership: %v", err)
+		return false
+	}
+	if !acquired {
+		return false
+	}
+
+	log.Println("Became scheduling leader")
+	s.isLeader.Store(true)
+	return true
+}
+
+// stopCancelledJobs stops local processes for jobs cancelled through another
+// replica, whose API could not reach this replica's executor, and for
+// preempted jobs adopted after a restart, whose stop signal was lost. Every
+// replica runs it, since every replica may be running local jobs.
+func (s *Scheduler) stopCancelledJobs() {
+	tracked := s.executor.trackedJobs()
+	if len(tracked) == 0 {
+		return
+	}
+
+	rows, err := s.db.Query(
+		`SELECT id, COALESCE(error_message, '') FROM jobs
+		 WHERE id = ANY($1) AND (status = 'cancelled' OR (status = 'running' AND preempted_by IS NOT NULL))`,
+		pq.Array(tracked),
+	)
+	if err != nil {
+		log.Printf("Error checking for cancelled jobs: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var jobID int
+		var reason string
+		if err := rows.Scan(&jobID, &reason); err != nil {
+			log.Printf("Error scanning cancelled job: %v", err)
+			continue
+		}
+		s.executor.CancelJob(jobID, reason)
+	}
+}
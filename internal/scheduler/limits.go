@@ -0,0 +1,155 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+// accountLimits holds a user's or a group's running limits (0 = unlimited)
+type accountLimits struct {
+	maxJobs     int
+	maxCPUs     int
+	maxMemoryGB int
+	maxGPUs     int
+}
+
+// accountUsage counts running jobs and the resources they hold
+type accountUsage struct {
+	jobs     int
+	cpus     int
+	memoryGB int
+	gpus     int
+}
+
+// add counts running jobs holding the given resources
+func (u *accountUsage) add(jobs, cpus, memoryGB, gpus int) {
+	u.jobs += jobs
+	u.cpus += cpus
+	u.memoryGB += memoryGB
+	u.gpus += gpus
+}
+
+// limitState tracks per-user and per-group limits and running usage during a cycle
+type limitState struct {
+	users   map[int]*accountLimits
+	groups  map[int]*accountLimits
+	byUser  map[int]*accountUsage
+	byGroup map[int]*accountUsage
+}
+
+// accountUsageFor returns the counter for id, creating it if needed
+func accountUsageFor(m map[int]*accountUsage, id int) *accountUsage {
+	u, ok := m[id]
+	if !ok {
+		u = &accountUsage{}
+		m[id] = u
+	}
+	return u
+}
+
+// exceeds returns how starting the job would break the limits, or ""
+func (l *accountLimits) exceeds(u *accountUsage, job *JobWithPriority) string {
+	switch {
+	case l.maxJobs > 0 && u.jobs >= l.maxJobs:
+		return fmt.Sprintf("has the maximum of %d running jobs", l.maxJobs)
+	case l.maxCPUs > 0 && u.cpus+job.CPUCores > l.maxCPUs:
+		return fmt.Sprintf("would exceed %d running CPU cores (%d in use)", l.maxCPUs, u.cpus)
+	case l.maxMemoryGB > 0 && u.memoryGB+job.MemoryGB > l.maxMemoryGB:
+		return fmt.Sprintf("would exceed %d GB of running memory (%d GB in use)", l.maxMemoryGB, u.memoryGB)
+	case l.maxGPUs > 0 && u.gpus+job.GPUCount > l.maxGPUs:
+		return fmt.Sprintf("would exceed %d running GPUs (%d in use)", l.maxGPUs, u.gpus)
+	}
+	return ""
+}
+
+// holdReason returns which limit (models.PendingUserLimit or
+// models.PendingGroupLimit) keeps the job from starting now and why, or "" if
+// none does
+func (ls *limitState) holdReason(job *JobWithPriority) (string, string) {
+	if l, ok := ls.users[job.UserID]; ok {
+		if reason := l.exceeds(accountUsageFor(ls.byUser, job.UserID), job); reason != "" {
+			return models.PendingUserLimit, fmt.Sprintf("user %d %s", job.UserID, reason)
+		}
+	}
+	if l, ok := ls.groups[job.GroupID]; ok {
+		if reason := l.exceeds(accountUsageFor(ls.byGroup, job.GroupID), job); reason != "" {
+			return models.PendingGroupLimit, fmt.Sprintf("group %d %s", job.GroupID, reason)
+		}
+	}
+	return "", ""
+}
+
+// started counts a job started this cycle against its user's and group's limits
+func (ls *limitState) started(job *JobWithPriority) {
+	accountUsageFor(ls.byUser, job.UserID).add(1, job.CPUCores, job.MemoryGB, job.GPUCount)
+	accountUsageFor(ls.byGroup, job.GroupID).add(1, job.CPUCores, job.MemoryGB, job.GPUCount)
+}
+
+// getLimitState loads the users and groups with running limits and what they
+// are running
+func (s *Scheduler) getLimitState() (*limitState, error) {
+	ls := &limitState{
+		users:   make(map[int]*accountLimits),
+		groups:  make(map[int]*accountLimits),
+		byUser:  make(map[int]*accountUsage),
+		byGroup: make(map[int]*accountUsage),
+	}
+
+	if err := s.loadAccountLimits("users", ls.users); err != nil {
+		return nil, err
+	}
+	if err := s.loadAccountLimits("groups", ls.groups); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT user_id, group_id, COUNT(*), SUM(cpu_cores), SUM(memory_gb), SUM(gpu_count)
+		FROM jobs
+		WHERE status = 'running' AND NOT is_array
+		GROUP BY user_id, group_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID, groupID, jobs, cpus, memoryGB, gpus int
+		if err := rows.Scan(&userID, &groupID, &jobs, &cpus, &memoryGB, &gpus); err != nil {
+			return nil, err
+		}
+		accountUsageFor(ls.byUser, userID).add(jobs, cpus, memoryGB, gpus)
+		accountUsageFor(ls.byGroup, groupID).add(jobs, cpus, memoryGB, gpus)
+	}
+
+	return ls, rows.Err()
+}
+
+// loadAccountLimits reads the running limits of every row in table ("users"
+// or "groups") that has any
+func (s *Scheduler) loadAccountLimits(table string, limits map[int]*accountLimits) error {
+	rows, err := s.db.Query(`
+		SELECT id, COALESCE(max_running_jobs, 0), COALESCE(max_cpus, 0),
+		       COALESCE(max_memory_gb, 0), COALESCE(max_gpus, 0)
+		FROM ` + table + `
+		WHERE max_running_jobs IS NOT NULL OR max_cpus IS NOT NULL
+		   OR max_memory_gb IS NOT NULL OR max_gpus IS NOT NULL
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		l := &accountLimits{}
+		if err := rows.Scan(&id, &l.maxJobs, &l.maxCPUs, &l.maxMemoryGB, &l.maxGPUs); err != nil {
+			log.Printf("Error scanning %s limits: %v", table, err)
+			continue
+		}
+		limits[id] = l
+	}
+	return rows.Err()
+}
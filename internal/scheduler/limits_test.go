@@ -0,0 +1,76 @@
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+func TestAccountLimitsExceeds(t *testing.T) {
+	l := &accountLimits{maxJobs: 4, maxCPUs: 16, maxMemoryGB: 64, maxGPUs: 2}
+	u := &accountUsage{jobs: 3, cpus: 12, memoryGB: 48, gpus: 1}
+
+	tests := []struct {
+		cpu, mem, gpu int
+		ok            bool
+	}{
+		{4, 16, 1, true}, // Exactly up to every limit
+		{5, 1, 0, false},
+		{1, 17, 0, false},
+		{1, 1, 2, false},
+	}
+	for _, tt := range tests {
+		job := &JobWithPriority{CPUCores: tt.cpu, MemoryGB: tt.mem, GPUCount: tt.gpu}
+		if got := l.exceeds(u, job); (got == "") != tt.ok {
+			t.Errorf("%d CPU, %d GB, %d GPU: got %q, want ok = %t", tt.cpu, tt.mem, tt.gpu, got, tt.ok)
+		}
+	}
+
+	// The job count is checked whatever the job's size
+	u.jobs = 4
+	if got := l.exceeds(u, &JobWithPriority{}); got == "" {
+		t.Error("at the job limit: expected a reason")
+	}
+
+	// Zero means unlimited
+	unlimited := &accountLimits{}
+	if got := unlimited.exceeds(&accountUsage{jobs: 100, cpus: 1000}, &JobWithPriority{CPUCores: 64}); got != "" {
+		t.Errorf("no limits: got %q", got)
+	}
+}
+
+func TestLimitStateHoldReason(t *testing.T) {
+	ls := &limitState{
+		users:   map[int]*accountLimits{1: {maxJobs: 2}},
+		groups:  map[int]*accountLimits{1: {maxCPUs: 16}},
+		byUser:  map[int]*accountUsage{1: {jobs: 1, cpus: 4}, 2: {jobs: 5, cpus: 8}},
+		byGroup: map[int]*accountUsage{1: {jobs: 6, cpus: 12}},
+	}
+
+	tests := []struct {
+		userID, groupID, cpu int
+		want                 string
+	}{
+		{1, 1, 4, ""},
+		{1, 1, 5, models.PendingGroupLimit},
+		{2, 1, 1, ""},                       // No user limit
+		{2, 1, 8, models.PendingGroupLimit}, // The group is over for everyone
+		{3, 2, 64, ""},                      // Neither has limits
+	}
+	for _, tt := range tests {
+		job := &JobWithPriority{UserID: tt.userID, GroupID: tt.groupID, CPUCores: tt.cpu}
+		if got, msg := ls.holdReason(job); got != tt.want {
+			t.Errorf("user %d, group %d, %d CPU: got %q (%s), want %q", tt.userID, tt.groupID, tt.cpu, got, msg, tt.want)
+		}
+	}
+
+	// Jobs started this cycle count straight away; the user's limit is checked first
+	job := &JobWithPriority{UserID: 1, GroupID: 1, CPUCores: 2}
+	ls.started(job)
+	if got, _ := ls.holdReason(job); got != models.PendingUserLimit {
+		t.Errorf("after starting a job: got %q, want %q", got, models.PendingUserLimit)
+	}
+	if u := ls.byGroup[1]; u.jobs != 7 || u.cpus != 14 {
+		t.Errorf("group usage: got %+v", u)
+	}
+}
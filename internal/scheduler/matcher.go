@@ -8,27 +8,39 @@ import (
 
 // ResourceMatcher finds suitable workers for jobs
 type ResourceMatcher struct {
-	db *database.DB
+	db       *database.DB
+	strategy PlacementStrategy
 }
 
 // NewResourceMatcher creates a new resource matcher
-func NewResourceMatcher(db *database.DB) *ResourceMatcher {
-	return &ResourceMatcher{db: db}
+func NewResourceMatcher(db *database.DB, strategy PlacementStrategy) *ResourceMatcher {
+	return &ResourceMatcher{db: db, strategy: strategy}
 }
 
-// FindWorkerForJob finds a worker that can run the job
-func (rm *ResourceMatcher) FindWorkerForJob(job *JobWithPriority, workers []Worker) (*Worker, error) {
+// Strategy returns the placement strategy in use
+func (rm *ResourceMatcher) Strategy() PlacementStrategy {
+	return rm.strategy
+}
+
+// FindWorkerForJob picks a worker that can run the job using the placement strategy.
+// If allowed is non-nil, only workers it accepts are considered.
+func (rm *ResourceMatcher) FindWorkerForJob(job *JobWithPriority, workers []Worker, allowed func(*Worker) bool) (*Worker, error) {
+	var candidates []*Worker
 	for i := range workers {
-		if rm.workerCanRunJob(&workers[i], job) {
-			return &workers[i], nil
+		if rm.workerCanRunJob(&workers[i], job) && (allowed == nil || allowed(&workers[i])) {
+			candidates = append(candidates, &workers[i])
 		}
 	}
+
+	if worker := rm.strategy.Choose(job, candidates); worker != nil {
+		return worker, nil
+	}
 	return nil, errors.New("no suitable worker found")
 }
 
-// workerCanRunJob checks if worker has enough resources
+// workerCanRunJob checks if worker has enough unallocated resources
 func (rm *ResourceMatcher) workerCanRunJob(worker *Worker, job *JobWithPriority) bool {
-	return worker.CPUCores >= job.CPUCores &&
-		worker.MemoryGB >= job.MemoryGB &&
-		worker.GPUCount >= job.GPUCount
-}
\ No newline at end of file
+	return worker.FreeCPUCores() >= job.CPUCores &&
+		worker.FreeMemoryGB() >= job.MemoryGB &&
+		worker.FreeGPUs() >= job.GPUCount
+}
@@ -0,0 +1,133 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"sort"
+)
+
+// partition holds a partition's limits and what is running in it this cycle
+type partition struct {
+	id             int
+	name           string
+	tier           int
+	maxRunning     int          // 0 = unlimited
+	maxJobsPerUser int          // 0 = unlimited
+	workers        map[int]bool // Member workers (empty = every worker)
+
+	running       int
+	runningByUser map[int]int
+}
+
+// hasWorker reports whether jobs in the partition may run on the worker
+func (p *partition) hasWorker(w *Worker) bool {
+	return len(p.workers) == 0 || p.workers[w.ID]
+}
+
+// up reports whether any of the online workers is in the partition
+func (p *partition) up(workers []Worker) bool {
+	for i := range workers {
+		if p.hasWorker(&workers[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// holdReason returns why the partition's limits keep the job from starting now,
+// or "" if they do not
+func (p *partition) holdReason(job *JobWithPriority) string {
+	if p.maxRunning > 0 && p.running >= p.maxRunning {
+		return fmt.Sprintf("partition %s is running its maximum of %d jobs", p.name, p.maxRunning)
+	}
+	if p.maxJobsPerUser > 0 && p.runningByUser[job.UserID] >= p.maxJobsPerUser {
+		return fmt.Sprintf("user %d has the maximum of %d running jobs in partition %s",
+			job.UserID, p.maxJobsPerUser, p.name)
+	}
+	return ""
+}
+
+// started counts a job started in the partition this cycle
+func (p *partition) started(job *JobWithPriority) {
+	p.running++
+	p.runningByUser[job.UserID]++
+}
+
+// getPartitions loads every partition with its member workers and running job counts
+func (s *Scheduler) getPartitions() (map[int]*partition, error) {
+	rows, err := s.db.Query(`
+		SELECT id, name, COALESCE(priority_tier, 0),
+		       COALESCE(max_running_jobs, 0), COALESCE(max_jobs_per_user, 0)
+		FROM partitions
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	partitions := make(map[int]*partition)
+	for rows.Next() {
+		p := &partition{workers: make(map[int]bool), runningByUser: make(map[int]int)}
+		if err := rows.Scan(&p.id, &p.name, &p.tier, &p.maxRunning, &p.maxJobsPerUser); err != nil {
+			log.Printf("Error scanning partition: %v", err)
+			continue
+		}
+		partitions[p.id] = p
+	}
+	rows.Close()
+
+	members, err := s.db.Query("SELECT partition_id, worker_id FROM partition_workers")
+	if err != nil {
+		return nil, err
+	}
+	defer members.Close()
+
+	for members.Next() {
+		var partitionID, workerID int
+		if err := members.Scan(&partitionID, &workerID); err != nil {
+			return nil, err
+		}
+		if p, ok := partitions[partitionID]; ok {
+			p.workers[workerID] = true
+		}
+	}
+	members.Close()
+
+	running, err := s.db.Query(`
+		SELECT partition_id, user_id, COUNT(*)
+		FROM jobs
+		WHERE status = 'running' AND NOT is_array AND partition_id IS NOT NULL
+		GROUP BY partition_id, user_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer running.Close()
+
+	for running.Next() {
+		var partitionID, userID, count int
+		if err := running.Scan(&partitionID, &userID, &count); err != nil {
+			return nil, err
+		}
+		if p, ok := partitions[partitionID]; ok {
+			p.running += count
+			p.runningByUser[userID] += count
+		}
+	}
+
+	return partitions, running.Err()
+}
+
+// sortJobsByPartitionTier moves jobs in higher-tier partitions ahead of the
+// rest, keeping priority order within each tier
+func sortJobsByPartitionTier(jobs []JobWithPriority, partitions map[int]*partition) {
+	tier := func(job *JobWithPriority) int {
+		if p, ok := partitions[job.PartitionID]; ok {
+			return p.tier
+		}
+		return 0
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return tier(&jobs[i]) > tier(&jobs[j])
+	})
+}
@@ -0,0 +1,148 @@
+package scheduler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+// queuePass walks the ranked queue once, starting each job its limits and the
+// free resources allow. Scheduling cycles and the simulator both run it; they
+// differ in how a job is started and in what can be done for a job no worker
+// has room for.
+type queuePass struct {
+	matcher       *ResourceMatcher
+	workers       []Worker
+	maxConcurrent int
+	slots         int // Jobs that may still start under MAX_CONCURRENT_JOBS
+	partitions    map[int]*partition
+	qos           *qosState
+	limits        *limitState
+	arrayRunning  map[int]int      // Array job ID → running tasks
+	planner       *backfillPlanner // nil = no backfill
+	reasons       map[int]pendingReason
+	logf          func(format string, args ...interface{})
+
+	// start starts the job on the worker, returning false if it could not
+	start func(job *JobWithPriority, w *Worker) bool
+
+	// blocked, if set, may make room for a job no worker has room for (e.g.
+	// by preemption). It returns true if it did, with the job's reason set.
+	blocked func(job *JobWithPriority, member func(*Worker) bool) bool
+}
+
+// run tries to start the jobs in order and returns how many started
+func (p *queuePass) run(jobs []JobWithPriority) int {
+	concurrencyDetail := fmt.Sprintf("the maximum of %d jobs are running", p.maxConcurrent)
+	hold := func(job *JobWithPriority, code, detail string) {
+		p.logf("Holding job %d (%s): %s", job.ID, code, detail)
+		p.reasons[job.ID] = pendingReason{code, detail}
+	}
+
+	scheduled := 0
+	for i, job := range jobs {
+		if scheduled >= p.slots {
+			for _, rest := range jobs[i:] {
+				if _, ok := p.reasons[rest.ID]; !ok {
+					p.reasons[rest.ID] = pendingReason{models.PendingConcurrency, concurrencyDetail}
+				}
+			}
+			break
+		}
+		p.reasons[job.ID] = pendingReason{}
+
+		// Job arrays can limit how many of their tasks run at once
+		if job.ArrayThrottle > 0 && p.arrayRunning[job.ArrayJobID] >= job.ArrayThrottle {
+			p.reasons[job.ID] = pendingReason{models.PendingArrayTaskLimit,
+				fmt.Sprintf("array %d is running its maximum of %d tasks", job.ArrayJobID, job.ArrayThrottle)}
+			continue
+		}
+
+		// Each partition runs on its own workers within its own limits
+		part := p.partitions[job.PartitionID]
+		if part != nil {
+			if !part.up(p.workers) {
+				p.reasons[job.ID] = pendingReason{models.PendingPartitionDown,
+					fmt.Sprintf("no worker in partition %s is online", part.name)}
+				continue
+			}
+			if detail := part.holdReason(&job); detail != "" {
+				hold(&job, models.PendingPartitionLimit, detail)
+				continue
+			}
+		}
+		if code, detail := p.qos.holdReason(&job); detail != "" {
+			hold(&job, code, detail)
+			continue
+		}
+
+		// Per-user and per-group limits on running jobs and resources
+		if code, detail := p.limits.holdReason(&job); detail != "" {
+			hold(&job, code, detail)
+			continue
+		}
+		member := func(w *Worker) bool { return part == nil || part.hasWorker(w) }
+
+		// Find a suitable worker; with reservations in place, only jobs that
+		// cannot delay a reserved job may start (backfill)
+		allowed := member
+		if p.planner != nil {
+			allowed = func(w *Worker) bool { return member(w) && p.planner.allows(&job, w) }
+		}
+		worker, err := p.matcher.FindWorkerForJob(&job, p.workers, allowed)
+		if err != nil || worker == nil {
+			p.logf("No suitable worker for job %d (needs %d CPU, %d GB RAM, %d GPU)",
+				job.ID, job.CPUCores, job.MemoryGB, job.GPUCount)
+			p.reasons[job.ID] = pendingReason{models.PendingResources,
+				fmt.Sprintf("no worker has %d CPU, %d GB RAM and %d GPU free", job.CPUCores, job.MemoryGB, job.GPUCount)}
+
+			// A worker has room, but it is reserved for a higher-priority job
+			if p.planner != nil {
+				if w, _ := p.matcher.FindWorkerForJob(&job, p.workers, member); w != nil {
+					if res := p.planner.reservationOn(w.ID); res != nil {
+						p.reasons[job.ID] = pendingReason{models.PendingPriority,
+							fmt.Sprintf("worker %s is reserved for higher-priority job %d", w.Hostname, res.jobID)}
+					}
+				}
+			}
+
+			if p.blocked != nil && p.blocked(&job, member) {
+				continue
+			}
+
+			// Reserve the earliest start for the partition's highest-priority blocked job
+			if p.planner != nil && !p.planner.reserved(job.PartitionID) {
+				if res := p.planner.reserve(&job, p.workers, member); res != nil {
+					p.logf("Reserved worker %d for job %d at %s (backfilling lower-priority jobs)",
+						res.workerID, job.ID, res.startAt.Format(time.RFC3339))
+				}
+			}
+			continue
+		}
+
+		// Assign and start job
+		if !p.start(&job, worker) {
+			continue
+		}
+
+		p.logf("✓ Scheduled job %d on worker %s (priority: %.2f, placement: %s)",
+			job.ID, worker.Hostname, job.CalculatedPriority, p.matcher.Strategy().Name())
+		scheduled++
+
+		// Account for the allocation so later jobs see the remaining capacity
+		worker.allocate(&job)
+		if job.ArrayJobID != 0 {
+			p.arrayRunning[job.ArrayJobID]++
+		}
+		if part != nil {
+			part.started(&job)
+		}
+		p.qos.started(&job)
+		p.limits.started(&job)
+		if p.planner != nil {
+			p.planner.started(&job, worker)
+		}
+	}
+	return scheduled
+}
@@ -0,0 +1,126 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+func TestQueuePassReasons(t *testing.T) {
+	reasons := make(map[int]pendingReason)
+	pass := &queuePass{
+		matcher:       NewResourceMatcher(nil, firstFit{}),
+		workers:       []Worker{{ID: 1, Hostname: "node1", CPUCores: 8, MemoryGB: 32}},
+		maxConcurrent: 10,
+		slots:         2,
+		partitions: map[int]*partition{
+			1: {id: 1, name: "gpu", workers: map[int]bool{99: true}, runningByUser: map[int]int{}},
+			2: {id: 2, name: "small", maxRunning: 1, running: 1, runningByUser: map[int]int{}},
+		},
+		qos: &qosState{
+			limits:  map[int]*qosLimits{1: {name: "normal", maxJobsPerUser: 1}},
+			byUser:  map[qosKey]*qosUsage{{1, 5}: {jobs: 1, cpus: 1}},
+			byGroup: make(map[qosKey]*qosUsage),
+		},
+		limits: &limitState{
+			users:   map[int]*accountLimits{6: {maxJobs: 1}},
+			groups:  make(map[int]*accountLimits),
+			byUser:  map[int]*accountUsage{6: {jobs: 1}},
+			byGroup: make(map[int]*accountUsage),
+		},
+		arrayRunning: map[int]int{100: 2},
+		reasons:      reasons,
+		logf:         t.Logf,
+		start:        func(*JobWithPriority, *Worker) bool { return true },
+		blocked: func(job *JobWithPriority, _ func(*Worker) bool) bool {
+			if job.ID != 8 {
+				return false
+			}
+			reasons[job.ID] = pendingReason{models.PendingPreemption, "stopping 1 job(s) on worker node1"}
+			return true
+		},
+	}
+
+	jobs := []JobWithPriority{
+		{ID: 1, UserID: 1, CPUCores: 2, MemoryGB: 1},
+		{ID: 2, UserID: 2, CPUCores: 1, MemoryGB: 1, ArrayJobID: 100, ArrayThrottle: 2},
+		{ID: 3, UserID: 2, CPUCores: 1, MemoryGB: 1, PartitionID: 1},
+		{ID: 4, UserID: 2, CPUCores: 1, MemoryGB: 1, PartitionID: 2},
+		{ID: 5, UserID: 5, CPUCores: 1, MemoryGB: 1, QoSID: 1},
+		{ID: 6, UserID: 6, CPUCores: 1, MemoryGB: 1},
+		{ID: 7, UserID: 2, CPUCores: 64, MemoryGB: 1},
+		{ID: 8, UserID: 2, CPUCores: 16, MemoryGB: 1},
+		{ID: 9, UserID: 2, CPUCores: 2, MemoryGB: 1},
+		{ID: 10, UserID: 2, CPUCores: 1, MemoryGB: 1},
+	}
+	if started := pass.run(jobs); started != 2 {
+		t.Errorf("started %d jobs, want 2", started)
+	}
+
+	want := map[int]string{
+		1:  "", // Started
+		2:  models.PendingArrayTaskLimit,
+		3:  models.PendingPartitionDown,
+		4:  models.PendingPartitionLimit,
+		5:  models.PendingQoSMaxJobs,
+		6:  models.PendingUserLimit,
+		7:  models.PendingResources,
+		8:  models.PendingPreemption,
+		9:  "", // Started
+		10: models.PendingConcurrency,
+	}
+	for id, code := range want {
+		reason, ok := reasons[id]
+		if !ok || reason.code != code {
+			t.Errorf("job %d: got %+v, want %q", id, reason, code)
+		}
+		if (reason.code == "") != (reason.detail == "") {
+			t.Errorf("job %d: code %q with detail %q", id, reason.code, reason.detail)
+		}
+	}
+}
+
+func TestQueuePassReservedWorker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Node1 is busy for another hour; job 1 needs all of it and reserves it
+	workers := []Worker{{ID: 1, Hostname: "node1", CPUCores: 8, MemoryGB: 32, AllocatedCPUCores: 6, AllocatedMemoryGB: 6}}
+	active := []ActiveJob{{ID: 100, WorkerID: 1, CPUCores: 6, MemoryGB: 6, StartedAt: now, EstimatedHours: 1}}
+	reasons := make(map[int]pendingReason)
+	pass := &queuePass{
+		matcher:       NewResourceMatcher(nil, firstFit{}),
+		workers:       workers,
+		maxConcurrent: 10,
+		slots:         10,
+		partitions:    make(map[int]*partition),
+		qos:           &qosState{limits: make(map[int]*qosLimits), byUser: make(map[qosKey]*qosUsage), byGroup: make(map[qosKey]*qosUsage)},
+		limits: &limitState{users: make(map[int]*accountLimits), groups: make(map[int]*accountLimits),
+			byUser: make(map[int]*accountUsage), byGroup: make(map[int]*accountUsage)},
+		arrayRunning: make(map[int]int),
+		planner:      newBackfillPlanner(now, 1, active),
+		reasons:      reasons,
+		logf:         t.Logf,
+		start:        func(*JobWithPriority, *Worker) bool { return true },
+	}
+
+	jobs := []JobWithPriority{
+		{ID: 1, UserID: 1, CPUCores: 8, MemoryGB: 8, EstimatedHours: 2},
+		{ID: 2, UserID: 2, CPUCores: 2, MemoryGB: 2},                      // Would outlive the reservation
+		{ID: 3, UserID: 2, CPUCores: 2, MemoryGB: 2, EstimatedHours: 0.5}, // Ends before it
+	}
+	if started := pass.run(jobs); started != 1 {
+		t.Errorf("started %d jobs, want 1", started)
+	}
+
+	want := map[int]string{
+		1: models.PendingResources,
+		2: models.PendingPriority,
+		3: "",
+	}
+	for id, code := range want {
+		if reasons[id].code != code {
+			t.Errorf("job %d: got %+v, want %q", id, reasons[id], code)
+		}
+	}
+}
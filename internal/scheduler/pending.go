@@ -0,0 +1,107 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+// pendingReason is why a job is still pending: a models.Pending* code and a
+// human-readable detail
+type pendingReason struct {
+	code   string
+	detail string
+}
+
+// pendingState is a pending job's reason from the last cycle, or why it is not
+// in the scheduling queue at all
+type pendingState struct {
+	reason     pendingReason
+	evaluated  *time.Time
+	waiting    bool       // Waiting on a dependency or a retry backoff; not ranked
+	parents    []int      // Unfinished parent jobs
+	eligibleAt *time.Time // End of the retry backoff
+}
+
+// getPendingStates finds the pending jobs still waiting on a dependency or a
+// retry backoff, and the reason the last cycle recorded for the others
+func (s *Scheduler) getPendingStates() (map[int]pendingState, error) {
+	rows, err := s.db.Query(`
+		SELECT j.id, COALESCE(j.pending_reason, ''), COALESCE(j.pending_detail, ''),
+		       j.pending_reason_at, j.eligible_at, COALESCE(j.eligible_at > NOW(), FALSE),
+		       ARRAY(SELECT d.depends_on_job_id FROM job_dependencies d
+		             JOIN jobs p ON p.id = d.depends_on_job_id
+		             WHERE d.job_id = COALESCE(j.array_job_id, j.id) AND NOT ` + dependencySatisfiedSQL + `
+		             ORDER BY d.depends_on_job_id)
+		FROM jobs j
+		WHERE j.status = 'pending' AND NOT j.is_array
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := make(map[int]pendingState)
+	for rows.Next() {
+		var id int
+		var state pendingState
+		var backoff bool
+		var parents pq.Int64Array
+		err := rows.Scan(&id, &state.reason.code, &state.reason.detail, &state.evaluated,
+			&state.eligibleAt, &backoff, &parents)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case len(parents) > 0:
+			ids := make([]string, len(parents))
+			for i, p := range parents {
+				ids[i] = fmt.Sprint(p)
+				state.parents = append(state.parents, int(p))
+			}
+			state.reason = pendingReason{models.PendingDependency, "waiting for job(s) " + strings.Join(ids, ", ")}
+			state.waiting = true
+		case backoff:
+			state.reason = pendingReason{models.PendingBeginTime, "retry eligible at " + state.eligibleAt.Format(time.RFC3339)}
+			state.waiting = true
+		}
+		states[id] = state
+	}
+	return states, rows.Err()
+}
+
+// recordPendingReasons saves why each evaluated job is still pending, and
+// when it was evaluated. An empty code clears the reason, e.g. for jobs that
+// started.
+func (s *Scheduler) recordPendingReasons(reasons map[int]pendingReason) {
+	if len(reasons) == 0 {
+		return
+	}
+
+	ids := make([]int64, 0, len(reasons))
+	codes := make([]string, 0, len(reasons))
+	details := make([]string, 0, len(reasons))
+	for id, reason := range reasons {
+		ids = append(ids, int64(id))
+		codes = append(codes, reason.code)
+		details = append(details, reason.detail)
+	}
+
+	_, err := s.db.Exec(`
+		UPDATE jobs j
+		SET pending_reason = NULLIF(r.code, ''),
+		    pending_detail = NULLIF(r.detail, ''),
+		    pending_reason_at = NOW()
+		FROM unnest($1::int[], $2::text[], $3::text[]) AS r(id, code, detail)
+		WHERE j.id = r.id
+	`, pq.Array(ids), pq.Array(codes), pq.Array(details))
+	if err != nil {
+		log.Printf("Error saving pending reasons: %v", err)
+	}
+}
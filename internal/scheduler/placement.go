@@ -0,0 +1,118 @@
+package scheduler
+
+import "fmt"
+
+// Placement strategy names (PLACEMENT_STRATEGY)
+const (
+	PlacementFirstFit = "first-fit"
+	PlacementBestFit  = "best-fit"
+	PlacementWorstFit = "worst-fit"
+	PlacementGPUAvoid = "gpu-avoid"
+)
+
+// PlacementStrategy picks which worker a job runs on
+type PlacementStrategy interface {
+	// Name returns the strategy name used in config and logs
+	Name() string
+	// Choose picks one of the candidates, all of which have room for the job
+	Choose(job *JobWithPriority, candidates []*Worker) *Worker
+}
+
+// NewPlacementStrategy returns the strategy with the given name
+func NewPlacementStrategy(name string) (PlacementStrategy, error) {
+	switch name {
+	case PlacementFirstFit:
+		return firstFit{}, nil
+	case PlacementBestFit:
+		return bestFit{}, nil
+	case PlacementWorstFit:
+		return worstFit{}, nil
+	case PlacementGPUAvoid:
+		return gpuAvoid{}, nil
+	default:
+		return nil, fmt.Errorf("unknown placement strategy %q (use %s, %s, %s or %s)",
+			name, PlacementFirstFit, PlacementBestFit, PlacementWorstFit, PlacementGPUAvoid)
+	}
+}
+
+// firstFit picks the first worker with room, in worker ID order
+type firstFit struct{}
+
+func (firstFit) Name() string { return PlacementFirstFit }
+
+func (firstFit) Choose(job *JobWithPriority, candidates []*Worker) *Worker {
+	if len(candidates) == 0 {
+		return nil
+	}
+	return candidates[0]
+}
+
+// bestFit picks the worker left with the least spare capacity, keeping big nodes free for big jobs
+type bestFit struct{}
+
+func (bestFit) Name() string { return PlacementBestFit }
+
+func (bestFit) Choose(job *JobWithPriority, candidates []*Worker) *Worker {
+	return pickByLeftover(job, candidates, func(a, b float64) bool { return a < b })
+}
+
+// worstFit picks the worker left with the most spare capacity, spreading load across nodes
+type worstFit struct{}
+
+func (worstFit) Name() string { return PlacementWorstFit }
+
+func (worstFit) Choose(job *JobWithPriority, candidates []*Worker) *Worker {
+	return pickByLeftover(job, candidates, func(a, b float64) bool { return a > b })
+}
+
+// gpuAvoid keeps GPU nodes free for GPU jobs: jobs that need no GPU go to the
+// worker with the fewest free GPUs, then best-fit among those
+type gpuAvoid struct{}
+
+func (gpuAvoid) Name() string { return PlacementGPUAvoid }
+
+func (gpuAvoid) Choose(job *JobWithPriority, candidates []*Worker) *Worker {
+	if job.GPUCount > 0 || len(candidates) == 0 {
+		return bestFit{}.Choose(job, candidates)
+	}
+
+	fewestGPUs := candidates[0].FreeGPUs()
+	for _, w := range candidates[1:] {
+		if w.FreeGPUs() < fewestGPUs {
+			fewestGPUs = w.FreeGPUs()
+		}
+	}
+
+	var preferred []*Worker
+	for _, w := range candidates {
+		if w.FreeGPUs() == fewestGPUs {
+			preferred = append(preferred, w)
+		}
+	}
+	return bestFit{}.Choose(job, preferred)
+}
+
+// pickByLeftover returns the candidate whose leftover score wins under better
+func pickByLeftover(job *JobWithPriority, candidates []*Worker, better func(a, b float64) bool) *Worker {
+	var chosen *Worker
+	var chosenScore float64
+	for _, w := range candidates {
+		score := leftoverScore(w, job)
+		if chosen == nil || better(score, chosenScore) {
+			chosen = w
+			chosenScore = score
+		}
+	}
+	return chosen
+}
+
+// leftoverScore is the fraction of each resource left free after placing the job,
+// summed over CPU, memory and GPU (GPU only counts on GPU nodes)
+func leftoverScore(w *Worker, job *JobWithPriority) float64 {
+	score := float64(w.FreeCPUCores()-job.CPUCores)/float64(w.CPUCores) +
+		float64(w.FreeMemoryGB()-job.MemoryGB)/float64(w.MemoryGB)
+	if w.GPUCount > 0 {
+		score += float64(w.FreeGPUs()-job.GPUCount) / float64(w.GPUCount)
+	}
+	return score
+}
@@ -0,0 +1,94 @@
+package scheduler
+
+import "testing"
+
+func TestPlacementStrategies(t *testing.T) {
+	workers := []Worker{
+		{ID: 1, CPUCores: 32, MemoryGB: 128},
+		{ID: 2, CPUCores: 8, MemoryGB: 32},
+		{ID: 3, CPUCores: 16, MemoryGB: 64, GPUCount: 4},
+	}
+	cpuJob := &JobWithPriority{ID: 1, CPUCores: 4, MemoryGB: 8}
+	gpuJob := &JobWithPriority{ID: 2, CPUCores: 4, MemoryGB: 8, GPUCount: 1}
+
+	tests := []struct {
+		strategy string
+		job      *JobWithPriority
+		want     int
+	}{
+		{PlacementFirstFit, cpuJob, 1},
+		{PlacementBestFit, cpuJob, 2},  // Least left over
+		{PlacementWorstFit, cpuJob, 3}, // Most left over, GPUs included
+		{PlacementGPUAvoid, cpuJob, 2}, // Best fit among the workers without free GPUs
+		{PlacementFirstFit, gpuJob, 3},
+		{PlacementBestFit, gpuJob, 3},
+		{PlacementGPUAvoid, gpuJob, 3},
+	}
+	for _, tt := range tests {
+		strategy, err := NewPlacementStrategy(tt.strategy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := NewResourceMatcher(nil, strategy).FindWorkerForJob(tt.job, workers, nil)
+		if err != nil {
+			t.Errorf("%s, job %d: %v", tt.strategy, tt.job.ID, err)
+			continue
+		}
+		if w.ID != tt.want {
+			t.Errorf("%s, job %d: got worker %d, want %d", tt.strategy, tt.job.ID, w.ID, tt.want)
+		}
+	}
+}
+
+func TestBestFitCountsAllocations(t *testing.T) {
+	// Worker 1 is the biggest, but most of it is taken
+	workers := []Worker{
+		{ID: 1, CPUCores: 32, MemoryGB: 128, AllocatedCPUCores: 28, AllocatedMemoryGB: 8},
+		{ID: 2, CPUCores: 8, MemoryGB: 32},
+	}
+	job := &JobWithPriority{CPUCores: 4, MemoryGB: 8}
+
+	w, err := NewResourceMatcher(nil, bestFit{}).FindWorkerForJob(job, workers, nil)
+	if err != nil || w.ID != 1 {
+		t.Errorf("got %+v, %v; want worker 1", w, err)
+	}
+
+	// A worker without room is never chosen, even by first-fit
+	job.CPUCores = 6
+	w, err = NewResourceMatcher(nil, firstFit{}).FindWorkerForJob(job, workers, nil)
+	if err != nil || w.ID != 2 {
+		t.Errorf("got %+v, %v; want worker 2", w, err)
+	}
+}
+
+func TestFindWorkerForJobAllowed(t *testing.T) {
+	workers := []Worker{
+		{ID: 1, CPUCores: 8, MemoryGB: 32},
+		{ID: 2, CPUCores: 8, MemoryGB: 32},
+	}
+	rm := NewResourceMatcher(nil, firstFit{})
+	job := &JobWithPriority{CPUCores: 2, MemoryGB: 2}
+
+	w, err := rm.FindWorkerForJob(job, workers, func(w *Worker) bool { return w.ID == 2 })
+	if err != nil || w.ID != 2 {
+		t.Errorf("got %+v, %v; want worker 2", w, err)
+	}
+	if _, err := rm.FindWorkerForJob(job, workers, func(*Worker) bool { return false }); err == nil {
+		t.Error("no allowed worker: expected an error")
+	}
+	if _, err := rm.FindWorkerForJob(&JobWithPriority{CPUCores: 16}, workers, nil); err == nil {
+		t.Error("job bigger than every worker: expected an error")
+	}
+}
+
+func TestNewPlacementStrategy(t *testing.T) {
+	for _, name := range []string{PlacementFirstFit, PlacementBestFit, PlacementWorstFit, PlacementGPUAvoid} {
+		s, err := NewPlacementStrategy(name)
+		if err != nil || s.Name() != name {
+			t.Errorf("%s: got %v, %v", name, s, err)
+		}
+	}
+	if _, err := NewPlacementStrategy("random"); err == nil {
+		t.Error("unknown strategy: expected an error")
+	}
+}
@@ -0,0 +1,386 @@
+package scheduler
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/samik-k21/research-compute-queue/internal/database"
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+// preemptionCandidate is a running preemptible job that could be stopped
+type preemptionCandidate struct {
+	job       JobWithPriority
+	workerID  int
+	startedAt time.Time
+}
+
+// lostWork is the CPU time thrown away if the candidate is stopped now
+func (pc *preemptionCandidate) lostWork(now time.Time) float64 {
+	return now.Sub(pc.startedAt).Seconds() * float64(pc.job.CPUCores)
+}
+
+// preemptionHold is the resources a job's victims free on their worker once they stop
+type preemptionHold struct {
+	workerID      int
+	cpu, mem, gpu int
+}
+
+// preemptionPlanner picks which preemptible jobs to stop so a blocked
+// higher-priority job can start
+type preemptionPlanner struct {
+	now        time.Time
+	candidates []preemptionCandidate
+	waiting    map[int]preemptionHold // Jobs whose victims are still stopping
+}
+
+// newPreemptionPlanner loads the running preemptible jobs and their priorities,
+// and the jobs already waiting for victims to stop
+func (s *Scheduler) newPreemptionPlanner() (*preemptionPlanner, error) {
+	rows, err := s.db.Query(`
+		SELECT j.id, j.user_id, j.group_id, j.cpu_cores, j.memory_gb, j.gpu_count,
+		       j.priority, j.submitted_at, COALESCE(j.estimated_hours, 0),
+		       g.priority, COALESCE(q.priority_factor, 1), COALESCE(j.partition_id, 0),
+		       a.worker_id, j.started_at
+		FROM jobs j
+		JOIN groups g ON g.id = j.group_id
+		JOIN worker_allocations a ON a.job_id = j.id
+		LEFT JOIN qos q ON q.id = j.qos_id
+		WHERE j.status = 'running' AND j.preemptible AND j.preempted_by IS NULL
+		  AND j.started_at IS NOT NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []JobWithPriority
+	placed := make(map[int]preemptionCandidate)
+	for rows.Next() {
+		var c preemptionCandidate
+		err := rows.Scan(&c.job.ID, &c.job.UserID, &c.job.GroupID, &c.job.CPUCores, &c.job.MemoryGB,
+			&c.job.GPUCount, &c.job.Priority, &c.job.SubmittedAt, &c.job.EstimatedHours,
+			&c.job.GroupPriority, &c.job.QoSFactor, &c.job.PartitionID, &c.workerID, &c.startedAt)
+		if err != nil {
+			log.Printf("Error scanning preemptible job: %v", err)
+			continue
+		}
+		c.job.Preemptible = true
+		jobs = append(jobs, c.job)
+		placed[c.job.ID] = c
+	}
+	rows.Close()
+
+	// Running jobs are ranked with the same formula as the queue
+	jobs, err = s.priorityCalc.CalculatePriorities(jobs)
+	if err != nil {
+		return nil, err
+	}
+
+	pp := &preemptionPlanner{now: time.Now(), waiting: make(map[int]preemptionHold)}
+	for _, job := range jobs {
+		c := placed[job.ID]
+		c.job = job
+		pp.candidates = append(pp.candidates, c)
+	}
+
+	holds, err := s.db.Query(`
+		SELECT j.preempted_by, a.worker_id,
+		       SUM(a.cpu_cores), SUM(a.memory_gb), SUM(a.gpu_count)
+		FROM jobs j
+		JOIN worker_allocations a ON a.job_id = j.id
+		WHERE j.status = 'running' AND j.preempted_by IS NOT NULL
+		GROUP BY j.preempted_by, a.worker_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer holds.Close()
+
+	for holds.Next() {
+		var jobID int
+		var h preemptionHold
+		if err := holds.Scan(&jobID, &h.workerID, &h.cpu, &h.mem, &h.gpu); err != nil {
+			log.Printf("Error scanning preempted job: %v", err)
+			continue
+		}
+		pp.waiting[jobID] = h
+	}
+
+	return pp, nil
+}
+
+// victimsFor returns the worker and the smallest set of lower-priority
+// preemptible jobs on it whose stopping lets job start. Only workers member
+// accepts are considered. Among them, the one needing the fewest victims wins,
+// then the one losing the least work. Only jobs whose QoS grants preemption
+// rights preempt, and preemptible jobs never do.
+func (pp *preemptionPlanner) victimsFor(job *JobWithPriority, workers []Worker, member func(*Worker) bool) (*Worker, []preemptionCandidate) {
+	if job.Preemptible || !job.CanPreempt {
+		return nil, nil
+	}
+
+	var bestWorker *Worker
+	var best []preemptionCandidate
+	var bestLost float64
+	for i := range workers {
+		w := &workers[i]
+		if w.CPUCores < job.CPUCores || w.MemoryGB < job.MemoryGB || w.GPUCount < job.GPUCount {
+			continue // Too small even when empty
+		}
+		if !member(w) {
+			continue
+		}
+
+		victims := pp.victimsOnWorker(job, w)
+		if len(victims) == 0 {
+			continue
+		}
+
+		lost := 0.0
+		for i := range victims {
+			lost += victims[i].lostWork(pp.now)
+		}
+		if bestWorker == nil || len(victims) < len(best) || (len(victims) == len(best) && lost < bestLost) {
+			bestWorker, best, bestLost = w, victims, lost
+		}
+	}
+	return bestWorker, best
+}
+
+// victimsOnWorker picks victims on one worker: the lowest-priority (then most
+// recently started) jobs are taken until the job fits, then any victim the job
+// does not need after all is spared. Returns nil if the job cannot fit there
+// or already fits without preempting anything.
+func (pp *preemptionPlanner) victimsOnWorker(job *JobWithPriority, w *Worker) []preemptionCandidate {
+	cpu, mem, gpu := w.FreeCPUCores(), w.FreeMemoryGB(), w.FreeGPUs()
+	fits := func(cpu, mem, gpu int) bool {
+		return cpu >= job.CPUCores && mem >= job.MemoryGB && gpu >= job.GPUCount
+	}
+	if fits(cpu, mem, gpu) {
+		return nil // Held back by backfill, not by running jobs
+	}
+
+	var eligible []preemptionCandidate
+	for _, c := range pp.candidates {
+		if c.workerID == w.ID && c.job.CalculatedPriority < job.CalculatedPriority {
+			eligible = append(eligible, c)
+		}
+	}
+	sort.SliceStable(eligible, func(i, j int) bool {
+		if eligible[i].job.CalculatedPriority != eligible[j].job.CalculatedPriority {
+			return eligible[i].job.CalculatedPriority < eligible[j].job.CalculatedPriority
+		}
+		return eligible[i].startedAt.After(eligible[j].startedAt)
+	})
+
+	var victims []preemptionCandidate
+	for _, c := range eligible {
+		if fits(cpu, mem, gpu) {
+			break
+		}
+		victims = append(victims, c)
+		cpu += c.job.CPUCores
+		mem += c.job.MemoryGB
+		gpu += c.job.GPUCount
+	}
+	if !fits(cpu, mem, gpu) {
+		return nil
+	}
+
+	// Spare the highest-priority victims first if the job fits without them
+	for i := len(victims) - 1; i >= 0; i-- {
+		c := victims[i]
+		if fits(cpu-c.job.CPUCores, mem-c.job.MemoryGB, gpu-c.job.GPUCount) {
+			cpu -= c.job.CPUCores
+			mem -= c.job.MemoryGB
+			gpu -= c.job.GPUCount
+			victims = append(victims[:i], victims[i+1:]...)
+		}
+	}
+	return victims
+}
+
+// hold sets aside on the worker what the job needs beyond what its victims
+// free, so lower-priority jobs cannot take it while the victims stop
+func (pp *preemptionPlanner) hold(job *JobWithPriority, w *Worker, freedCPU, freedMem, freedGPU int) {
+	w.AllocatedCPUCores += job.CPUCores - freedCPU
+	w.AllocatedMemoryGB += job.MemoryGB - freedMem
+	w.AllocatedGPUs += job.GPUCount - freedGPU
+}
+
+// holdWaiting re-applies the hold for a job still waiting for its victims.
+// Returns false if the job has no victims stopping.
+func (pp *preemptionPlanner) holdWaiting(job *JobWithPriority, workers []Worker) bool {
+	h, ok := pp.waiting[job.ID]
+	if !ok {
+		return false
+	}
+	for i := range workers {
+		if workers[i].ID == h.workerID {
+			pp.hold(job, &workers[i], h.cpu, h.mem, h.gpu)
+		}
+	}
+	return true
+}
+
+// preempt marks the victims as preempted by job, records the events and stops
+// them. Each victim is requeued or cancelled per its preempt_mode once its
+// process has exited (see finishPreemption), which frees its resources.
+// Returns false if none of the victims could be preempted.
+func (s *Scheduler) preempt(pp *preemptionPlanner, job *JobWithPriority, w *Worker, victims []preemptionCandidate) bool {
+	var freedCPU, freedMem, freedGPU int
+	marked := 0
+	for _, v := range victims {
+		reason := fmt.Sprintf("%s: stopped for higher-priority job %d (priority %.2f > %.2f)",
+			models.PreemptedReason, job.ID, job.CalculatedPriority, v.job.CalculatedPriority)
+		if !s.markPreempted(v.job.ID, job.ID, w.ID, reason) {
+			continue
+		}
+		log.Printf("Preempting job %d on worker %s for job %d", v.job.ID, w.Hostname, job.ID)
+		s.CancelJob(v.job.ID, reason)
+
+		marked++
+		freedCPU += v.job.CPUCores
+		freedMem += v.job.MemoryGB
+		freedGPU += v.job.GPUCount
+	}
+
+	// Victims are only preempted once
+	preempted := make(map[int]bool, len(victims))
+	for _, v := range victims {
+		preempted[v.job.ID] = true
+	}
+	remaining := pp.candidates[:0]
+	for _, c := range pp.candidates {
+		if !preempted[c.job.ID] {
+			remaining = append(remaining, c)
+		}
+	}
+	pp.candidates = remaining
+
+	if marked == 0 {
+		return false
+	}
+	pp.waiting[job.ID] = preemptionHold{workerID: w.ID, cpu: freedCPU, mem: freedMem, gpu: freedGPU}
+	pp.hold(job, w, freedCPU, freedMem, freedGPU)
+	return true
+}
+
+// markPreempted flags a running job as being stopped for preemptor and records
+// the preemption event. Returns false if the job is no longer running or is
+// already being preempted.
+func (s *Scheduler) markPreempted(jobID, preemptorID, workerID int, reason string) bool {
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Printf("Error preempting job %d: %v", jobID, err)
+		return false
+	}
+	defer tx.Rollback()
+
+	var mode string
+	err = tx.QueryRow(`
+		UPDATE jobs SET preempted_by = $1, error_message = $2
+		WHERE id = $3 AND status = 'running' AND preempted_by IS NULL
+		RETURNING preempt_mode
+	`, preemptorID, reason, jobID).Scan(&mode)
+	if err == sql.ErrNoRows {
+		return false // Finished or cancelled in the meantime
+	}
+	if err != nil {
+		log.Printf("Error preempting job %d: %v", jobID, err)
+		return false
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO preemption_events (job_id, preemptor_job_id, worker_id, action, reason, preempted_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, jobID, preemptorID, workerID, mode, reason, time.Now())
+	if err != nil {
+		log.Printf("Error recording preemption of job %d: %v", jobID, err)
+		return false
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error preempting job %d: %v", jobID, err)
+		return false
+	}
+	return true
+}
+
+// finishPreemption requeues or cancels a preempted job once its process has
+// stopped, per its preempt_mode, and frees its resources. The preempted run is
+// kept in the attempt history but not charged to the group's fair-share usage.
+// Returns false if the job was not being preempted.
+func (e *Executor) finishPreemption(jobID, exitCode int) bool {
+	tx, err := e.db.Begin()
+	if err != nil {
+		log.Printf("Error finishing preemption of job %d: %v", jobID, err)
+		return false
+	}
+	defer tx.Rollback()
+
+	var mode string
+	var preemptorID int
+	err = tx.QueryRow(`
+		SELECT preempt_mode, preempted_by FROM jobs
+		WHERE id = $1 AND status = 'running' AND preempted_by IS NOT NULL
+		FOR UPDATE
+	`, jobID).Scan(&mode, &preemptorID)
+	if err == sql.ErrNoRows {
+		return false
+	}
+	if err != nil {
+		log.Printf("Error loading preemption of job %d: %v", jobID, err)
+		return false
+	}
+
+	now := time.Now()
+	if mode == models.PreemptCancel {
+		if _, err := tx.Exec(insertAttemptSQL, jobID, exitCode, models.StatusCancelled, ""); err != nil {
+			log.Printf("Error recording attempt of job %d: %v", jobID, err)
+			return false
+		}
+		_, err = tx.Exec(`
+			UPDATE jobs
+			SET status = 'cancelled', completed_at = $1, exit_code = $2,
+			    preempted_by = NULL, preemption_count = preemption_count + 1
+			WHERE id = $3
+		`, now, exitCode, jobID)
+	} else {
+		if _, err := tx.Exec(insertAttemptSQL, jobID, exitCode, models.AttemptRequeued, ""); err != nil {
+			log.Printf("Error recording attempt of job %d: %v", jobID, err)
+			return false
+		}
+		_, err = tx.Exec(`
+			UPDATE jobs
+			SET status = 'pending', started_at = NULL, claimed_at = NULL, worker_id = NULL,
+			    pid = NULL, exit_code = NULL,
+			    preempted_by = NULL, preemption_count = preemption_count + 1
+			WHERE id = $1
+		`, jobID)
+	}
+	if err != nil {
+		log.Printf("Error finishing preemption of job %d: %v", jobID, err)
+		return false
+	}
+
+	if _, err := releaseAllocation(tx, jobID); err != nil {
+		log.Printf("Error releasing allocation for job %d: %v", jobID, err)
+		return false
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error finishing preemption of job %d: %v", jobID, err)
+		return false
+	}
+
+	// The freed resources are what the preempting job is waiting for
+	database.Notify(e.db, database.EventJobCompleted, jobID)
+
+	log.Printf("Job %d preempted for job %d (%s)", jobID, preemptorID, mode)
+	return true
+}
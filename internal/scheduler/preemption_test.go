@@ -0,0 +1,120 @@
+package scheduler
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestVictimsOnWorker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Worker 1 is full; job 4 runs elsewhere and is never a victim here
+	worker := Worker{ID: 1, CPUCores: 16, MemoryGB: 64, AllocatedCPUCores: 16, AllocatedMemoryGB: 16}
+	pp := &preemptionPlanner{now: now, candidates: []preemptionCandidate{
+		{JobWithPriority{ID: 1, CPUCores: 4, MemoryGB: 4, CalculatedPriority: 10}, 1, now.Add(-time.Hour)},
+		{JobWithPriority{ID: 2, CPUCores: 4, MemoryGB: 4, CalculatedPriority: 10}, 1, now.Add(-10 * time.Minute)},
+		{JobWithPriority{ID: 3, CPUCores: 8, MemoryGB: 8, CalculatedPriority: 20}, 1, now.Add(-2 * time.Hour)},
+		{JobWithPriority{ID: 4, CPUCores: 8, MemoryGB: 8, CalculatedPriority: 1}, 2, now},
+	}}
+
+	tests := []struct {
+		cpu      int
+		priority float64
+		want     []int
+	}{
+		{4, 50, []int{2}},        // Lowest priority, most recently started
+		{8, 50, []int{2, 1}},     // Both of the lowest priority
+		{12, 50, []int{2, 3}},    // Job 1 is spared once job 3 is taken
+		{16, 50, []int{2, 1, 3}}, // Everything
+		{17, 50, nil},            // Bigger than the worker
+		{8, 15, []int{2, 1}},     // Job 3 outranks the job
+		{12, 15, nil},            // Not enough lower-priority jobs
+		{4, 10, nil},             // Equal priority is not enough
+	}
+	for _, tt := range tests {
+		job := &JobWithPriority{ID: 10, CPUCores: tt.cpu, MemoryGB: 1, CalculatedPriority: tt.priority}
+		var got []int
+		for _, v := range pp.victimsOnWorker(job, &worker) {
+			got = append(got, v.job.ID)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%d CPU at priority %.0f: got victims %v, want %v", tt.cpu, tt.priority, got, tt.want)
+		}
+	}
+
+	// A job that already fits is held back by something else
+	worker.AllocatedCPUCores = 8
+	job := &JobWithPriority{ID: 10, CPUCores: 4, MemoryGB: 1, CalculatedPriority: 50}
+	if got := pp.victimsOnWorker(job, &worker); got != nil {
+		t.Errorf("job fits: got %d victims", len(got))
+	}
+}
+
+func TestVictimsFor(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	all := func(*Worker) bool { return true }
+
+	// Worker 1 runs two small jobs, worker 2 one big long-running job
+	workers := []Worker{
+		{ID: 1, CPUCores: 8, MemoryGB: 32, AllocatedCPUCores: 8, AllocatedMemoryGB: 8},
+		{ID: 2, CPUCores: 8, MemoryGB: 32, AllocatedCPUCores: 8, AllocatedMemoryGB: 8},
+		{ID: 3, CPUCores: 2, MemoryGB: 32},
+	}
+	pp := &preemptionPlanner{now: now, candidates: []preemptionCandidate{
+		{JobWithPriority{ID: 1, CPUCores: 4, MemoryGB: 4, CalculatedPriority: 10}, 1, now.Add(-time.Hour)},
+		{JobWithPriority{ID: 2, CPUCores: 4, MemoryGB: 4, CalculatedPriority: 10}, 1, now.Add(-time.Hour)},
+		{JobWithPriority{ID: 3, CPUCores: 8, MemoryGB: 8, CalculatedPriority: 10}, 2, now.Add(-3 * time.Hour)},
+	}}
+
+	tests := []struct {
+		name        string
+		job         JobWithPriority
+		member      func(*Worker) bool
+		wantWorker  int
+		wantVictims []int
+	}{
+		{"fewest victims", JobWithPriority{ID: 10, CPUCores: 8, MemoryGB: 1, CalculatedPriority: 50, CanPreempt: true}, all, 2, []int{3}},
+		{"least lost work", JobWithPriority{ID: 10, CPUCores: 4, MemoryGB: 1, CalculatedPriority: 50, CanPreempt: true}, all, 1, []int{1}},
+		{"member workers only", JobWithPriority{ID: 10, CPUCores: 4, MemoryGB: 1, CalculatedPriority: 50, CanPreempt: true}, func(w *Worker) bool { return w.ID == 2 }, 2, []int{3}},
+		{"no preemption rights", JobWithPriority{ID: 10, CPUCores: 4, MemoryGB: 1, CalculatedPriority: 50}, all, 0, nil},
+		{"preemptible job", JobWithPriority{ID: 10, CPUCores: 4, MemoryGB: 1, CalculatedPriority: 50, CanPreempt: true, Preemptible: true}, all, 0, nil},
+		{"outranked everywhere", JobWithPriority{ID: 10, CPUCores: 4, MemoryGB: 1, CalculatedPriority: 5, CanPreempt: true}, all, 0, nil},
+	}
+	for _, tt := range tests {
+		w, victims := pp.victimsFor(&tt.job, workers, tt.member)
+		gotWorker := 0
+		if w != nil {
+			gotWorker = w.ID
+		}
+		var got []int
+		for _, v := range victims {
+			got = append(got, v.job.ID)
+		}
+		if gotWorker != tt.wantWorker || !slices.Equal(got, tt.wantVictims) {
+			t.Errorf("%s: got worker %d with victims %v, want worker %d with %v",
+				tt.name, gotWorker, got, tt.wantWorker, tt.wantVictims)
+		}
+	}
+}
+
+func TestHoldWaiting(t *testing.T) {
+	workers := []Worker{
+		{ID: 1, CPUCores: 16, MemoryGB: 64, AllocatedCPUCores: 8, AllocatedMemoryGB: 8},
+		{ID: 2, CPUCores: 16, MemoryGB: 64},
+	}
+	pp := &preemptionPlanner{waiting: map[int]preemptionHold{10: {workerID: 1, cpu: 4, mem: 4}}}
+
+	// Job 10 needs 12 cores, 4 of which its victims free once they stop
+	job := &JobWithPriority{ID: 10, CPUCores: 12, MemoryGB: 8}
+	if !pp.holdWaiting(job, workers) {
+		t.Fatal("expected job 10 to be waiting")
+	}
+	if workers[0].AllocatedCPUCores != 16 || workers[0].AllocatedMemoryGB != 12 || workers[1].AllocatedCPUCores != 0 {
+		t.Errorf("got allocations %+v", workers)
+	}
+
+	if pp.holdWaiting(&JobWithPriority{ID: 11, CPUCores: 1}, workers) {
+		t.Error("job 11 has no victims: expected false")
+	}
+}
@@ -2,155 +2,171 @@ package scheduler
 
 import (
 	"log"
+	"sort"
 	"time"
 
+	"github.com/samik-k21/research-compute-queue/internal/config"
 	"github.com/samik-k21/research-compute-queue/internal/database"
 )
 
-// PriorityCalculator calculates job priorities using fair-share algorithm
+// PriorityCalculator calculates job priorities with a multifactor formula:
+// weighted age, fair-share, job size, QoS and partition factors, scaled by
+// the group's and the job's own priority
 type PriorityCalculator struct {
-	db *database.DB
+	db         *database.DB
+	weights    config.PriorityWeights
+	maxAge     time.Duration // Wait at which the age factor reaches 1
+	halfLife   time.Duration // Fair-share usage half-life (0 = no decay)
+	favorSmall bool          // Job size factor favours small jobs
 }
 
 // NewPriorityCalculator creates a new priority calculator
-func NewPriorityCalculator(db *database.DB) *PriorityCalculator {
-	return &PriorityCalculator{db: db}
+func NewPriorityCalculator(db *database.DB, cfg *config.Config) *PriorityCalculator {
+	return &PriorityCalculator{
+		db:         db,
+		weights:    cfg.PriorityWeights,
+		maxAge:     time.Duration(cfg.PriorityMaxAgeHours * float64(time.Hour)),
+		halfLife:   time.Duration(cfg.FairShareHalfLifeHours * float64(time.Hour)),
+		favorSmall: cfg.PriorityFavorSmall,
+	}
+}
+
+// priorityContext is the cluster-wide state a round of priorities is computed from
+type priorityContext struct {
+	now          time.Time
+	fairShare    *fairShareTree
+	maxQoSFactor float64     // Largest QoS priority factor
+	tiers        map[int]int // Partition ID → priority tier
+	maxTier      int
+	clusterCPUs  int // CPU cores of the online workers
+}
+
+// priorityFactors breaks a job's priority down. Every factor is between 0 and 1.
+type priorityFactors struct {
+	base      float64 // Group priority + job priority / 10
+	age       float64
+	fairShare float64
+	jobSize   float64
+	qos       float64
+	partition float64
 }
 
 // CalculatePriorities computes final priority for each job
 func (pc *PriorityCalculator) CalculatePriorities(jobs []JobWithPriority) ([]JobWithPriority, error) {
-	// Get usage data for fair-share calculation
-	groupUsage, err := pc.getGroupUsage()
+	ctx, err := pc.loadPriorityContext()
 	if err != nil {
-		log.Printf("Error getting group usage: %v", err)
-		return jobs, nil // Continue with base priorities
+		log.Printf("Error loading priority factors: %v", err)
+		return jobs, nil // Continue in submission order
 	}
-	
-	// Calculate priority for each job
+
+	pc.rank(jobs, ctx)
+	return jobs, nil
+}
+
+// rank computes every job's priority and sorts the jobs, highest first
+func (pc *PriorityCalculator) rank(jobs []JobWithPriority, ctx *priorityContext) {
 	for i := range jobs {
-		jobs[i].CalculatedPriority = pc.calculateJobPriority(&jobs[i], groupUsage)
+		jobs[i].CalculatedPriority = pc.priority(pc.factors(&jobs[i], ctx))
 	}
-	
-	// Sort jobs by calculated priority (highest first)
 	sortJobsByPriority(jobs)
-	
-	return jobs, nil
 }
 
-// calculateJobPriority computes the final priority score
-func (pc *PriorityCalculator) calculateJobPriority(job *JobWithPriority, groupUsage map[int]UsageData) float64 {
-	// Base priority (1-10, from job and group)
-	basePriority := float64(job.Priority + job.GroupPriority)
-	
-	// Fair-share multiplier
-	fairShareMultiplier := pc.calculateFairShare(job.GroupID, groupUsage)
-	
-	// Wait time boost (jobs waiting longer get priority boost)
-	waitTimeBoost := pc.calculateWaitTimeBoost(job.SubmittedAt)
-	
-	// Final priority formula
-	finalPriority := basePriority * fairShareMultiplier * waitTimeBoost
-	
-	return finalPriority
-}
+// factors computes each priority factor of a job
+func (pc *PriorityCalculator) factors(job *JobWithPriority, ctx *priorityContext) priorityFactors {
+	// Users can set any priority, so it only adds up to one group step,
+	// ordering their own jobs rather than competing with other groups
+	f := priorityFactors{
+		base:      float64(job.GroupPriority) + float64(job.Priority)/10,
+		fairShare: ctx.fairShare.factors[job.UserID],
+	}
+
+	// Age grows linearly until the maximum age
+	if pc.maxAge > 0 {
+		f.age = clampFactor(ctx.now.Sub(job.SubmittedAt).Seconds() / pc.maxAge.Seconds())
+	}
+
+	// Job size is the job's fraction of the cluster's CPU cores
+	if ctx.clusterCPUs > 0 {
+		f.jobSize = clampFactor(float64(job.CPUCores) / float64(ctx.clusterCPUs))
+	}
+	if pc.favorSmall {
+		f.jobSize = 1 - f.jobSize
+	}
 
-// calculateFairShare computes fair-share multiplier for a group
-func (pc *PriorityCalculator) calculateFairShare(groupID int, groupUsage map[int]UsageData) float64 {
-	usage, exists := groupUsage[groupID]
-	if !exists {
-		return 1.0 // No usage data, use neutral multiplier
-	}
-	
-	// If group hasn't used anything, boost their priority
-	if usage.CPUHoursUsed == 0 {
-		return 2.0
-	}
-	
-	// Calculate fair-share ratio
-	// If group uses less than quota → ratio > 1 (boost)
-	// If group uses more than quota → ratio < 1 (penalty)
-	quota := float64(usage.CPUQuota)
-	used := usage.CPUHoursUsed
-	
-	if quota <= 0 {
-		return 1.0
-	}
-	
-	ratio := quota / used
-	
-	// Cap the multiplier between 0.5 and 2.0
-	if ratio > 2.0 {
-		ratio = 2.0
-	}
-	if ratio < 0.5 {
-		ratio = 0.5
-	}
-	
-	return ratio
+	if ctx.maxQoSFactor > 0 {
+		f.qos = clampFactor(job.QoSFactor / ctx.maxQoSFactor)
+	}
+	if ctx.maxTier > 0 {
+		f.partition = clampFactor(float64(ctx.tiers[job.PartitionID]) / float64(ctx.maxTier))
+	}
+	return f
 }
 
-// calculateWaitTimeBoost increases priority for jobs waiting longer
-func (pc *PriorityCalculator) calculateWaitTimeBoost(submittedAt time.Time) float64 {
-	waitMinutes := time.Since(submittedAt).Minutes()
-	
-	// 1% boost per hour of waiting
-	// Job waiting 1 hour: 1.01
-	// Job waiting 10 hours: 1.10
-	boost := 1.0 + (waitMinutes / 60.0 * 0.01)
-	
-	// Cap at 1.5x (50% boost after 50 hours)
-	if boost > 1.5 {
-		boost = 1.5
-	}
-	
-	return boost
+// priority weighs the factors and scales them by the base priority
+func (pc *PriorityCalculator) priority(f priorityFactors) float64 {
+	w := pc.weights
+	return f.base * (w.Age*f.age + w.FairShare*f.fairShare + w.JobSize*f.jobSize +
+		w.QoS*f.qos + w.Partition*f.partition)
 }
 
-// UsageData holds group resource usage information
-type UsageData struct {
-	GroupID       int
-	CPUQuota      int
-	CPUHoursUsed  float64
+// clampFactor limits a factor to [0, 1]
+func clampFactor(f float64) float64 {
+	if f < 0 {
+		return 0
+	}
+	if f > 1 {
+		return 1
+	}
+	return f
 }
 
-// getGroupUsage retrieves recent usage for all groups
-func (pc *PriorityCalculator) getGroupUsage() (map[int]UsageData, error) {
-	// Get usage from last 30 days
-	thirtyDaysAgo := time.Now().AddDate(0, 0, -30)
-	
-	rows, err := pc.db.Query(`
-		SELECT g.id, g.cpu_quota, COALESCE(SUM(ul.cpu_hours_used), 0) as total_used
-		FROM groups g
-		LEFT JOIN usage_logs ul ON g.id = ul.group_id AND ul.logged_at > $1
-		GROUP BY g.id, g.cpu_quota
-	`, thirtyDaysAgo)
+// loadPriorityContext loads the fair-share tree and what the QoS, partition
+// and job size factors are normalised against
+func (pc *PriorityCalculator) loadPriorityContext() (*priorityContext, error) {
+	ctx := &priorityContext{now: time.Now(), tiers: make(map[int]int)}
+
+	var err error
+	ctx.fairShare, err = pc.loadFairShareTree()
+	if err != nil {
+		return nil, err
+	}
+
+	err = pc.db.QueryRow("SELECT COALESCE(MAX(priority_factor), 1) FROM qos").Scan(&ctx.maxQoSFactor)
+	if err != nil {
+		return nil, err
+	}
+
+	err = pc.db.QueryRow(
+		"SELECT COALESCE(SUM(cpu_cores), 0) FROM workers WHERE status != 'offline'",
+	).Scan(&ctx.clusterCPUs)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := pc.db.Query("SELECT id, COALESCE(priority_tier, 0) FROM partitions")
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	
-	usage := make(map[int]UsageData)
+
 	for rows.Next() {
-		var data UsageData
-		err := rows.Scan(&data.GroupID, &data.CPUQuota, &data.CPUHoursUsed)
-		if err != nil {
-			continue
+		var id, tier int
+		if err := rows.Scan(&id, &tier); err != nil {
+			return nil, err
+		}
+		ctx.tiers[id] = tier
+		if tier > ctx.maxTier {
+			ctx.maxTier = tier
 		}
-		usage[data.GroupID] = data
 	}
-	
-	return usage, nil
+	return ctx, rows.Err()
 }
 
-// sortJobsByPriority sorts jobs by calculated priority (descending)
+// sortJobsByPriority sorts jobs by calculated priority (descending), keeping
+// the order of jobs with equal priority. Simulated traces can queue thousands
+// of jobs, so this must not be quadratic.
 func sortJobsByPriority(jobs []JobWithPriority) {
-	// Simple bubble sort (fine for small job counts)
-	n := len(jobs)
-	for i := 0; i < n-1; i++ {
-		for j := 0; j < n-i-1; j++ {
-			if jobs[j].CalculatedPriority < jobs[j+1].CalculatedPriority {
-				jobs[j], jobs[j+1] = jobs[j+1], jobs[j]
-			}
-		}
-	}
-}
\ No newline at end of file
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].CalculatedPriority > jobs[j].CalculatedPriority
+	})
+}
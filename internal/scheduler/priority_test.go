@@ -0,0 +1,122 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samik-k21/research-compute-queue/internal/config"
+)
+
+func TestPriorityFactors(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	pc := &PriorityCalculator{maxAge: 24 * time.Hour}
+	ctx := &priorityContext{
+		now:          now,
+		fairShare:    &fairShareTree{factors: map[int]float64{1: 1, 2: 0.25}},
+		maxQoSFactor: 2,
+		tiers:        map[int]int{1: 0, 2: 2, 3: 4},
+		maxTier:      4,
+		clusterCPUs:  64,
+	}
+
+	tests := []struct {
+		name string
+		job  JobWithPriority
+		want priorityFactors
+	}{
+		{"new job",
+			JobWithPriority{UserID: 1, GroupPriority: 2, Priority: 5, SubmittedAt: now, CPUCores: 16, QoSFactor: 1, PartitionID: 2},
+			priorityFactors{base: 2.5, fairShare: 1, jobSize: 0.25, qos: 0.5, partition: 0.5}},
+		{"half the maximum age",
+			JobWithPriority{UserID: 2, GroupPriority: 1, SubmittedAt: now.Add(-12 * time.Hour), CPUCores: 32, QoSFactor: 2, PartitionID: 3},
+			priorityFactors{base: 1, age: 0.5, fairShare: 0.25, jobSize: 0.5, qos: 1, partition: 1}},
+		{"age and size are capped",
+			JobWithPriority{GroupPriority: 1, SubmittedAt: now.Add(-72 * time.Hour), CPUCores: 128, PartitionID: 1},
+			priorityFactors{base: 1, age: 1, jobSize: 1}},
+		{"submitted in the future",
+			JobWithPriority{GroupPriority: 1, SubmittedAt: now.Add(time.Hour)},
+			priorityFactors{base: 1}},
+		{"user without fair-share",
+			JobWithPriority{UserID: 99, GroupPriority: 3, SubmittedAt: now},
+			priorityFactors{base: 3}},
+	}
+	for _, tt := range tests {
+		if got := pc.factors(&tt.job, ctx); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	// Favouring small jobs turns the size factor around
+	small := &PriorityCalculator{maxAge: 24 * time.Hour, favorSmall: true}
+	job := &JobWithPriority{GroupPriority: 1, SubmittedAt: now, CPUCores: 16}
+	if got := small.factors(job, ctx).jobSize; got != 0.75 {
+		t.Errorf("favour small jobs: got job size %v, want 0.75", got)
+	}
+
+	// Without a maximum age there is no age factor
+	noAge := &PriorityCalculator{}
+	job = &JobWithPriority{GroupPriority: 1, SubmittedAt: now.Add(-12 * time.Hour)}
+	if got := noAge.factors(job, ctx).age; got != 0 {
+		t.Errorf("no maximum age: got age %v, want 0", got)
+	}
+
+	// An empty cluster without QoS or partitions zeroes those factors
+	empty := &priorityContext{now: now, fairShare: &fairShareTree{factors: map[int]float64{}}, tiers: map[int]int{}}
+	job = &JobWithPriority{GroupPriority: 1, SubmittedAt: now, CPUCores: 8, QoSFactor: 1, PartitionID: 2}
+	if got := pc.factors(job, empty); got != (priorityFactors{base: 1}) {
+		t.Errorf("empty cluster: got %+v, want only the base priority", got)
+	}
+}
+
+func TestPriorityWeights(t *testing.T) {
+	defaults := config.PriorityWeights{Age: 1000, FairShare: 10000, JobSize: 1000, QoS: 2000, Partition: 1000}
+	factors := priorityFactors{base: 2, age: 0.5, fairShare: 0.25, jobSize: 0.5, qos: 1, partition: 0}
+	maxed := priorityFactors{base: 1, age: 1, fairShare: 1, jobSize: 1, qos: 1, partition: 1}
+
+	tests := []struct {
+		name    string
+		weights config.PriorityWeights
+		factors priorityFactors
+		want    float64
+	}{
+		{"default weights", defaults, factors, 2 * (500 + 2500 + 500 + 2000)},
+		{"no weights", config.PriorityWeights{}, factors, 0},
+		{"fair-share only", config.PriorityWeights{FairShare: 10000}, factors, 2 * 2500},
+		{"age only", config.PriorityWeights{Age: 1000}, factors, 2 * 500},
+		{"partition weight on a zero factor", config.PriorityWeights{Partition: 1000}, factors, 0},
+		{"zero base priority", defaults, priorityFactors{age: 1, fairShare: 1, jobSize: 1, qos: 1, partition: 1}, 0},
+		{"all factors maxed", defaults, maxed, 15000},
+	}
+	for _, tt := range tests {
+		pc := &PriorityCalculator{weights: tt.weights}
+		if got := pc.priority(tt.factors); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPriorityRank(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	pc := &PriorityCalculator{
+		weights: config.PriorityWeights{Age: 1000, FairShare: 10000},
+		maxAge:  24 * time.Hour,
+	}
+	ctx := &priorityContext{now: now, fairShare: &fairShareTree{factors: map[int]float64{1: 0.5, 2: 1}}, tiers: map[int]int{}}
+
+	jobs := []JobWithPriority{
+		{ID: 1, UserID: 1, GroupPriority: 1, SubmittedAt: now},
+		{ID: 2, UserID: 2, GroupPriority: 1, SubmittedAt: now},
+		{ID: 3, UserID: 1, GroupPriority: 1, SubmittedAt: now.Add(-12 * time.Hour)},
+		{ID: 4, UserID: 1, GroupPriority: 1, SubmittedAt: now},
+		{ID: 5, UserID: 1, GroupPriority: 3, SubmittedAt: now},
+	}
+	pc.rank(jobs, ctx)
+
+	// Higher base priority first, then fair-share, then age; ties keep queue order
+	want := []int{5, 2, 3, 1, 4}
+	for i, job := range jobs {
+		if job.ID != want[i] {
+			t.Errorf("position %d: got job %d, want job %d", i, job.ID, want[i])
+		}
+	}
+}
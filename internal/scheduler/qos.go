@@ -0,0 +1,143 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+// qosLimits holds a QoS's running limits (0 = unlimited)
+type qosLimits struct {
+	name            string
+	maxJobsPerUser  int
+	maxCPUsPerUser  int
+	maxJobsPerGroup int
+	maxCPUsPerGroup int
+}
+
+// qosKey identifies a user's or a group's running jobs in one QoS
+type qosKey struct {
+	qosID int
+	id    int
+}
+
+// qosUsage counts running jobs and the CPU cores they hold
+type qosUsage struct {
+	jobs int
+	cpus int
+}
+
+// qosState tracks QoS limits and running usage during a cycle
+type qosState struct {
+	limits  map[int]*qosLimits
+	byUser  map[qosKey]*qosUsage
+	byGroup map[qosKey]*qosUsage
+}
+
+// usage returns the counter for key, creating it if needed
+func usage(m map[qosKey]*qosUsage, key qosKey) *qosUsage {
+	u, ok := m[key]
+	if !ok {
+		u = &qosUsage{}
+		m[key] = u
+	}
+	return u
+}
+
+// holdReason returns which of the job's QoS limits (models.PendingQoSMaxJobs
+// or models.PendingQoSMaxCPUs) keeps it from starting now and why, or "" if
+// none does
+func (qs *qosState) holdReason(job *JobWithPriority) (string, string) {
+	l, ok := qs.limits[job.QoSID]
+	if !ok {
+		return "", ""
+	}
+
+	user := usage(qs.byUser, qosKey{job.QoSID, job.UserID})
+	group := usage(qs.byGroup, qosKey{job.QoSID, job.GroupID})
+	switch {
+	case l.maxJobsPerUser > 0 && user.jobs >= l.maxJobsPerUser:
+		return models.PendingQoSMaxJobs,
+			fmt.Sprintf("user %d has the maximum of %d running jobs in QoS %s", job.UserID, l.maxJobsPerUser, l.name)
+	case l.maxCPUsPerUser > 0 && user.cpus+job.CPUCores > l.maxCPUsPerUser:
+		return models.PendingQoSMaxCPUs,
+			fmt.Sprintf("user %d would exceed %d running CPU cores in QoS %s", job.UserID, l.maxCPUsPerUser, l.name)
+	case l.maxJobsPerGroup > 0 && group.jobs >= l.maxJobsPerGroup:
+		return models.PendingQoSMaxJobs,
+			fmt.Sprintf("group %d has the maximum of %d running jobs in QoS %s", job.GroupID, l.maxJobsPerGroup, l.name)
+	case l.maxCPUsPerGroup > 0 && group.cpus+job.CPUCores > l.maxCPUsPerGroup:
+		return models.PendingQoSMaxCPUs,
+			fmt.Sprintf("group %d would exceed %d running CPU cores in QoS %s", job.GroupID, l.maxCPUsPerGroup, l.name)
+	}
+	return "", ""
+}
+
+// started counts a job started this cycle against its QoS limits
+func (qs *qosState) started(job *JobWithPriority) {
+	for _, u := range []*qosUsage{
+		usage(qs.byUser, qosKey{job.QoSID, job.UserID}),
+		usage(qs.byGroup, qosKey{job.QoSID, job.GroupID}),
+	} {
+		u.jobs++
+		u.cpus += job.CPUCores
+	}
+}
+
+// getQoSState loads every QoS's limits and what is running in it
+func (s *Scheduler) getQoSState() (*qosState, error) {
+	qs := &qosState{
+		limits:  make(map[int]*qosLimits),
+		byUser:  make(map[qosKey]*qosUsage),
+		byGroup: make(map[qosKey]*qosUsage),
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, name, COALESCE(max_jobs_per_user, 0), COALESCE(max_cpus_per_user, 0),
+		       COALESCE(max_jobs_per_group, 0), COALESCE(max_cpus_per_group, 0)
+		FROM qos
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		l := &qosLimits{}
+		if err := rows.Scan(&id, &l.name, &l.maxJobsPerUser, &l.maxCPUsPerUser,
+			&l.maxJobsPerGroup, &l.maxCPUsPerGroup); err != nil {
+			log.Printf("Error scanning QoS: %v", err)
+			continue
+		}
+		qs.limits[id] = l
+	}
+	rows.Close()
+
+	running, err := s.db.Query(`
+		SELECT qos_id, user_id, group_id, COUNT(*), SUM(cpu_cores)
+		FROM jobs
+		WHERE status = 'running' AND NOT is_array AND qos_id IS NOT NULL
+		GROUP BY qos_id, user_id, group_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer running.Close()
+
+	for running.Next() {
+		var qosID, userID, groupID, jobs, cpus int
+		if err := running.Scan(&qosID, &userID, &groupID, &jobs, &cpus); err != nil {
+			return nil, err
+		}
+		for _, u := range []*qosUsage{
+			usage(qs.byUser, qosKey{qosID, userID}),
+			usage(qs.byGroup, qosKey{qosID, groupID}),
+		} {
+			u.jobs += jobs
+			u.cpus += cpus
+		}
+	}
+
+	return qs, running.Err()
+}
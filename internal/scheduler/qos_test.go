@@ -0,0 +1,107 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+func TestQoSHoldReason(t *testing.T) {
+	qs := &qosState{
+		limits: map[int]*qosLimits{
+			1: {name: "normal", maxJobsPerUser: 2, maxCPUsPerUser: 16},
+			2: {name: "group", maxJobsPerGroup: 3, maxCPUsPerGroup: 32},
+			3: {name: "unlimited"},
+		},
+		byUser: map[qosKey]*qosUsage{
+			{1, 1}: {jobs: 1, cpus: 8},
+			{1, 2}: {jobs: 2, cpus: 4},
+			{3, 1}: {jobs: 100, cpus: 1000},
+		},
+		byGroup: map[qosKey]*qosUsage{
+			{2, 1}: {jobs: 2, cpus: 24},
+			{2, 2}: {jobs: 3, cpus: 3},
+		},
+	}
+
+	tests := []struct {
+		qosID, userID, groupID, cpu int
+		want                        string
+	}{
+		{1, 1, 1, 8, ""},
+		{1, 1, 1, 9, models.PendingQoSMaxCPUs}, // 17 cores for user 1
+		{1, 2, 1, 1, models.PendingQoSMaxJobs}, // User 2 already runs 2 jobs
+		{1, 3, 1, 16, ""},                      // Nothing running yet
+		{2, 1, 1, 8, ""},
+		{2, 1, 1, 9, models.PendingQoSMaxCPUs}, // 33 cores for group 1
+		{2, 1, 2, 1, models.PendingQoSMaxJobs}, // Group 2 already runs 3 jobs
+		{3, 1, 1, 64, ""},
+		{0, 1, 1, 64, ""}, // No QoS
+		{9, 1, 1, 64, ""}, // Unknown QoS
+	}
+	for _, tt := range tests {
+		job := &JobWithPriority{QoSID: tt.qosID, UserID: tt.userID, GroupID: tt.groupID, CPUCores: tt.cpu}
+		got, msg := qs.holdReason(job)
+		if got != tt.want {
+			t.Errorf("QoS %d, user %d, group %d, %d CPU: got %q (%s), want %q",
+				tt.qosID, tt.userID, tt.groupID, tt.cpu, got, msg, tt.want)
+		}
+		if (got == "") != (msg == "") {
+			t.Errorf("QoS %d, user %d: reason %q with message %q", tt.qosID, tt.userID, got, msg)
+		}
+	}
+}
+
+func TestQoSStarted(t *testing.T) {
+	qs := &qosState{
+		limits:  map[int]*qosLimits{1: {name: "normal", maxJobsPerUser: 2, maxCPUsPerGroup: 10}},
+		byUser:  make(map[qosKey]*qosUsage),
+		byGroup: make(map[qosKey]*qosUsage),
+	}
+
+	// Jobs started this cycle count against the limits straight away
+	job := &JobWithPriority{QoSID: 1, UserID: 1, GroupID: 1, CPUCores: 4}
+	qs.started(job)
+	if got, _ := qs.holdReason(job); got != "" {
+		t.Errorf("after one job: got %q", got)
+	}
+	qs.started(job)
+	if got, _ := qs.holdReason(job); got != models.PendingQoSMaxJobs {
+		t.Errorf("after two jobs: got %q, want %q", got, models.PendingQoSMaxJobs)
+	}
+
+	// Another user in the group is held by the group's CPU cores
+	other := &JobWithPriority{QoSID: 1, UserID: 2, GroupID: 1, CPUCores: 4}
+	if got, _ := qs.holdReason(other); got != models.PendingQoSMaxCPUs {
+		t.Errorf("other user: got %q, want %q", got, models.PendingQoSMaxCPUs)
+	}
+}
+
+func TestQoSPriorityFactor(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	pc := &PriorityCalculator{}
+	ctx := &priorityContext{now: now, fairShare: &fairShareTree{factors: map[int]float64{}}, maxQoSFactor: 4}
+
+	tests := []struct {
+		factor float64
+		want   float64
+	}{
+		{4, 1},    // The highest QoS
+		{1, 0.25}, // Normal against a QoS four times as important
+		{0, 0},
+		{8, 1}, // Capped
+	}
+	for _, tt := range tests {
+		job := &JobWithPriority{GroupPriority: 1, SubmittedAt: now, QoSFactor: tt.factor}
+		if got := pc.factors(job, ctx).qos; got != tt.want {
+			t.Errorf("factor %v: got %v, want %v", tt.factor, got, tt.want)
+		}
+	}
+
+	// Without any QoS the factor is 0 for everyone
+	ctx.maxQoSFactor = 0
+	if got := pc.factors(&JobWithPriority{QoSFactor: 1}, ctx).qos; got != 0 {
+		t.Errorf("no QoS: got %v, want 0", got)
+	}
+}
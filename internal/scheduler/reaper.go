@@ -0,0 +1,155 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+// reapDeadWorkers marks agents that stopped sending heartbeats offline and
+// requeues the jobs they were running. Local workers run inside the server
+// process and never send heartbeats, so only agents are checked.
+func (s *Scheduler) reapDeadWorkers() {
+	cutoff := time.Now().Add(-s.heartbeatTimeout)
+
+	rows, err := s.db.Query(`
+		UPDATE workers
+		SET status = 'offline'
+		WHERE is_agent AND status != 'offline' AND last_heartbeat < $1
+		RETURNING id, hostname, last_heartbeat
+	`, cutoff)
+	if err != nil {
+		log.Printf("Error checking worker heartbeats: %v", err)
+		return
+	}
+
+	type deadWorker struct {
+		id       int
+		hostname string
+		lastSeen time.Time
+	}
+	var dead []deadWorker
+	for rows.Next() {
+		var w deadWorker
+		if err := rows.Scan(&w.id, &w.hostname, &w.lastSeen); err != nil {
+			log.Printf("Error scanning worker: %v", err)
+			continue
+		}
+		dead = append(dead, w)
+	}
+	rows.Close()
+
+	for _, w := range dead {
+		log.Printf("Worker %d (%s) missed heartbeats since %s, marking offline",
+			w.id, w.hostname, w.lastSeen.Format(time.RFC3339))
+
+		jobIDs, err := s.runningJobsOnWorker(w.id)
+		if err != nil {
+			log.Printf("Error getting jobs on worker %d: %v", w.id, err)
+			continue
+		}
+
+		reason := fmt.Sprintf("%s: worker %s stopped sending heartbeats (last seen %s)",
+			models.NodeFailureReason, w.hostname, w.lastSeen.Format(time.RFC3339))
+		for _, jobID := range jobIDs {
+			s.handleNodeFailure(jobID, w.id, reason)
+		}
+	}
+}
+
+// runningJobsOnWorker returns the IDs of jobs running on a worker
+func (s *Scheduler) runningJobsOnWorker(workerID int) ([]int, error) {
+	rows, err := s.db.Query(
+		"SELECT id FROM jobs WHERE worker_id = $1 AND status = 'running'", workerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobIDs []int
+	for rows.Next() {
+		var jobID int
+		if err := rows.Scan(&jobID); err != nil {
+			return nil, err
+		}
+		jobIDs = append(jobIDs, jobID)
+	}
+	return jobIDs, nil
+}
+
+// handleNodeFailure requeues a job lost to a node failure, or fails it once
+// it has been requeued maxNodeRequeues times
+func (s *Scheduler) handleNodeFailure(jobID, workerID int, reason string) {
+	var failures int
+	err := s.db.QueryRow(`
+		UPDATE jobs SET node_failure_count = node_failure_count + 1
+		WHERE id = $1 AND status = 'running'
+		RETURNING node_failure_count
+	`, jobID).Scan(&failures)
+	if err != nil {
+		return // Finished or cancelled in the meantime
+	}
+
+	if failures > s.maxNodeRequeues {
+		s.executor.completeJob(jobID, workerID, -1,
+			fmt.Sprintf("%s; failed after %d node-failure requeues", reason, s.maxNodeRequeues))
+		return
+	}
+
+	if s.requeueJob(jobID, reason) {
+		log.Printf("Requeued job %d from worker %d (node failure %d of %d allowed)",
+			jobID, workerID, failures, s.maxNodeRequeues)
+	}
+}
+
+// requeueJob moves a running job back to pending, records the lost run in its
+// attempt history and frees its resources. The reason is kept in
+// error_message so users can see why the job restarted.
+func (s *Scheduler) requeueJob(jobID int, reason string) bool {
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Printf("Error requeueing job %d: %v", jobID, err)
+		return false
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow(
+		"SELECT id FROM jobs WHERE id = $1 AND status = 'running' FOR UPDATE", jobID,
+	).Scan(&id)
+	if err != nil {
+		return false // Finished or cancelled in the meantime
+	}
+
+	_, err = tx.Exec(insertAttemptSQL, jobID, nil, models.AttemptRequeued, reason)
+	if err != nil {
+		log.Printf("Error recording attempt of job %d: %v", jobID, err)
+		return false
+	}
+
+	_, err = tx.Exec(`
+		UPDATE jobs
+		SET status = 'pending', started_at = NULL, claimed_at = NULL, worker_id = NULL,
+		    pid = NULL, exit_code = NULL, preempted_by = NULL, error_message = $1
+		WHERE id = $2
+	`, reason, jobID)
+	if err != nil {
+		log.Printf("Error requeueing job %d: %v", jobID, err)
+		return false
+	}
+
+	// Lost runtime is not charged to the group's fair-share usage
+	if _, err := releaseAllocation(tx, jobID); err != nil {
+		log.Printf("Error releasing allocation for job %d: %v", jobID, err)
+		return false
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error requeueing job %d: %v", jobID, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Recovery policies for jobs whose process was lost in a server restart
+const (
+	RecoveryRequeue = "requeue" // Move the job back to pending
+	RecoveryFail    = "fail"    // Mark the job failed
+)
+
+// lostJobReason is written to error_message for jobs lost in a server restart
+const lostJobReason = "server restarted: job process was lost"
+
+// RecoverySummary counts what Recover did
+type RecoverySummary struct {
+	RunningJobs         int // Local jobs left on this host by replicas that stopped
+	AdoptedLocal        int // Local processes still alive, now watched again
+	Requeued            int
+	Failed              int
+	RestoredAllocations int // Running jobs whose allocation row was missing
+	StaleAllocations    int // Allocations left behind by finished jobs
+	WorkersReset        int
+}
+
+// recoveredJob is a running job taken over from another replica
+type recoveredJob struct {
+	job        JobWithPriority
+	workerID   int
+	pid        sql.NullInt64
+	startedAt  time.Time
+	outputPath string
+}
+
+// Recover reconciles the database with reality after a restart. Executor only
+// tracks jobs in memory, so without this every job running when the server
+// stopped would stay running, and hold its worker's resources, forever.
+// It runs once, when the server starts, before it serves requests. Only local
+// jobs on this host whose replica has stopped are taken over; jobs of live
+// replicas and agent jobs are left alone.
+func (s *Scheduler) Recover() (*RecoverySummary, error) {
+	summary := &RecoverySummary{}
+
+	// Announce this replica first, so the leader leaves this host's jobs to it
+	s.replicaHeartbeat()
+
+	// 1. Make allocations match the jobs that are running
+	restored, err := s.db.Exec(`
+		INSERT INTO worker_allocations (job_id, worker_id, cpu_cores, memory_gb, gpu_count)
+		SELECT id, worker_id, cpu_cores, memory_gb, gpu_count
+		FROM jobs
+		WHERE status = 'running' AND worker_id IS NOT NULL
+		ON CONFLICT (job_id) DO NOTHING
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore allocations: %w", err)
+	}
+	n, _ := restored.RowsAffected()
+	summary.RestoredAllocations = int(n)
+
+	stale, err := s.db.Exec(`
+		DELETE FROM worker_allocations a
+		USING jobs j
+		WHERE a.job_id = j.id AND j.status != 'running'
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to release stale allocations: %w", err)
+	}
+	n, _ = stale.RowsAffected()
+	summary.StaleAllocations = int(n)
+
+	// 2. Re-adopt this host's orphaned jobs that are still alive; requeue or fail the rest
+	if err := s.adoptOrphanedJobs(summary); err != nil {
+		return nil, fmt.Errorf("failed to load running jobs: %w", err)
+	}
+
+	// 3. Recompute every worker's status from its allocations
+	rows, err := s.db.Query("SELECT id FROM workers WHERE status != 'offline'")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workers: %w", err)
+	}
+	var workerIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		workerIDs = append(workerIDs, id)
+	}
+	rows.Close()
+
+	for _, id := range workerIDs {
+		if _, err := s.db.Exec(workerStatusSQL, id); err != nil {
+			return nil, fmt.Errorf("failed to reset status of worker %d: %w", id, err)
+		}
+		summary.WorkersReset++
+	}
+
+	log.Printf("Recovery: %d orphaned local jobs found, %d re-adopted, %d requeued, %d failed (policy: %s)",
+		summary.RunningJobs, summary.AdoptedLocal, summary.Requeued, summary.Failed, s.recoveryPolicy)
+	log.Printf("Recovery: %d allocations restored, %d stale allocations released, %d worker statuses reset",
+		summary.RestoredAllocations, summary.StaleAllocations, summary.WorkersReset)
+
+	return summary, nil
+}
@@ -0,0 +1,182 @@
+package scheduler
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/samik-k21/research-compute-queue/internal/runner"
+)
+
+// newReplicaID returns an ID unique to this server process. Local jobs record
+// the replica that runs them, so only that replica, or one taking over after it
+// died, watches their processes.
+func newReplicaID(hostname string) string {
+	return fmt.Sprintf("%s/%d/%d", hostname, os.Getpid(), time.Now().UnixNano())
+}
+
+// replicaHeartbeat records that this replica is alive. Every replica sends one
+// each tick, leader or not, since every replica may be running local jobs.
+func (s *Scheduler) replicaHeartbeat() {
+	_, err := s.db.Exec(`
+		INSERT INTO scheduler_replicas (id, hostname, pid, started_at, last_seen)
+		VALUES ($1, $2, $3, NOW(), NOW())
+		ON CONFLICT (id) DO UPDATE SET last_seen = NOW()
+	`, s.replicaID, s.hostname, os.Getpid())
+	if err != nil {
+		log.Printf("Error recording replica heartbeat: %v", err)
+	}
+}
+
+// orphanedJob is a local job whose replica has stopped
+type orphanedJob struct {
+	recoveredJob
+	owner sql.NullString // Replica that started it (NULL if unknown)
+}
+
+// getOrphanedJobs loads local jobs running on this host whose replica is no
+// longer alive: its process is gone, or it has not sent a heartbeat within
+// the heartbeat timeout. Jobs of live replicas are never touched.
+func (s *Scheduler) getOrphanedJobs() ([]orphanedJob, error) {
+	rows, err := s.db.Query(`
+		SELECT j.id, j.user_id, j.group_id, j.script, j.cpu_cores, j.memory_gb, j.gpu_count,
+		       COALESCE(j.estimated_hours, 0), COALESCE(j.started_at, NOW()),
+		       COALESCE(j.worker_id, 0), j.pid, COALESCE(j.output_path, ''),
+		       j.replica_id, r.pid, r.last_seen < NOW() - $3 * INTERVAL '1 second'
+		FROM jobs j
+		JOIN scheduler_replicas r ON r.id = j.replica_id
+		WHERE j.status = 'running' AND NOT j.is_array
+		  AND r.hostname = $1 AND r.id != $2
+		ORDER BY j.id
+	`, s.hostname, s.replicaID, s.heartbeatTimeout.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []orphanedJob
+	for rows.Next() {
+		var oj orphanedJob
+		var ownerPID int
+		var silent bool
+		err := rows.Scan(&oj.job.ID, &oj.job.UserID, &oj.job.GroupID, &oj.job.Script,
+			&oj.job.CPUCores, &oj.job.MemoryGB, &oj.job.GPUCount, &oj.job.EstimatedHours,
+			&oj.startedAt, &oj.workerID, &oj.pid, &oj.outputPath, &oj.owner, &ownerPID, &silent)
+		if err != nil {
+			return nil, err
+		}
+		// On the same host the owner's process can be checked directly
+		if runner.ProcessExists(ownerPID) && !silent {
+			continue
+		}
+		jobs = append(jobs, oj)
+	}
+	return jobs, rows.Err()
+}
+
+// claimJob makes this replica the owner of a job, unless another replica
+// claimed it first
+func (s *Scheduler) claimJob(jobID int, owner sql.NullString) bool {
+	result, err := s.db.Exec(`
+		UPDATE jobs SET replica_id = $1
+		WHERE id = $2 AND status = 'running' AND replica_id IS NOT DISTINCT FROM $3
+	`, s.replicaID, jobID, owner)
+	if err != nil {
+		log.Printf("Error claiming job %d: %v", jobID, err)
+		return false
+	}
+	n, _ := result.RowsAffected()
+	return n == 1
+}
+
+// adoptOrphanedJobs takes over local jobs on this host left by a replica that
+// stopped. Jobs whose process is still alive are watched again; the rest were
+// lost and are requeued or failed per RECOVERY_POLICY.
+func (s *Scheduler) adoptOrphanedJobs(summary *RecoverySummary) error {
+	jobs, err := s.getOrphanedJobs()
+	if err != nil {
+		return err
+	}
+
+	for i := range jobs {
+		oj := &jobs[i]
+		if !s.claimJob(oj.job.ID, oj.owner) {
+			continue
+		}
+		summary.RunningJobs++
+
+		switch {
+		case s.executor.mode == ExecutorLocal && oj.pid.Valid && runner.JobProcessAlive(int(oj.pid.Int64), oj.job.ID):
+			s.executor.AdoptJob(&oj.job, oj.workerID, int(oj.pid.Int64), oj.startedAt, oj.outputPath)
+			log.Printf("Re-adopted job %d (pid %d) from replica %s", oj.job.ID, oj.pid.Int64, oj.owner.String)
+			summary.AdoptedLocal++
+		default:
+			s.handleLostJob(oj.job.ID, oj.workerID, summary)
+		}
+	}
+	return nil
+}
+
+// handleLostJob requeues or fails a local job whose process was lost
+func (s *Scheduler) handleLostJob(jobID, workerID int, summary *RecoverySummary) {
+	if s.recoveryPolicy == RecoveryFail {
+		s.executor.completeJob(jobID, workerID, -1, lostJobReason)
+		summary.Failed++
+		return
+	}
+	if s.requeueJob(jobID, lostJobReason) {
+		summary.Requeued++
+	}
+}
+
+// reapDeadReplicas handles local jobs of replicas that stopped on hosts where
+// no replica runs anymore, so nothing can check on their processes. A replica
+// started later on the same host adopts them instead, as long as they are
+// still running. Jobs that record no replica at all are handled the same way.
+func (s *Scheduler) reapDeadReplicas() {
+	rows, err := s.db.Query(`
+		SELECT j.id, COALESCE(j.worker_id, 0)
+		FROM jobs j
+		LEFT JOIN workers w ON w.id = j.worker_id
+		LEFT JOIN scheduler_replicas r ON r.id = j.replica_id
+		WHERE j.status = 'running' AND NOT j.is_array AND NOT COALESCE(w.is_agent, FALSE)
+		  AND NOT EXISTS (
+		      SELECT 1 FROM scheduler_replicas live
+		      WHERE live.hostname = r.hostname AND live.last_seen >= NOW() - $1 * INTERVAL '1 second'
+		  )
+	`, s.heartbeatTimeout.Seconds())
+	if err != nil {
+		log.Printf("Error checking for jobs of stopped replicas: %v", err)
+		return
+	}
+
+	type lostJob struct{ id, workerID int }
+	var lost []lostJob
+	for rows.Next() {
+		var j lostJob
+		if err := rows.Scan(&j.id, &j.workerID); err != nil {
+			log.Printf("Error scanning job: %v", err)
+			continue
+		}
+		lost = append(lost, j)
+	}
+	rows.Close()
+
+	summary := &RecoverySummary{}
+	for _, j := range lost {
+		log.Printf("Job %d was running on a replica that stopped, and no replica runs on its host", j.id)
+		s.handleLostJob(j.id, j.workerID, summary)
+	}
+
+	// Forget replicas that have been gone a while and left no jobs behind
+	_, err = s.db.Exec(`
+		DELETE FROM scheduler_replicas r
+		WHERE r.last_seen < NOW() - INTERVAL '1 day'
+		  AND NOT EXISTS (SELECT 1 FROM jobs j WHERE j.replica_id = r.id AND j.status = 'running')
+	`)
+	if err != nil {
+		log.Printf("Error removing stopped replicas: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package scheduler
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/samik-k21/research-compute-queue/internal/database"
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+// maxRetryDelay caps exponential backoff
+const maxRetryDelay = 24 * time.Hour
+
+// insertAttemptSQL records the run a job is finishing, taking the worker and
+// start time from the job row; the run ends now, by the database clock. An
+// empty message keeps the job's error_message.
+const insertAttemptSQL = `
+	INSERT INTO job_attempts (job_id, attempt, worker_id, started_at, ended_at, exit_code, status, error_message)
+	SELECT id, attempt, worker_id, started_at, NOW(), $2::integer, $3::varchar,
+	       COALESCE(NULLIF($4::text, ''), error_message)
+	FROM jobs WHERE id = $1
+`
+
+// recordAttempt adds the job's finished run to its attempt history
+func (e *Executor) recordAttempt(jobID int, exitCode int, status, errorMessage string) {
+	if _, err := e.db.Exec(insertAttemptSQL, jobID, exitCode, status, errorMessage); err != nil {
+		log.Printf("Error recording attempt of job %d: %v", jobID, err)
+	}
+}
+
+// retryJob requeues a failed job if its retry policy allows it. The attempt is
+// recorded, the job goes back to pending with eligible_at set by the backoff,
+// and its allocation is freed, all in one transaction. Returns false if the
+// job should fail instead (or is no longer running, e.g. it was cancelled).
+func (e *Executor) retryJob(jobID, exitCode int, errorMessage string) bool {
+	tx, err := e.db.Begin()
+	if err != nil {
+		log.Printf("Error retrying job %d: %v", jobID, err)
+		return false
+	}
+	defer tx.Rollback()
+
+	var attempt, maxRetries, retryCount, delaySeconds int
+	var backoff string
+	var exitCodes pq.Int64Array
+	var onNodeFailure, onTimeout bool
+	err = tx.QueryRow(`
+		SELECT attempt, max_retries, retry_count, retry_backoff, retry_delay_seconds,
+		       retry_exit_codes, retry_on_node_failure, retry_on_timeout
+		FROM jobs
+		WHERE id = $1 AND status = 'running'
+		FOR UPDATE
+	`, jobID).Scan(&attempt, &maxRetries, &retryCount, &backoff, &delaySeconds, &exitCodes, &onNodeFailure, &onTimeout)
+	if err == sql.ErrNoRows {
+		return false
+	}
+	if err != nil {
+		log.Printf("Error loading retry policy of job %d: %v", jobID, err)
+		return false
+	}
+
+	if retryCount >= maxRetries || !retryable(exitCode, errorMessage, exitCodes, onNodeFailure, onTimeout) {
+		return false
+	}
+
+	delay := retryDelay(backoff, time.Duration(delaySeconds)*time.Second, retryCount)
+	reason := errorMessage
+	if reason == "" {
+		reason = fmt.Sprintf("process exited with code %d", exitCode)
+	}
+	message := fmt.Sprintf("attempt %d failed (%s); retry %d of %d in %v",
+		attempt, reason, retryCount+1, maxRetries, delay)
+
+	if _, err := tx.Exec(insertAttemptSQL, jobID, exitCode, models.StatusFailed, errorMessage); err != nil {
+		log.Printf("Error recording attempt of job %d: %v", jobID, err)
+		return false
+	}
+
+	_, err = tx.Exec(`
+		UPDATE jobs
+		SET status = 'pending', retry_count = retry_count + 1,
+		    eligible_at = NOW() + $1 * INTERVAL '1 second',
+		    started_at = NULL, claimed_at = NULL, worker_id = NULL, pid = NULL,
+		    exit_code = $2, error_message = $3
+		WHERE id = $4
+	`, delay.Seconds(), exitCode, message, jobID)
+	if err != nil {
+		log.Printf("Error requeueing job %d for retry: %v", jobID, err)
+		return false
+	}
+
+	// Free the allocation before commit so a new run can never collide with it
+	if _, err := releaseAllocation(tx, jobID); err != nil {
+		log.Printf("Error releasing allocation for job %d: %v", jobID, err)
+		return false
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error retrying job %d: %v", jobID, err)
+		return false
+	}
+
+	// The failed attempt still used the resources
+	e.logUsage(jobID)
+	database.Notify(e.db, database.EventJobCompleted, jobID)
+
+	log.Printf("Job %d attempt %d failed, retrying in %v (retry %d of %d)",
+		jobID, attempt, delay, retryCount+1, maxRetries)
+	return true
+}
+
+// retryable reports whether a failure matches the retry policy. Walltime kills
+// are only retried when asked for. Otherwise, with no exit codes listed and
+// node failures not singled out, every failure is retried.
+func retryable(exitCode int, errorMessage string, exitCodes []int64, onNodeFailure, onTimeout bool) bool {
+	if strings.HasPrefix(errorMessage, models.TimeoutReason) {
+		return onTimeout
+	}
+	if len(exitCodes) == 0 && !onNodeFailure {
+		return true
+	}
+
+	if onNodeFailure && strings.HasPrefix(errorMessage, models.NodeFailureReason) {
+		return true
+	}
+	for _, code := range exitCodes {
+		if int64(exitCode) == code {
+			return true
+		}
+	}
+	return false
+}
+
+// retryDelay returns the wait before the next retry. Exponential backoff
+// doubles the base delay for every retry already made.
+func retryDelay(backoff string, base time.Duration, retryCount int) time.Duration {
+	if backoff != models.RetryExponential {
+		return base
+	}
+
+	delay := base
+	for i := 0; i < retryCount && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
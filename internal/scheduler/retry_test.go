@@ -0,0 +1,76 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+func TestRetryable(t *testing.T) {
+	nodeFailure := models.NodeFailureReason + ": worker node1 stopped sending heartbeats"
+	timeout := models.TimeoutReason + ": exceeded walltime of 1h0m0s"
+
+	tests := []struct {
+		exitCode      int
+		message       string
+		exitCodes     []int64
+		onNodeFailure bool
+		onTimeout     bool
+		want          bool
+	}{
+		// No policy: every failure but a timeout
+		{1, "", nil, false, false, true},
+		{-1, nodeFailure, nil, false, false, true},
+		{-1, timeout, nil, false, false, false},
+		{-1, timeout, nil, false, true, true},
+
+		// Listed exit codes only
+		{75, "", []int64{75, 143}, false, false, true},
+		{1, "", []int64{75, 143}, false, false, false},
+		{-1, nodeFailure, []int64{75}, false, false, false},
+		{143, timeout, []int64{143}, false, false, false},
+		{143, timeout, []int64{143}, false, true, true},
+
+		// Node failures only
+		{-1, nodeFailure, nil, true, false, true},
+		{1, "", nil, true, false, false},
+		{-1, timeout, nil, true, false, false},
+
+		// Both
+		{75, "", []int64{75}, true, false, true},
+		{-1, nodeFailure, []int64{75}, true, false, true},
+		{2, "", []int64{75}, true, false, false},
+	}
+	for _, tt := range tests {
+		got := retryable(tt.exitCode, tt.message, tt.exitCodes, tt.onNodeFailure, tt.onTimeout)
+		if got != tt.want {
+			t.Errorf("retryable(%d, %q, %v, %t, %t) = %t, want %t",
+				tt.exitCode, tt.message, tt.exitCodes, tt.onNodeFailure, tt.onTimeout, got, tt.want)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		backoff    string
+		base       time.Duration
+		retryCount int
+		want       time.Duration
+	}{
+		{models.RetryFixed, time.Minute, 0, time.Minute},
+		{models.RetryFixed, time.Minute, 5, time.Minute},
+		{"", 30 * time.Second, 3, 30 * time.Second},
+		{models.RetryExponential, time.Minute, 0, time.Minute},
+		{models.RetryExponential, time.Minute, 1, 2 * time.Minute},
+		{models.RetryExponential, time.Minute, 4, 16 * time.Minute},
+		{models.RetryExponential, time.Hour, 5, maxRetryDelay}, // 32h, capped
+		{models.RetryExponential, time.Minute, 1000, maxRetryDelay},
+		{models.RetryExponential, 0, 3, 0},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(tt.backoff, tt.base, tt.retryCount); got != tt.want {
+			t.Errorf("retryDelay(%q, %v, %d) = %v, want %v", tt.backoff, tt.base, tt.retryCount, got, tt.want)
+		}
+	}
+}
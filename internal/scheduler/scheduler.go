@@ -2,22 +2,42 @@ package scheduler
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"os"
+	"sync/atomic"
 	"time"
 
+	"github.com/samik-k21/research-compute-queue/internal/config"
 	"github.com/samik-k21/research-compute-queue/internal/database"
+	"github.com/samik-k21/research-compute-queue/internal/models"
 )
 
 // Scheduler manages job scheduling and execution
 type Scheduler struct {
-	db              *database.DB
-	interval        time.Duration
-	maxConcurrent   int
-	priorityCalc    *PriorityCalculator
-	resourceMatcher *ResourceMatcher
-	executor        *Executor
-	ctx             context.Context
-	cancel          context.CancelFunc
+	db               *database.DB
+	interval         time.Duration
+	maxConcurrent    int
+	walltimeGrace    float64
+	backfill         bool
+	preemption       bool
+	heartbeatTimeout time.Duration
+	maxNodeRequeues  int
+	recoveryPolicy   string
+	priorityCalc     *PriorityCalculator
+	resourceMatcher  *ResourceMatcher
+	executor         *Executor
+	leader           *leaderLock
+	isLeader         atomic.Bool // Read by the forecast goroutine
+	replicaID        string      // This server process, recorded on the local jobs it runs
+	hostname         string
+	databaseURL      string
+	events           bool          // Wake on LISTEN/NOTIFY events as well as the ticker
+	debounce         time.Duration // How long to gather events before running a cycle
+	forecastInterval time.Duration // How often start times of pending jobs are forecast
+	wake             chan struct{}
+	ctx              context.Context
+	cancel           context.CancelFunc
 }
 
 // JobWithPriority holds job info plus calculated priority
@@ -34,49 +54,108 @@ type JobWithPriority struct {
 	EstimatedHours     float64
 	GroupPriority      int
 	CalculatedPriority float64
+	ArrayJobID         int // Parent array (0 = not an array task)
+	ArrayIndex         int
+	ArrayThrottle      int // Max tasks of the array running at once (0 = unlimited)
+	Preemptible        bool
+	PartitionID        int     // 0 = no partition: any worker, no partition limits
+	QoSID              int     // 0 = no QoS: no QoS limits
+	QoSFactor          float64 // Multiplies the priority
+	CanPreempt         bool    // May preempt lower-priority preemptible jobs
 }
 
 // Worker holds worker information
 type Worker struct {
-	ID       int
-	Hostname string
-	CPUCores int
-	MemoryGB int
-	GPUCount int
-	Status   string
+	ID                int
+	Hostname          string
+	CPUCores          int
+	MemoryGB          int
+	GPUCount          int
+	Status            string
+	IsAgent           bool // Runs jobs through a remote worker agent instead of the local executor
+	AllocatedCPUCores int  // Resources held by jobs already running on the worker
+	AllocatedMemoryGB int
+	AllocatedGPUs     int
 }
 
 // NewScheduler creates a new scheduler instance
-func NewScheduler(db *database.DB, intervalSeconds int, maxConcurrent int) *Scheduler {
+func NewScheduler(db *database.DB, cfg *config.Config) (*Scheduler, error) {
+	strategy, err := NewPlacementStrategy(cfg.PlacementStrategy)
+	if err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
+	hostname, _ := os.Hostname()
+	replicaID := newReplicaID(hostname)
 
 	return &Scheduler{
-		db:              db,
-		interval:        time.Duration(intervalSeconds) * time.Second,
-		maxConcurrent:   maxConcurrent,
-		priorityCalc:    NewPriorityCalculator(db),
-		resourceMatcher: NewResourceMatcher(db),
-		executor:        NewExecutor(db),
-		ctx:             ctx,
-		cancel:          cancel,
-	}
+		db:               db,
+		interval:         time.Duration(cfg.SchedulerIntervalSecs) * time.Second,
+		maxConcurrent:    cfg.MaxConcurrentJobs,
+		walltimeGrace:    cfg.WalltimeGraceFactor,
+		backfill:         cfg.BackfillEnabled,
+		preemption:       cfg.PreemptionEnabled,
+		heartbeatTimeout: time.Duration(cfg.HeartbeatTimeoutSecs) * time.Second,
+		maxNodeRequeues:  cfg.MaxNodeFailureRequeues,
+		recoveryPolicy:   cfg.RecoveryPolicy,
+		priorityCalc:     NewPriorityCalculator(db, cfg),
+		resourceMatcher:  NewResourceMatcher(db, strategy),
+		executor:         NewExecutor(db, cfg, replicaID),
+		leader:           &leaderLock{db: db},
+		replicaID:        replicaID,
+		hostname:         hostname,
+		databaseURL:      cfg.DatabaseURL,
+		events:           cfg.SchedulerEventsEnabled,
+		debounce:         time.Duration(cfg.SchedulerDebounceMs) * time.Millisecond,
+		forecastInterval: time.Duration(cfg.ForecastIntervalSecs) * time.Second,
+		wake:             make(chan struct{}, 1),
+		ctx:              ctx,
+		cancel:           cancel,
+	}, nil
 }
 
-// Start begins the scheduling loop
+// Start begins the scheduling loop. Cycles only run while this replica is the
+// scheduling leader; otherwise each tick tries to take over. Besides the
+// ticker, job submissions, completions and workers coming online trigger a
+// cycle shortly after they happen.
 func (s *Scheduler) Start() {
 	log.Println("Scheduler starting...")
+	defer s.leader.release()
+
+	if s.events {
+		go s.listen()
+	}
+	go s.runForecasts()
 
 	// Run immediately on start
-	s.runSchedulingCycle()
+	s.tendLocalJobs()
+	if s.TryBecomeLeader() {
+		s.runSchedulingCycle()
+	}
 
-	// Then run on interval
+	// Then run on interval, and on events after a short debounce so a burst of
+	// submissions is handled by one cycle
 	ticker := time.NewTicker(s.interval)
 	defer ticker.Stop()
+	var debounce <-chan time.Time
 
 	for {
 		select {
 		case <-ticker.C:
-			s.runSchedulingCycle()
+			s.tendLocalJobs()
+			if s.TryBecomeLeader() {
+				s.runSchedulingCycle()
+			}
+		case <-s.wake:
+			if debounce == nil {
+				debounce = time.After(s.debounce)
+			}
+		case <-debounce:
+			debounce = nil
+			if s.TryBecomeLeader() {
+				s.runSchedulingCycle()
+			}
 		case <-s.ctx.Done():
 			log.Println("Scheduler stopping...")
 			return
@@ -84,17 +163,72 @@ func (s *Scheduler) Start() {
 	}
 }
 
+// tendLocalJobs is what every replica does each tick, leader or not: report
+// that it is alive, take over local jobs on its host whose replica stopped, and
+// stop its jobs that were cancelled through other replicas
+func (s *Scheduler) tendLocalJobs() {
+	s.replicaHeartbeat()
+	if err := s.adoptOrphanedJobs(&RecoverySummary{}); err != nil {
+		log.Printf("Error checking for orphaned local jobs: %v", err)
+	}
+	s.stopCancelledJobs()
+}
+
 // Stop gracefully stops the scheduler
 func (s *Scheduler) Stop() {
 	log.Println("Stopping scheduler...")
 	s.cancel()
 }
 
+// CancelJob stops a running job. Local processes are signalled directly;
+// agents learn about the cancellation from their next heartbeat.
+func (s *Scheduler) CancelJob(jobID int, reason string) bool {
+	if s.executor.CancelJob(jobID, reason) {
+		return true
+	}
+	s.cancelUnclaimedJob(jobID)
+	return false
+}
+
 // runSchedulingCycle executes one scheduling cycle
 func (s *Scheduler) runSchedulingCycle() {
 	log.Println("===== Running scheduling cycle =====")
 
-	// 1. Get pending jobs
+	// 0. Requeue jobs from workers that stopped sending heartbeats and from
+	// replicas that stopped with no replica left on their host, stop jobs
+	// cancelled through other replicas, update job array statuses from their
+	// tasks, and cancel jobs whose parents finished in a state that fails their
+	// dependency
+	s.reapDeadWorkers()
+	s.reapDeadReplicas()
+	s.stopCancelledJobs()
+	s.syncArrayStatuses()
+	s.cancelUnsatisfiableJobs()
+
+	// Every pending job evaluated this cycle gets a pending reason; jobs that
+	// start have theirs cleared
+	reasons := make(map[int]pendingReason)
+	defer s.recordPendingReasons(reasons)
+	holdAll := func(jobs []JobWithPriority, code, detail string) {
+		for _, job := range jobs {
+			if _, ok := reasons[job.ID]; !ok {
+				reasons[job.ID] = pendingReason{code, detail}
+			}
+		}
+	}
+
+	// Jobs waiting on a dependency or a retry backoff are not scheduled yet
+	states, err := s.getPendingStates()
+	if err != nil {
+		log.Printf("Error getting pending job states: %v", err)
+	}
+	for id, state := range states {
+		if state.waiting {
+			reasons[id] = state.reason
+		}
+	}
+
+	// 1. Get pending jobs whose dependencies are satisfied and whose retry backoff has passed
 	pendingJobs, err := s.getPendingJobs()
 	if err != nil {
 		log.Printf("Error getting pending jobs: %v", err)
@@ -108,26 +242,47 @@ func (s *Scheduler) runSchedulingCycle() {
 
 	log.Printf("Found %d pending jobs", len(pendingJobs))
 
-	// 2. Calculate priorities for all jobs
+	// 2. Calculate priorities for all jobs, then order partitions by priority tier
 	jobsWithPriority, err := s.priorityCalc.CalculatePriorities(pendingJobs)
 	if err != nil {
 		log.Printf("Error calculating priorities: %v", err)
 		return
 	}
 
-	// 3. Get available workers
-	workers, err := s.getAvailableWorkers()
+	partitions, err := s.getPartitions()
+	if err != nil {
+		log.Printf("Error getting partitions: %v", err)
+		return
+	}
+	sortJobsByPartitionTier(jobsWithPriority, partitions)
+
+	qos, err := s.getQoSState()
+	if err != nil {
+		log.Printf("Error getting QoS limits: %v", err)
+		return
+	}
+
+	limits, err := s.getLimitState()
+	if err != nil {
+		log.Printf("Error getting user and group limits: %v", err)
+		return
+	}
+
+	// 3. Get online workers with their current allocations
+	workers, err := s.getOnlineWorkers()
 	if err != nil {
 		log.Printf("Error getting workers: %v", err)
 		return
 	}
 
 	if len(workers) == 0 {
-		log.Println("No available workers")
+		log.Println("No online workers")
+		holdAll(jobsWithPriority, models.PendingResources, "no online workers")
 		return
 	}
 
-	log.Printf("Found %d available workers", len(workers))
+	log.Printf("Found %d online workers (placement strategy: %s, backfill: %t)",
+		len(workers), s.resourceMatcher.Strategy().Name(), s.backfill)
 
 	// 4. Check how many jobs are currently running
 	runningCount, err := s.getRunningJobCount()
@@ -137,60 +292,116 @@ func (s *Scheduler) runSchedulingCycle() {
 	}
 
 	slotsAvailable := s.maxConcurrent - runningCount
+	concurrencyDetail := fmt.Sprintf("the maximum of %d jobs are running", s.maxConcurrent)
 	if slotsAvailable <= 0 {
 		log.Printf("Max concurrent jobs reached (%d/%d)", runningCount, s.maxConcurrent)
+		holdAll(jobsWithPriority, models.PendingConcurrency, concurrencyDetail)
 		return
 	}
 
 	log.Printf("Can schedule up to %d jobs (%d running, %d max)", slotsAvailable, runningCount, s.maxConcurrent)
 
-	// 5. Try to schedule jobs
-	scheduled := 0
-	for _, job := range jobsWithPriority {
-		if scheduled >= slotsAvailable {
-			break
-		}
-
-		// Find a suitable worker
-		worker, err := s.resourceMatcher.FindWorkerForJob(&job, workers)
-		if err != nil || worker == nil {
-			log.Printf("No suitable worker for job %d (needs %d CPU, %d GB RAM, %d GPU)",
-				job.ID, job.CPUCores, job.MemoryGB, job.GPUCount)
-			continue
+	// 5. Load running jobs so backfill knows when resources free up
+	var planner *backfillPlanner
+	if s.backfill {
+		active, err := s.getActiveJobs()
+		if err != nil {
+			log.Printf("Error getting running jobs: %v", err)
+			return
 		}
+		planner = newBackfillPlanner(time.Now(), s.walltimeGrace, active)
+	}
 
-		// Assign and start job
-		err = s.executor.StartJob(&job, worker)
+	// Running preemptible jobs can be stopped for blocked higher-priority jobs
+	var preemption *preemptionPlanner
+	if s.preemption {
+		preemption, err = s.newPreemptionPlanner()
 		if err != nil {
-			log.Printf("Error starting job %d: %v", job.ID, err)
-			continue
+			log.Printf("Error loading preemptible jobs: %v", err)
+			return
 		}
+	}
+
+	// 6. Try to schedule jobs in priority order
+	arrayRunning, err := s.getRunningArrayTasks()
+	if err != nil {
+		log.Printf("Error counting running array tasks: %v", err)
+		return
+	}
 
-		log.Printf("✓ Scheduled job %d on worker %s (priority: %.2f)",
-			job.ID, worker.Hostname, job.CalculatedPriority)
-		scheduled++
+	pass := &queuePass{
+		matcher:       s.resourceMatcher,
+		workers:       workers,
+		maxConcurrent: s.maxConcurrent,
+		slots:         slotsAvailable,
+		partitions:    partitions,
+		qos:           qos,
+		limits:        limits,
+		arrayRunning:  arrayRunning,
+		planner:       planner,
+		reasons:       reasons,
+		logf:          log.Printf,
+		start: func(job *JobWithPriority, w *Worker) bool {
+			if err := s.executor.StartJob(job, w); err != nil {
+				log.Printf("Error starting job %d: %v", job.ID, err)
+				return false
+			}
+			return true
+		},
+	}
 
-		// Mark worker as busy (remove from available list)
-		s.markWorkerBusy(worker.ID)
-		workers = removeWorker(workers, worker.ID)
+	// Make room by stopping lower-priority preemptible jobs. Until they stop,
+	// what the job needs on their worker is held for it.
+	if preemption != nil {
+		pass.blocked = func(job *JobWithPriority, member func(*Worker) bool) bool {
+			if preemption.holdWaiting(job, workers) {
+				log.Printf("Job %d is waiting for preempted jobs to stop", job.ID)
+				reasons[job.ID] = pendingReason{models.PendingPreemption, "waiting for preempted jobs to stop"}
+				return true
+			}
+			if w, victims := preemption.victimsFor(job, workers, member); w != nil && s.preempt(preemption, job, w, victims) {
+				log.Printf("Preempting %d job(s) on worker %s for job %d (priority: %.2f)",
+					len(victims), w.Hostname, job.ID, job.CalculatedPriority)
+				reasons[job.ID] = pendingReason{models.PendingPreemption,
+					fmt.Sprintf("stopping %d job(s) on worker %s", len(victims), w.Hostname)}
+				return true
+			}
+			return false
+		}
 	}
 
+	scheduled := pass.run(jobsWithPriority)
+
 	log.Printf("Scheduled %d jobs in this cycle", scheduled)
 	log.Println("====================================")
 }
 
-// getPendingJobs retrieves all pending jobs from database
+// pendingJobsSQL selects pending jobs with what the scheduler needs to rank and place them
+const pendingJobsSQL = `
+	SELECT j.id, j.user_id, j.group_id, j.script, j.cpu_cores, j.memory_gb,
+	       j.gpu_count, j.priority, j.submitted_at, 
+	       COALESCE(j.estimated_hours, 0) as estimated_hours,
+	       g.priority as group_priority,
+	       COALESCE(j.array_job_id, 0), COALESCE(j.array_index, 0),
+	       COALESCE(ap.array_throttle, 0), j.preemptible, COALESCE(j.partition_id, 0),
+	       COALESCE(j.qos_id, 0), COALESCE(q.priority_factor, 1), COALESCE(q.can_preempt, TRUE)
+	FROM jobs j
+	JOIN groups g ON j.group_id = g.id
+	LEFT JOIN jobs ap ON ap.id = j.array_job_id
+	LEFT JOIN qos q ON q.id = j.qos_id
+	WHERE j.status = 'pending' AND NOT j.is_array`
+
+// getPendingJobs retrieves pending jobs whose dependencies are satisfied
 func (s *Scheduler) getPendingJobs() ([]JobWithPriority, error) {
-	rows, err := s.db.Query(`
-		SELECT j.id, j.user_id, j.group_id, j.script, j.cpu_cores, j.memory_gb,
-		       j.gpu_count, j.priority, j.submitted_at, 
-		       COALESCE(j.estimated_hours, 0) as estimated_hours,
-		       g.priority as group_priority
-		FROM jobs j
-		JOIN groups g ON j.group_id = g.id
-		WHERE j.status = 'pending'
+	return s.queryPendingJobs(pendingJobsSQL + ` AND NOT ` + unmetDependenciesSQL + `
+		  AND (j.eligible_at IS NULL OR j.eligible_at <= NOW())
 		ORDER BY j.submitted_at ASC
 	`)
+}
+
+// queryPendingJobs runs a pendingJobsSQL query
+func (s *Scheduler) queryPendingJobs(query string, args ...interface{}) ([]JobWithPriority, error) {
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -203,6 +414,8 @@ func (s *Scheduler) getPendingJobs() ([]JobWithPriority, error) {
 			&job.ID, &job.UserID, &job.GroupID, &job.Script, &job.CPUCores,
 			&job.MemoryGB, &job.GPUCount, &job.Priority, &job.SubmittedAt,
 			&job.EstimatedHours, &job.GroupPriority,
+			&job.ArrayJobID, &job.ArrayIndex, &job.ArrayThrottle, &job.Preemptible, &job.PartitionID,
+			&job.QoSID, &job.QoSFactor, &job.CanPreempt,
 		)
 		if err != nil {
 			log.Printf("Error scanning job: %v", err)
@@ -214,13 +427,18 @@ func (s *Scheduler) getPendingJobs() ([]JobWithPriority, error) {
 	return jobs, nil
 }
 
-// getAvailableWorkers retrieves idle workers
-func (s *Scheduler) getAvailableWorkers() ([]Worker, error) {
+// getOnlineWorkers retrieves all online workers and their current allocations.
+// Full workers are included because backfill needs them to plan reservations.
+func (s *Scheduler) getOnlineWorkers() ([]Worker, error) {
 	rows, err := s.db.Query(`
-		SELECT id, hostname, cpu_cores, memory_gb, gpu_count, status
-		FROM workers
-		WHERE status = 'idle'
-		ORDER BY cpu_cores DESC
+		SELECT w.id, w.hostname, w.cpu_cores, w.memory_gb, w.gpu_count, w.status, w.is_agent,
+		       COALESCE(SUM(a.cpu_cores), 0), COALESCE(SUM(a.memory_gb), 0),
+		       COALESCE(SUM(a.gpu_count), 0)
+		FROM workers w
+		LEFT JOIN worker_allocations a ON a.worker_id = w.id
+		WHERE w.status != 'offline'
+		GROUP BY w.id
+		ORDER BY w.id
 	`)
 	if err != nil {
 		return nil, err
@@ -230,7 +448,8 @@ func (s *Scheduler) getAvailableWorkers() ([]Worker, error) {
 	var workers []Worker
 	for rows.Next() {
 		var w Worker
-		err := rows.Scan(&w.ID, &w.Hostname, &w.CPUCores, &w.MemoryGB, &w.GPUCount, &w.Status)
+		err := rows.Scan(&w.ID, &w.Hostname, &w.CPUCores, &w.MemoryGB, &w.GPUCount, &w.Status, &w.IsAgent,
+			&w.AllocatedCPUCores, &w.AllocatedMemoryGB, &w.AllocatedGPUs)
 		if err != nil {
 			log.Printf("Error scanning worker: %v", err)
 			continue
@@ -244,22 +463,36 @@ func (s *Scheduler) getAvailableWorkers() ([]Worker, error) {
 // getRunningJobCount returns the number of currently running jobs
 func (s *Scheduler) getRunningJobCount() (int, error) {
 	var count int
-	err := s.db.QueryRow("SELECT COUNT(*) FROM jobs WHERE status = 'running'").Scan(&count)
+	err := s.db.QueryRow("SELECT COUNT(*) FROM jobs WHERE status = 'running' AND NOT is_array").Scan(&count)
 	return count, err
 }
 
-// markWorkerBusy updates worker status to busy
-func (s *Scheduler) markWorkerBusy(workerID int) error {
-	_, err := s.db.Exec("UPDATE workers SET status = 'busy' WHERE id = $1", workerID)
-	return err
-}
+// getActiveJobs retrieves running jobs and the resources they hold
+func (s *Scheduler) getActiveJobs() ([]ActiveJob, error) {
+	rows, err := s.db.Query(`
+		SELECT j.id, a.worker_id, a.cpu_cores, a.memory_gb, a.gpu_count,
+		       j.started_at, COALESCE(j.estimated_hours, 0), j.user_id, j.group_id,
+		       COALESCE(j.qos_id, 0), COALESCE(j.partition_id, 0), COALESCE(j.array_job_id, 0)
+		FROM worker_allocations a
+		JOIN jobs j ON j.id = a.job_id
+		WHERE j.started_at IS NOT NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-// removeWorker removes a worker from the list
-func removeWorker(workers []Worker, workerID int) []Worker {
-	for i, w := range workers {
-		if w.ID == workerID {
-			return append(workers[:i], workers[i+1:]...)
+	var active []ActiveJob
+	for rows.Next() {
+		var a ActiveJob
+		err := rows.Scan(&a.ID, &a.WorkerID, &a.CPUCores, &a.MemoryGB, &a.GPUCount,
+			&a.StartedAt, &a.EstimatedHours, &a.UserID, &a.GroupID, &a.QoSID, &a.PartitionID, &a.ArrayJobID)
+		if err != nil {
+			log.Printf("Error scanning running job: %v", err)
+			continue
 		}
+		active = append(active, a)
 	}
-	return workers
-}
\ No newline at end of file
+
+	return active, nil
+}
@@ -0,0 +1,478 @@
+package scheduler
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/samik-k21/research-compute-queue/internal/config"
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+// SimJob is a job of a replayed trace
+type SimJob struct {
+	ID             int
+	UserID         int
+	GroupID        int
+	SubmitAt       time.Time
+	RunTime        time.Duration // How long the job runs once started
+	EstimatedHours float64       // Requested walltime (0 = none)
+	CPUCores       int
+	MemoryGB       int
+	GPUCount       int
+	Priority       int
+}
+
+// SimOutcome is what happened to a job in a simulation
+type SimOutcome struct {
+	Job      SimJob
+	Started  bool // False if the job never fit on any worker
+	StartAt  time.Time
+	EndAt    time.Time
+	WorkerID int
+	Killed   bool // Ran past its walltime
+}
+
+// simRun is a job running in a simulation
+type simRun struct {
+	job    *JobWithPriority
+	worker *Worker
+	start  time.Time
+	end    time.Time
+}
+
+// decayedUsage is CPU hours decaying with the fair-share half-life, as
+// loadFairShareTree decays usage_logs
+type decayedUsage struct {
+	cpuHours float64
+	at       time.Time
+}
+
+// valueAt returns the usage decayed until t
+func (u *decayedUsage) valueAt(t time.Time, halfLife time.Duration) float64 {
+	if halfLife <= 0 {
+		return u.cpuHours
+	}
+	return u.cpuHours * math.Pow(0.5, t.Sub(u.at).Seconds()/halfLife.Seconds())
+}
+
+// Simulation runs the scheduling loop against a virtual clock and in-memory
+// state: no database, no processes. Priorities come from the same
+// PriorityCalculator, placement from the same ResourceMatcher, and each cycle
+// makes the same queue pass as the server, without preemption.
+type Simulation struct {
+	priorityCalc  *PriorityCalculator
+	matcher       *ResourceMatcher
+	maxConcurrent int
+	walltimeGrace float64
+	backfill      bool
+	interval      time.Duration
+	events        bool          // Cycle on submissions and completions, not only on the interval
+	debounce      time.Duration // Delay between an event and its cycle
+
+	workers       []Worker
+	clusterCPUs   int
+	departments   []shareAccount
+	groups        []shareAccount
+	users         []shareAccount
+	knownGroups   map[int]bool
+	knownUsers    map[int]bool
+	groupPriority map[int]int
+	groupLimits   map[int]*accountLimits
+	userLimits    map[int]*accountLimits
+
+	runTimes map[int]time.Duration    // Job ID → how long the job runs
+	usage    map[[2]int]*decayedUsage // (group ID, user ID) → completed jobs' CPU hours
+	running  map[int]*simRun
+
+	// Cycles counts the scheduling cycles run
+	Cycles int
+}
+
+// NewSimulation sets up a simulated cluster with the scheduling settings of cfg
+func NewSimulation(cfg *config.Config, cluster models.SimCluster) (*Simulation, error) {
+	strategy, err := NewPlacementStrategy(cfg.PlacementStrategy)
+	if err != nil {
+		return nil, err
+	}
+
+	sim := &Simulation{
+		priorityCalc:  NewPriorityCalculator(nil, cfg),
+		matcher:       NewResourceMatcher(nil, strategy),
+		maxConcurrent: cfg.MaxConcurrentJobs,
+		walltimeGrace: cfg.WalltimeGraceFactor,
+		backfill:      cfg.BackfillEnabled,
+		interval:      time.Duration(cfg.SchedulerIntervalSecs) * time.Second,
+		events:        cfg.SchedulerEventsEnabled,
+		debounce:      time.Duration(cfg.SchedulerDebounceMs) * time.Millisecond,
+		knownGroups:   make(map[int]bool),
+		knownUsers:    make(map[int]bool),
+		groupPriority: make(map[int]int),
+		groupLimits:   make(map[int]*accountLimits),
+		userLimits:    make(map[int]*accountLimits),
+		runTimes:      make(map[int]time.Duration),
+		usage:         make(map[[2]int]*decayedUsage),
+		running:       make(map[int]*simRun),
+	}
+
+	for _, w := range cluster.Workers {
+		count := w.Count
+		if count == 0 {
+			count = 1
+		}
+		if w.CPUCores < 1 || w.MemoryGB < 1 || w.GPUCount < 0 || count < 0 {
+			return nil, fmt.Errorf("worker %q needs at least 1 CPU core and 1 GB of memory", w.Hostname)
+		}
+		for i := 1; i <= count; i++ {
+			hostname := w.Hostname
+			if count > 1 {
+				hostname = fmt.Sprintf("%s-%02d", w.Hostname, i)
+			}
+			sim.workers = append(sim.workers, Worker{
+				ID:       len(sim.workers) + 1,
+				Hostname: hostname,
+				CPUCores: w.CPUCores,
+				MemoryGB: w.MemoryGB,
+				GPUCount: w.GPUCount,
+				Status:   "idle",
+			})
+			sim.clusterCPUs += w.CPUCores
+		}
+	}
+	if len(sim.workers) == 0 {
+		return nil, fmt.Errorf("the cluster has no workers")
+	}
+
+	departmentIDs := make(map[string]int)
+	for i, d := range cluster.Departments {
+		departmentIDs[d.Name] = i + 1
+		sim.departments = append(sim.departments, shareAccount{id: i + 1, name: d.Name, shares: d.Shares})
+	}
+	for _, g := range cluster.Groups {
+		departmentID, ok := departmentIDs[g.Department]
+		if g.Department != "" && !ok {
+			return nil, fmt.Errorf("group %d is in unknown department %q", g.ID, g.Department)
+		}
+		sim.addGroup(g.ID, departmentID, g.Name, g.Priority, g.Shares)
+		sim.groupLimits[g.ID] = simLimits(g.Limits)
+	}
+	for _, u := range cluster.Users {
+		sim.addUser(u.ID, u.GroupID, u.Shares)
+		sim.userLimits[u.ID] = simLimits(u.Limits)
+	}
+	return sim, nil
+}
+
+// simLimits converts a cluster file's limits
+func simLimits(l models.Limits) *accountLimits {
+	value := func(v *int) int {
+		if v == nil {
+			return 0
+		}
+		return *v
+	}
+	return &accountLimits{
+		maxJobs:     value(l.MaxRunningJobs),
+		maxCPUs:     value(l.MaxCPUs),
+		maxMemoryGB: value(l.MaxMemoryGB),
+		maxGPUs:     value(l.MaxGPUs),
+	}
+}
+
+// addGroup adds a group to the fair-share tree (priority and shares default to 1)
+func (sim *Simulation) addGroup(id, departmentID int, name string, priority int, shares float64) {
+	if sim.knownGroups[id] {
+		return
+	}
+	if name == "" {
+		name = fmt.Sprintf("group %d", id)
+	}
+	if priority == 0 {
+		priority = 1
+	}
+	if shares == 0 {
+		shares = 1
+	}
+	sim.knownGroups[id] = true
+	sim.groupPriority[id] = priority
+	sim.groups = append(sim.groups, shareAccount{id: id, parentID: departmentID, name: name, shares: shares})
+}
+
+// addUser adds a user to a group of the fair-share tree (shares default to 1)
+func (sim *Simulation) addUser(id, groupID int, shares float64) {
+	if sim.knownUsers[id] {
+		return
+	}
+	if shares == 0 {
+		shares = 1
+	}
+	sim.addGroup(groupID, 0, "", 0, 0)
+	sim.knownUsers[id] = true
+	sim.users = append(sim.users, shareAccount{id: id, parentID: groupID, name: fmt.Sprintf("user %d", id), shares: shares})
+}
+
+// Capacity returns the cluster's worker count, CPU cores and GPUs
+func (sim *Simulation) Capacity() (workers, cpus, gpus int) {
+	for _, w := range sim.workers {
+		cpus += w.CPUCores
+		gpus += w.GPUCount
+	}
+	return len(sim.workers), cpus, gpus
+}
+
+// GroupShares returns each group's fair-share target: the fraction of the
+// cluster its shares entitle it to, through its department. Call it after Run
+// so groups first seen in the trace are included.
+func (sim *Simulation) GroupShares() map[int]float64 {
+	tree := newFairShareTree(sim.departments, sim.groups, sim.users, nil)
+	targets := make(map[int]float64)
+	var walk func(n *shareNode, fraction float64)
+	walk = func(n *shareNode, fraction float64) {
+		for _, child := range n.children {
+			switch child.kind {
+			case shareDepartment:
+				walk(child, fraction*child.normShares)
+			case shareGroup:
+				targets[child.id] = fraction * child.normShares
+			}
+		}
+	}
+	walk(tree.root, 1)
+	return targets
+}
+
+// Run replays the jobs and returns what happened to each, in submission order
+func (sim *Simulation) Run(jobs []SimJob) []SimOutcome {
+	jobs = append([]SimJob(nil), jobs...)
+	sort.SliceStable(jobs, func(i, j int) bool { return jobs[i].SubmitAt.Before(jobs[j].SubmitAt) })
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	outcomes := make([]SimOutcome, len(jobs))
+	index := make(map[int]int, len(jobs))
+	for i, job := range jobs {
+		outcomes[i].Job = job
+		index[job.ID] = i
+		sim.runTimes[job.ID] = job.RunTime
+		sim.addUser(job.UserID, job.GroupID, 0)
+	}
+
+	epoch := jobs[0].SubmitAt
+	var pending []JobWithPriority
+	next := 0
+	for next < len(jobs) || len(sim.running) > 0 {
+		// Nothing changes between submissions and completions, so only
+		// the cycle right after each of them is run
+		event := sim.nextEnd()
+		if next < len(jobs) && (event.IsZero() || jobs[next].SubmitAt.Before(event)) {
+			event = jobs[next].SubmitAt
+		}
+		now := sim.cycleTime(epoch, event)
+
+		// Apply everything up to the cycle in time order, so usage decays
+		// from the moment each job ended
+		for {
+			end := sim.nextEnd()
+			submitting := next < len(jobs) && !jobs[next].SubmitAt.After(now) &&
+				(end.IsZero() || jobs[next].SubmitAt.Before(end))
+			switch {
+			case submitting:
+				pending = append(pending, sim.jobWithPriority(&jobs[next]))
+				next++
+				continue
+			case !end.IsZero() && !end.After(now):
+				sim.finishJobsEndingAt(end)
+				continue
+			}
+			break
+		}
+
+		started := sim.cycle(now, pending)
+		remaining := pending[:0]
+		for _, job := range pending {
+			run, ok := started[job.ID]
+			if !ok {
+				remaining = append(remaining, job)
+				continue
+			}
+			o := &outcomes[index[job.ID]]
+			o.Started, o.StartAt, o.EndAt, o.WorkerID = true, run.start, run.end, run.worker.ID
+			o.Killed = run.end.Sub(run.start) < o.Job.RunTime
+		}
+		pending = remaining
+	}
+	return outcomes
+}
+
+// cycleTime returns when the first cycle after an event at t runs: after the
+// debounce with events enabled, otherwise at the next tick of the interval
+func (sim *Simulation) cycleTime(epoch, t time.Time) time.Time {
+	if sim.events {
+		return t.Add(sim.debounce)
+	}
+	ticks := math.Ceil(float64(t.Sub(epoch)) / float64(sim.interval))
+	return epoch.Add(time.Duration(ticks) * sim.interval)
+}
+
+// nextEnd returns when the next running job ends (zero = none running)
+func (sim *Simulation) nextEnd() time.Time {
+	var next time.Time
+	for _, run := range sim.running {
+		if next.IsZero() || run.end.Before(next) {
+			next = run.end
+		}
+	}
+	return next
+}
+
+// finishJobsEndingAt frees the resources of the jobs ending at t and charges
+// their usage, as the executor does when a job finishes
+func (sim *Simulation) finishJobsEndingAt(t time.Time) {
+	for id, run := range sim.running {
+		if !run.end.Equal(t) {
+			continue
+		}
+		run.worker.AllocatedCPUCores -= run.job.CPUCores
+		run.worker.AllocatedMemoryGB -= run.job.MemoryGB
+		run.worker.AllocatedGPUs -= run.job.GPUCount
+
+		key := [2]int{run.job.GroupID, run.job.UserID}
+		u, ok := sim.usage[key]
+		if !ok {
+			u = &decayedUsage{at: t}
+			sim.usage[key] = u
+		}
+		u.cpuHours = u.valueAt(t, sim.priorityCalc.halfLife) + t.Sub(run.start).Hours()*float64(run.job.CPUCores)
+		u.at = t
+		delete(sim.running, id)
+	}
+}
+
+// jobWithPriority converts a trace job for the scheduling loop
+func (sim *Simulation) jobWithPriority(job *SimJob) JobWithPriority {
+	priority := job.Priority
+	if priority == 0 {
+		priority = 1
+	}
+	return JobWithPriority{
+		ID:             job.ID,
+		UserID:         job.UserID,
+		GroupID:        job.GroupID,
+		CPUCores:       job.CPUCores,
+		MemoryGB:       job.MemoryGB,
+		GPUCount:       job.GPUCount,
+		Priority:       priority,
+		SubmittedAt:    job.SubmitAt,
+		EstimatedHours: job.EstimatedHours,
+		GroupPriority:  sim.groupPriority[job.GroupID],
+		QoSFactor:      1,
+	}
+}
+
+// cycle runs one scheduling cycle at now and returns the jobs it started
+func (sim *Simulation) cycle(now time.Time, pending []JobWithPriority) map[int]*simRun {
+	started := make(map[int]*simRun)
+	if len(pending) == 0 {
+		return started
+	}
+	sim.Cycles++
+
+	slots := sim.maxConcurrent - len(sim.running)
+	if slots <= 0 {
+		return started
+	}
+
+	queue := append([]JobWithPriority(nil), pending...)
+	sim.priorityCalc.rank(queue, &priorityContext{
+		now:          now,
+		fairShare:    sim.fairShareTree(now),
+		maxQoSFactor: 1,
+		tiers:        make(map[int]int),
+		clusterCPUs:  sim.clusterCPUs,
+	})
+
+	var planner *backfillPlanner
+	if sim.backfill {
+		var active []ActiveJob
+		for _, run := range sim.running {
+			active = append(active, ActiveJob{
+				ID:             run.job.ID,
+				WorkerID:       run.worker.ID,
+				CPUCores:       run.job.CPUCores,
+				MemoryGB:       run.job.MemoryGB,
+				GPUCount:       run.job.GPUCount,
+				StartedAt:      run.start,
+				EstimatedHours: run.job.EstimatedHours,
+			})
+		}
+		planner = newBackfillPlanner(now, sim.walltimeGrace, active)
+	}
+
+	pass := &queuePass{
+		matcher:       sim.matcher,
+		workers:       sim.workers,
+		maxConcurrent: sim.maxConcurrent,
+		slots:         slots,
+		partitions:    make(map[int]*partition),
+		qos: &qosState{
+			limits:  make(map[int]*qosLimits),
+			byUser:  make(map[qosKey]*qosUsage),
+			byGroup: make(map[qosKey]*qosUsage),
+		},
+		limits:       sim.limitState(),
+		arrayRunning: make(map[int]int),
+		planner:      planner,
+		reasons:      make(map[int]pendingReason),
+		logf:         func(string, ...interface{}) {},
+		start: func(job *JobWithPriority, w *Worker) bool {
+			// Jobs are killed at their walltime, like the executor does
+			runTime := sim.runTimes[job.ID]
+			if job.EstimatedHours > 0 {
+				walltime := time.Duration(job.EstimatedHours * sim.walltimeGrace * float64(time.Hour))
+				if runTime > walltime {
+					runTime = walltime
+				}
+			}
+			jobCopy := *job
+			run := &simRun{job: &jobCopy, worker: w, start: now, end: now.Add(runTime)}
+			sim.running[job.ID] = run
+			started[job.ID] = run
+			return true
+		},
+	}
+	pass.run(queue)
+	return started
+}
+
+// fairShareTree builds the fair-share tree at now from decayed usage, plus
+// what running jobs have used so far
+func (sim *Simulation) fairShareTree(now time.Time) *fairShareTree {
+	var usage []shareUsage
+	for key, u := range sim.usage {
+		usage = append(usage, shareUsage{groupID: key[0], userID: key[1], cpuHours: u.valueAt(now, sim.priorityCalc.halfLife)})
+	}
+	for _, run := range sim.running {
+		usage = append(usage, shareUsage{
+			groupID:  run.job.GroupID,
+			userID:   run.job.UserID,
+			cpuHours: now.Sub(run.start).Hours() * float64(run.job.CPUCores),
+		})
+	}
+	return newFairShareTree(sim.departments, sim.groups, sim.users, usage)
+}
+
+// limitState returns the user and group limits with what is running now
+func (sim *Simulation) limitState() *limitState {
+	ls := &limitState{
+		users:   sim.userLimits,
+		groups:  sim.groupLimits,
+		byUser:  make(map[int]*accountUsage),
+		byGroup: make(map[int]*accountUsage),
+	}
+	for _, run := range sim.running {
+		ls.started(run.job)
+	}
+	return ls
+}
@@ -0,0 +1,33 @@
+package scheduler
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDecayedUsage(t *testing.T) {
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name     string
+		cpuHours float64
+		elapsed  time.Duration
+		halfLife time.Duration
+		want     float64
+	}{
+		{"no decay without a half-life", 100, 30 * day, 0, 100},
+		{"no time elapsed", 100, 0, 7 * day, 100},
+		{"one half-life", 100, 7 * day, 7 * day, 50},
+		{"three half-lives", 100, 21 * day, 7 * day, 12.5},
+		{"half a half-life", 100, 12 * time.Hour, day, 100 / math.Sqrt2},
+		{"no usage", 0, 7 * day, 7 * day, 0},
+	}
+	for _, tt := range tests {
+		u := decayedUsage{cpuHours: tt.cpuHours, at: at}
+		if got := u.valueAt(at.Add(tt.elapsed), tt.halfLife); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package scheduler
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/samik-k21/research-compute-queue/internal/database"
+	"github.com/samik-k21/research-compute-queue/internal/models"
+)
+
+// Errors returned by the worker agent API
+var (
+	ErrWorkerNotFound = errors.New("worker not found")
+	ErrJobNotAssigned = errors.New("job is not assigned to this worker")
+	ErrHostnameInUse  = errors.New("hostname belongs to a local worker or to an agent that is still online")
+)
+
+// RegisterWorker adds a worker agent (or re-registers a restarted one) and returns its ID.
+// An agent can only take over the row of an agent that went offline or stopped
+// sending heartbeats, never a local worker's or a live agent's.
+func (s *Scheduler) RegisterWorker(req models.RegisterWorkerRequest) (int, error) {
+	now := time.Now()
+	var workerID int
+	err := s.db.QueryRow(`
+		INSERT INTO workers (hostname, cpu_cores, memory_gb, gpu_count, status, is_agent, last_heartbeat)
+		VALUES ($1, $2, $3, $4, 'idle', TRUE, $5)
+		ON CONFLICT (hostname) DO UPDATE
+		SET cpu_cores = EXCLUDED.cpu_cores, memory_gb = EXCLUDED.memory_gb,
+		    gpu_count = EXCLUDED.gpu_count,
+		    last_heartbeat = EXCLUDED.last_heartbeat,
+		    status = CASE WHEN workers.status = 'offline' THEN 'idle' ELSE workers.status END
+		WHERE workers.is_agent AND (workers.status = 'offline' OR workers.last_heartbeat < $6)
+		RETURNING id
+	`, req.Hostname, req.CPUCores, req.MemoryGB, req.GPUCount, now, now.Add(-s.heartbeatTimeout)).Scan(&workerID)
+	if err == sql.ErrNoRows {
+		return 0, ErrHostnameInUse
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to register worker: %w", err)
+	}
+
+	if _, err := s.db.Exec(workerStatusSQL, workerID); err != nil {
+		return 0, fmt.Errorf("failed to update worker status: %w", err)
+	}
+
+	database.Notify(s.db, database.EventWorkerOnline, workerID)
+
+	log.Printf("Worker agent %s registered as worker %d (%d CPU, %d GB RAM, %d GPU)",
+		req.Hostname, workerID, req.CPUCores, req.MemoryGB, req.GPUCount)
+	return workerID, nil
+}
+
+// Heartbeat records that an agent is alive and returns the jobs it must stop:
+// any job it reports running that is no longer running on it (e.g. cancelled,
+// or requeued while the agent was unreachable)
+func (s *Scheduler) Heartbeat(workerID int, runningJobs []int) ([]int, error) {
+	var wasOffline bool
+	err := s.db.QueryRow(`
+		UPDATE workers w
+		SET last_heartbeat = $1,
+		    status = CASE WHEN w.status = 'offline' THEN 'idle' ELSE w.status END
+		FROM workers old
+		WHERE w.id = $2 AND w.is_agent AND old.id = w.id
+		RETURNING old.status = 'offline'
+	`, time.Now(), workerID).Scan(&wasOffline)
+	if err == sql.ErrNoRows {
+		return nil, ErrWorkerNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to record heartbeat: %w", err)
+	}
+
+	// A worker marked offline by the reaper comes back once it reports in again
+	if wasOffline {
+		if _, err := s.db.Exec(workerStatusSQL, workerID); err != nil {
+			return nil, fmt.Errorf("failed to update worker status: %w", err)
+		}
+		database.Notify(s.db, database.EventWorkerOnline, workerID)
+		log.Printf("Worker %d is sending heartbeats again, back online", workerID)
+	}
+
+	// Everything the agent runs that is not a running job assigned to it must
+	// stop, as must jobs being preempted
+	rows, err := s.db.Query(
+		"SELECT id FROM jobs WHERE worker_id = $1 AND status = 'running' AND preempted_by IS NULL", workerID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check running jobs: %w", err)
+	}
+	defer rows.Close()
+
+	assigned := make(map[int]bool)
+	for rows.Next() {
+		var jobID int
+		if err := rows.Scan(&jobID); err != nil {
+			return nil, err
+		}
+		assigned[jobID] = true
+	}
+
+	stopJobs := []int{}
+	for _, jobID := range runningJobs {
+		if !assigned[jobID] {
+			stopJobs = append(stopJobs, jobID)
+		}
+	}
+
+	return stopJobs, nil
+}
+
+// ClaimJobs hands an agent the jobs the scheduler has assigned to it but it has not yet picked up
+func (s *Scheduler) ClaimJobs(workerID int) ([]models.AssignedJob, error) {
+	now := time.Now()
+
+	// started_at moves to the claim time so usage reflects when the agent really started
+	rows, err := s.db.Query(`
+		UPDATE jobs
+		SET claimed_at = $1, started_at = $1
+		WHERE worker_id = $2 AND status = 'running' AND claimed_at IS NULL
+		RETURNING id, script, cpu_cores, memory_gb, gpu_count,
+		          COALESCE(output_path, ''), COALESCE(estimated_hours, 0),
+		          array_job_id, array_index
+	`, now, workerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []models.AssignedJob{}
+	for rows.Next() {
+		var job models.AssignedJob
+		var estimatedHours float64
+		err := rows.Scan(&job.JobID, &job.Script, &job.CPUCores, &job.MemoryGB, &job.GPUCount,
+			&job.OutputPath, &estimatedHours, &job.ArrayJobID, &job.ArrayIndex)
+		if err != nil {
+			return nil, err
+		}
+		job.WalltimeSeconds = int64(estimatedHours * s.walltimeGrace * 3600)
+		jobs = append(jobs, job)
+		log.Printf("Worker %d claimed job %d", workerID, job.JobID)
+	}
+
+	return jobs, nil
+}
+
+// ReportJobResult records the exit status an agent reports for one of its jobs
+func (s *Scheduler) ReportJobResult(workerID, jobID int, result models.JobResultRequest) error {
+	// An unclaimed job was requeued and reassigned since this agent ran it
+	var assignedWorker sql.NullInt64
+	var claimed bool
+	err := s.db.QueryRow(
+		"SELECT worker_id, claimed_at IS NOT NULL FROM jobs WHERE id = $1", jobID,
+	).Scan(&assignedWorker, &claimed)
+	if err == sql.ErrNoRows {
+		return ErrJobNotAssigned
+	}
+	if err != nil {
+		return err
+	}
+	if !assignedWorker.Valid || int(assignedWorker.Int64) != workerID || !claimed {
+		return ErrJobNotAssigned
+	}
+
+	s.executor.completeJob(jobID, workerID, result.ExitCode, result.ErrorMessage)
+	return nil
+}
+
+// cancelUnclaimedJob finalizes a cancelled job an agent never picked up, so its
+// allocation is released even though no agent will ever report it
+func (s *Scheduler) cancelUnclaimedJob(jobID int) {
+	var workerID int
+	err := s.db.QueryRow(`
+		SELECT j.worker_id FROM jobs j
+		JOIN workers w ON w.id = j.worker_id
+		WHERE j.id = $1 AND w.is_agent AND j.claimed_at IS NULL
+	`, jobID).Scan(&workerID)
+	if err != nil {
+		return // Not an unclaimed agent job; a claimed one is stopped via heartbeat
+	}
+
+	s.executor.completeJob(jobID, workerID, -1, "")
+}
@@ -0,0 +1,3 @@
+-- Export finished jobs as a trace for the simulator (cmd/simulate):
+--   psql "$DATABASE_URL" -f scripts/export_trace.sql > trace.csv
+\copy (SELECT id, user_id, group_id, submitted_at, started_at, completed_at, cpu_cores, memory_gb, gpu_count, estimated_hours, priority FROM jobs WHERE started_at IS NOT NULL AND completed_at IS NOT NULL AND NOT is_array ORDER BY submitted_at) TO STDOUT WITH (FORMAT csv, HEADER)
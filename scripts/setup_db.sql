@@ -1,18 +1,48 @@
 -- Drop existing tables if they exist (for clean setup)
+DROP TABLE IF EXISTS scheduler_replicas CASCADE;
 DROP TABLE IF EXISTS usage_logs CASCADE;
+DROP TABLE IF EXISTS worker_allocations CASCADE;
+DROP TABLE IF EXISTS preemption_events CASCADE;
+DROP TABLE IF EXISTS job_attempts CASCADE;
 DROP TABLE IF EXISTS job_dependencies CASCADE;
 DROP TABLE IF EXISTS jobs CASCADE;
+DROP TABLE IF EXISTS partition_groups CASCADE;
+DROP TABLE IF EXISTS partition_workers CASCADE;
+DROP TABLE IF EXISTS partitions CASCADE;
+DROP TABLE IF EXISTS group_qos CASCADE;
+DROP TABLE IF EXISTS qos CASCADE;
 DROP TABLE IF EXISTS workers CASCADE;
 DROP TABLE IF EXISTS users CASCADE;
 DROP TABLE IF EXISTS groups CASCADE;
+DROP TABLE IF EXISTS departments CASCADE;
+
+-- Departments: the top level of the fair-share tree
+CREATE TABLE departments (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    shares INTEGER NOT NULL DEFAULT 1,  -- Fair-share entitlement relative to other departments
+    created_at TIMESTAMP DEFAULT NOW(),
+    
+    CONSTRAINT positive_department_shares CHECK (shares > 0)
+);
 
 -- Research groups table
 CREATE TABLE groups (
     id SERIAL PRIMARY KEY,
     name VARCHAR(100) NOT NULL UNIQUE,
+    department_id INTEGER REFERENCES departments(id) ON DELETE SET NULL,  -- NULL = top level of the fair-share tree
+    shares INTEGER NOT NULL DEFAULT 1,  -- Fair-share entitlement relative to sibling groups
     cpu_quota INTEGER DEFAULT 100,  -- CPU hours per month
     priority INTEGER DEFAULT 1,      -- Higher = more important
-    created_at TIMESTAMP DEFAULT NOW()
+    max_walltime_hours DECIMAL,      -- Longest walltime a job may request (NULL = unlimited)
+    max_running_jobs INTEGER,        -- Limits on the whole group's jobs (NULL = unlimited)
+    max_cpus INTEGER,                -- CPU cores held by running jobs
+    max_memory_gb INTEGER,
+    max_gpus INTEGER,
+    max_pending_jobs INTEGER,        -- Jobs waiting in the queue
+    created_at TIMESTAMP DEFAULT NOW(),
+    
+    CONSTRAINT positive_group_shares CHECK (shares > 0)
 );
 
 -- Users table
@@ -22,14 +52,62 @@ CREATE TABLE users (
     password_hash VARCHAR(255) NOT NULL,
     group_id INTEGER REFERENCES groups(id),
     is_admin BOOLEAN DEFAULT FALSE,
+    shares INTEGER NOT NULL DEFAULT 1,  -- Fair-share entitlement relative to others in the group
+    max_running_jobs INTEGER,        -- Limits on the user's jobs (NULL = unlimited)
+    max_cpus INTEGER,
+    max_memory_gb INTEGER,
+    max_gpus INTEGER,
+    max_pending_jobs INTEGER,
+    created_at TIMESTAMP DEFAULT NOW(),
+    
+    CONSTRAINT positive_user_shares CHECK (shares > 0)
+);
+
+-- Partitions: named queues, each scheduled on its own workers with its own limits
+CREATE TABLE partitions (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL UNIQUE,
+    max_walltime_hours DECIMAL,     -- Longest walltime a job may request (NULL = unlimited)
+    max_jobs_per_user INTEGER,      -- Running jobs per user (NULL = unlimited)
+    max_running_jobs INTEGER,       -- Running jobs in the partition (NULL = only MAX_CONCURRENT_JOBS)
+    priority_tier INTEGER DEFAULT 1,  -- Higher tiers are scheduled first
+    is_default BOOLEAN DEFAULT FALSE, -- Used when neither the job nor its group names a partition
     created_at TIMESTAMP DEFAULT NOW()
 );
 
+-- Quality-of-service classes: priority factor, limits and preemption rights
+CREATE TABLE qos (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL UNIQUE,
+    description TEXT,
+    priority_factor DECIMAL NOT NULL DEFAULT 1.0,  -- Multiplies the job's priority
+    max_jobs_per_user INTEGER,      -- Running jobs per user in this QoS (NULL = unlimited)
+    max_cpus_per_user INTEGER,      -- Running CPU cores per user in this QoS
+    max_jobs_per_group INTEGER,     -- Running jobs per group in this QoS
+    max_cpus_per_group INTEGER,     -- Running CPU cores per group in this QoS
+    max_walltime_hours DECIMAL,     -- Longest walltime a job may request
+    can_preempt BOOLEAN DEFAULT TRUE,  -- Jobs may preempt lower-priority preemptible jobs
+    preemptible BOOLEAN DEFAULT FALSE, -- Jobs can always be preempted
+    is_default BOOLEAN DEFAULT FALSE,  -- Used when the job names no QoS; open to every group
+    created_at TIMESTAMP DEFAULT NOW(),
+    
+    CONSTRAINT positive_priority_factor CHECK (priority_factor > 0)
+);
+
+-- Groups allowed to use each (non-default) QoS, granted by admins
+CREATE TABLE group_qos (
+    group_id INTEGER REFERENCES groups(id) ON DELETE CASCADE,
+    qos_id INTEGER REFERENCES qos(id) ON DELETE CASCADE,
+    PRIMARY KEY (group_id, qos_id)
+);
+
 -- Jobs table
 CREATE TABLE jobs (
     id SERIAL PRIMARY KEY,
     user_id INTEGER REFERENCES users(id) NOT NULL,
     group_id INTEGER REFERENCES groups(id) NOT NULL,
+    partition_id INTEGER REFERENCES partitions(id) ON DELETE SET NULL,
+    qos_id INTEGER REFERENCES qos(id) ON DELETE SET NULL,
     
     -- Job specification
     script TEXT NOT NULL,
@@ -41,10 +119,15 @@ CREATE TABLE jobs (
     -- Status tracking
     status VARCHAR(20) NOT NULL DEFAULT 'pending',  -- pending, running, completed, failed, cancelled
     priority INTEGER DEFAULT 1,
+    pending_reason TEXT,  -- Why the last cycle left a pending job waiting: Resources, Dependency, UserLimit...
+    pending_detail TEXT,  -- Human-readable explanation of pending_reason
+    pending_reason_at TIMESTAMP,  -- When the scheduler last evaluated the pending job
+    expected_start TIMESTAMPTZ,  -- Forecast start of a pending job (NULL = cannot be predicted)
+    expected_worker_id INTEGER,  -- Forecast worker
     
     -- Timing
     submitted_at TIMESTAMP DEFAULT NOW(),
-    started_at TIMESTAMP,
+    started_at TIMESTAMPTZ,  -- Compared with NOW() for running usage
     completed_at TIMESTAMP,
     
     -- Results
@@ -54,16 +137,74 @@ CREATE TABLE jobs (
     
     -- Worker assignment
     worker_id INTEGER,
+    claimed_at TIMESTAMPTZ,  -- When a worker agent picked the job up
+    node_failure_count INTEGER DEFAULT 0,  -- Times requeued because its worker died
+    pid INTEGER,  -- Process ID of a local job (for recovery after a server restart)
+    replica_id VARCHAR(300),  -- Server replica running a local job (NULL for agent jobs)
+    
+    -- Retries
+    attempt INTEGER DEFAULT 0,              -- Number of times the job has been started
+    max_retries INTEGER DEFAULT 0,
+    retry_count INTEGER DEFAULT 0,          -- Failed attempts retried so far
+    retry_backoff VARCHAR(20) DEFAULT 'fixed',  -- fixed, exponential
+    retry_delay_seconds INTEGER DEFAULT 60,
+    retry_exit_codes INTEGER[],             -- Only retry these exit codes (NULL = any)
+    retry_on_node_failure BOOLEAN DEFAULT FALSE,
+    retry_on_timeout BOOLEAN DEFAULT FALSE,  -- Retry walltime kills too
+    eligible_at TIMESTAMPTZ,                -- Not scheduled before this time (retry backoff)
+    
+    -- Job arrays: the parent row is never scheduled, its tasks are
+    is_array BOOLEAN DEFAULT FALSE,         -- Parent of a job array (status follows its tasks)
+    array_spec VARCHAR(255),                -- e.g. 0-499:1%20
+    array_throttle INTEGER DEFAULT 0,       -- Max tasks running at once (0 = unlimited)
+    array_job_id INTEGER REFERENCES jobs(id) ON DELETE CASCADE,  -- Parent, for array tasks
+    array_index INTEGER,                    -- Task index, passed as RCQ_ARRAY_TASK_ID
+    
+    -- Preemption
+    preemptible BOOLEAN DEFAULT FALSE,      -- May be stopped to make room for higher-priority jobs
+    preempt_mode VARCHAR(20) DEFAULT 'requeue',  -- requeue, cancel
+    preempted_by INTEGER,                   -- Job this one is being stopped for (NULL = not preempted)
+    preemption_count INTEGER DEFAULT 0,
+    
+    CONSTRAINT valid_status CHECK (status IN ('pending', 'running', 'completed', 'failed', 'cancelled')),
+    CONSTRAINT valid_retry_backoff CHECK (retry_backoff IN ('fixed', 'exponential')),
+    CONSTRAINT valid_preempt_mode CHECK (preempt_mode IN ('requeue', 'cancel'))
+);
+
+-- One row per finished run of a job
+CREATE TABLE job_attempts (
+    id SERIAL PRIMARY KEY,
+    job_id INTEGER REFERENCES jobs(id) ON DELETE CASCADE,
+    attempt INTEGER NOT NULL,
+    worker_id INTEGER,
+    started_at TIMESTAMPTZ,
+    ended_at TIMESTAMPTZ,
+    exit_code INTEGER,
+    status VARCHAR(20) NOT NULL,  -- completed, failed, cancelled, requeued
+    error_message TEXT,
     
-    CONSTRAINT valid_status CHECK (status IN ('pending', 'running', 'completed', 'failed', 'cancelled'))
+    CONSTRAINT valid_attempt_status CHECK (status IN ('completed', 'failed', 'cancelled', 'requeued'))
+);
+
+-- Running jobs stopped to make room for a higher-priority job
+CREATE TABLE preemption_events (
+    id SERIAL PRIMARY KEY,
+    job_id INTEGER REFERENCES jobs(id) ON DELETE CASCADE,           -- The preempted job
+    preemptor_job_id INTEGER REFERENCES jobs(id) ON DELETE SET NULL, -- The job it made room for
+    worker_id INTEGER,
+    action VARCHAR(20) NOT NULL,  -- requeue, cancel
+    reason TEXT,
+    preempted_at TIMESTAMP DEFAULT NOW()
 );
 
 -- Job dependencies (for DAG execution)
 CREATE TABLE job_dependencies (
     job_id INTEGER REFERENCES jobs(id) ON DELETE CASCADE,
     depends_on_job_id INTEGER REFERENCES jobs(id) ON DELETE CASCADE,
+    dependency_type VARCHAR(20) NOT NULL DEFAULT 'afterok',  -- afterok, afterany, afternotok
     PRIMARY KEY (job_id, depends_on_job_id),
-    CONSTRAINT no_self_dependency CHECK (job_id != depends_on_job_id)
+    CONSTRAINT no_self_dependency CHECK (job_id != depends_on_job_id),
+    CONSTRAINT valid_dependency_type CHECK (dependency_type IN ('afterok', 'afterany', 'afternotok'))
 );
 
 -- Worker nodes
@@ -73,11 +214,46 @@ CREATE TABLE workers (
     cpu_cores INTEGER NOT NULL,
     memory_gb INTEGER NOT NULL,
     gpu_count INTEGER DEFAULT 0,
-    status VARCHAR(20) DEFAULT 'idle',  -- idle, busy, offline
+    status VARCHAR(20) DEFAULT 'idle',  -- idle, mixed (partially allocated), full, offline
+    is_agent BOOLEAN DEFAULT FALSE,     -- Runs jobs through a worker agent (cmd/worker)
     last_heartbeat TIMESTAMP,
     created_at TIMESTAMP DEFAULT NOW(),
     
-    CONSTRAINT valid_worker_status CHECK (status IN ('idle', 'busy', 'offline'))
+    CONSTRAINT valid_worker_status CHECK (status IN ('idle', 'mixed', 'full', 'offline'))
+);
+
+-- Workers in each partition (a partition without members uses every worker)
+CREATE TABLE partition_workers (
+    partition_id INTEGER REFERENCES partitions(id) ON DELETE CASCADE,
+    worker_id INTEGER REFERENCES workers(id) ON DELETE CASCADE,
+    PRIMARY KEY (partition_id, worker_id)
+);
+
+-- Groups allowed to use each partition (a partition without rows is open to all)
+CREATE TABLE partition_groups (
+    partition_id INTEGER REFERENCES partitions(id) ON DELETE CASCADE,
+    group_id INTEGER REFERENCES groups(id) ON DELETE CASCADE,
+    is_default BOOLEAN DEFAULT FALSE,  -- The group's default partition
+    PRIMARY KEY (partition_id, group_id)
+);
+
+-- Server replicas, so a replica's local jobs are only recovered once it has stopped
+CREATE TABLE scheduler_replicas (
+    id VARCHAR(300) PRIMARY KEY,  -- hostname/pid/start time
+    hostname VARCHAR(255) NOT NULL,
+    pid INTEGER NOT NULL,
+    started_at TIMESTAMP DEFAULT NOW(),
+    last_seen TIMESTAMP DEFAULT NOW()  -- Updated every scheduler tick
+);
+
+-- Resources allocated to running jobs (one row per running job)
+CREATE TABLE worker_allocations (
+    job_id INTEGER PRIMARY KEY REFERENCES jobs(id) ON DELETE CASCADE,
+    worker_id INTEGER REFERENCES workers(id) NOT NULL,
+    cpu_cores INTEGER NOT NULL,
+    memory_gb INTEGER NOT NULL,
+    gpu_count INTEGER NOT NULL DEFAULT 0,
+    allocated_at TIMESTAMP DEFAULT NOW()
 );
 
 -- Usage tracking (for fair-share calculations)
@@ -86,36 +262,76 @@ CREATE TABLE usage_logs (
     group_id INTEGER REFERENCES groups(id),
     job_id INTEGER REFERENCES jobs(id),
     cpu_hours_used DECIMAL NOT NULL,
-    logged_at TIMESTAMP DEFAULT NOW()
+    logged_at TIMESTAMPTZ DEFAULT NOW()
 );
 
 -- Create indexes for common queries
 CREATE INDEX idx_jobs_user_id ON jobs(user_id);
 CREATE INDEX idx_jobs_status ON jobs(status);
 CREATE INDEX idx_jobs_group_id ON jobs(group_id);
+CREATE INDEX idx_jobs_partition_id ON jobs(partition_id);
+CREATE INDEX idx_jobs_qos_id ON jobs(qos_id);
 CREATE INDEX idx_jobs_submitted_at ON jobs(submitted_at);
+CREATE INDEX idx_jobs_pending_reason ON jobs(pending_reason) WHERE status = 'pending';
+CREATE UNIQUE INDEX idx_jobs_array_task ON jobs(array_job_id, array_index);
+CREATE INDEX idx_job_dependencies_depends_on ON job_dependencies(depends_on_job_id);
+CREATE INDEX idx_job_attempts_job_id ON job_attempts(job_id);
+CREATE INDEX idx_preemption_events_job_id ON preemption_events(job_id);
+CREATE UNIQUE INDEX idx_partitions_default ON partitions(is_default) WHERE is_default;
+CREATE UNIQUE INDEX idx_qos_default ON qos(is_default) WHERE is_default;
+CREATE UNIQUE INDEX idx_partition_groups_default ON partition_groups(group_id) WHERE is_default;
+CREATE INDEX idx_worker_allocations_worker_id ON worker_allocations(worker_id);
 CREATE INDEX idx_usage_logs_group_id ON usage_logs(group_id);
+CREATE INDEX idx_usage_logs_job_id ON usage_logs(job_id);
 CREATE INDEX idx_usage_logs_logged_at ON usage_logs(logged_at);
 
 -- Insert some sample data for testing
-INSERT INTO groups (name, cpu_quota, priority) VALUES 
-    ('ML Research Lab', 500, 3),
-    ('Systems Lab', 400, 2),
-    ('Theory Group', 200, 1);
+INSERT INTO departments (name, shares) VALUES
+    ('Computer Science', 3),
+    ('Mathematics', 1);
+
+INSERT INTO groups (name, department_id, shares, cpu_quota, priority) VALUES 
+    ('ML Research Lab', 1, 5, 500, 3),
+    ('Systems Lab', 1, 4, 400, 2),
+    ('Theory Group', 2, 2, 200, 1);
 
 INSERT INTO workers (hostname, cpu_cores, memory_gb, gpu_count, status) VALUES
     ('compute-node-01', 32, 128, 2, 'idle'),
     ('compute-node-02', 16, 64, 1, 'idle'),
     ('compute-node-03', 64, 256, 4, 'idle');
 
+INSERT INTO partitions (name, max_walltime_hours, priority_tier, is_default) VALUES
+    ('batch', NULL, 1, TRUE),
+    ('debug', 0.5, 2, FALSE);
+
+INSERT INTO qos (name, description, priority_factor, max_jobs_per_user, max_cpus_per_user,
+                 max_walltime_hours, can_preempt, preemptible, is_default) VALUES
+    ('normal', 'Default service level', 1.0, NULL, NULL, NULL, TRUE, FALSE, TRUE),
+    ('high', 'Urgent work, granted per group', 2.0, 4, 64, NULL, TRUE, FALSE, FALSE),
+    ('debug', 'Short interactive tests', 1.5, 2, 8, 0.5, TRUE, FALSE, FALSE),
+    ('scavenger', 'Idle capacity only, always preemptible', 0.5, NULL, NULL, NULL, FALSE, TRUE, FALSE);
+
+INSERT INTO group_qos (group_id, qos_id)
+SELECT g.id, q.id FROM groups g, qos q
+WHERE q.name IN ('debug', 'scavenger') OR (q.name = 'high' AND g.name = 'ML Research Lab');
+
 -- Create a default admin user (password: admin123)
 -- Password hash for 'admin123' using bcrypt
 INSERT INTO users (email, password_hash, group_id, is_admin) VALUES
     ('admin@research.edu', '$2a$10$rN7qXqXqXqXqXqXqXqXqXuO7vKq9q9q9q9q9q9q9q9q9q9q9q9q9q', 1, TRUE);
 
+COMMENT ON TABLE departments IS 'Top level of the fair-share tree (department, group, user)';
 COMMENT ON TABLE groups IS 'Research groups with resource quotas';
 COMMENT ON TABLE users IS 'User accounts with authentication';
+COMMENT ON TABLE partitions IS 'Named queues with their own worker pools and limits';
+COMMENT ON TABLE partition_workers IS 'Member workers of each partition';
+COMMENT ON TABLE partition_groups IS 'Groups allowed to use each partition, and their default';
+COMMENT ON TABLE qos IS 'Quality-of-service classes with priority factors and limits';
+COMMENT ON TABLE group_qos IS 'QoS classes each group may use';
 COMMENT ON TABLE jobs IS 'Submitted computing jobs';
 COMMENT ON TABLE job_dependencies IS 'Job execution dependencies (DAG)';
 COMMENT ON TABLE workers IS 'Available compute nodes';
+COMMENT ON TABLE job_attempts IS 'Per-attempt history of each job (retries and requeues)';
+COMMENT ON TABLE preemption_events IS 'Jobs stopped to make room for higher-priority jobs';
+COMMENT ON TABLE worker_allocations IS 'Resources held by running jobs on each worker';
 COMMENT ON TABLE usage_logs IS 'Historical resource usage for fair-share';
\ No newline at end of file